@@ -3,6 +3,9 @@
 type DashboardData = {
 	user_agents: UserAgents;
 	requests: RequestsData;
+	// Missing from servers without cursor pagination
+	next_cursor?: string;
+	has_more?: boolean;
 };
 
 // ip_address, path, hostname, user_agent, method, response_time, status, location, created_at
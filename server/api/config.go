@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Config holds the api's settings, read from an optional config file,
+// environment variables and command-line flags
+type Config struct {
+	Port               int             `config:"port" help:"Port to listen on"`
+	MetricsPort        int             `config:"metrics_port" help:"Port serving Prometheus metrics at /metrics, 0 to disable"`
+	RateLimit          uint            `config:"rate_limit" help:"Requests per second allowed from a single IP address"`
+	PageSize           int             `config:"page_size" help:"Rows read from the database per query"`
+	MaxLoad            int             `config:"max_load" help:"Maximum rows returned to the dashboard"`
+	MonitorLimit       int             `config:"monitor_limit" help:"Monitors per account when quotas cannot be read"`
+	LiveMaxSubscribers int             `config:"live_max_subscribers" help:"Clients allowed to stream the live tail at once, 0 to disable"`
+	Postgres           database.Config `config:"postgres"`
+	Log                logging.Options `config:"log"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:               3000,
+		RateLimit:          100,
+		PageSize:           250_000,
+		MaxLoad:            1_000_000,
+		MonitorLimit:       3,
+		LiveMaxSubscribers: 1000,
+		Log: logging.Options{
+			Path:       "./api.log",
+			Level:      "info",
+			MaxSize:    100,
+			MaxBackups: 5,
+		},
+	}
+}
+
+// Validate checks the settings are usable before the api starts.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 || c.MetricsPort == c.Port {
+		errs = append(errs, fmt.Errorf("metrics_port must be between 1 and 65535 and differ from port, or 0, got %d", c.MetricsPort))
+	}
+	if c.RateLimit == 0 {
+		errs = append(errs, errors.New("rate_limit must be positive"))
+	}
+	if c.PageSize <= 0 {
+		errs = append(errs, fmt.Errorf("page_size must be positive, got %d", c.PageSize))
+	}
+	if c.MaxLoad < c.PageSize {
+		errs = append(errs, fmt.Errorf("max_load must be at least page_size, got %d", c.MaxLoad))
+	}
+	if c.MonitorLimit < 0 {
+		errs = append(errs, fmt.Errorf("monitor_limit cannot be negative, got %d", c.MonitorLimit))
+	}
+	if c.LiveMaxSubscribers < 0 {
+		errs = append(errs, fmt.Errorf("live_max_subscribers cannot be negative, got %d", c.LiveMaxSubscribers))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.Log.Level))
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxBackups < 0 {
+		errs = append(errs, errors.New("log max_size and max_backups cannot be negative"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,269 @@
+// Package compact encodes and decodes the compact columnar format returned
+// by the data and dashboard endpoints when compact=true.
+//
+// A response holds each field of the requests as a column, with one value
+// per request in the same order. Paths, hostnames and user agents, which
+// repeat across requests, are held once in a dictionary with their column
+// holding indexes into it. Columns the endpoint does not return are omitted.
+package compact
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Version of the format, incremented when a change would break decoders.
+const Version = 1
+
+// Request is a logged request held in a compact response. Fields of columns
+// missing from a response are left empty.
+type Request struct {
+	IPAddress      string
+	Path           string
+	Hostname       string
+	UserAgent      string
+	Method         string
+	Status         int16
+	ResponseTime   int16 // Milliseconds
+	Location       string
+	UserID         string
+	CreatedAt      time.Time
+	Browser        string
+	BrowserVersion string
+	OS             string
+	OSVersion      string
+	DeviceType     string
+	IsBot          bool
+	BotName        string
+}
+
+// Data is a compact response.
+type Data struct {
+	Version      int          `json:"version"`
+	Count        int          `json:"count"`
+	Dictionaries Dictionaries `json:"dictionaries"`
+	Columns      Columns      `json:"columns"`
+}
+
+type Dictionaries struct {
+	Path      []string `json:"path"`
+	Hostname  []string `json:"hostname"`
+	UserAgent []string `json:"user_agent"`
+}
+
+// Columns holds a value per request for each field, with created_at in Unix
+// microseconds.
+type Columns struct {
+	IPAddress      []string `json:"ip_address"`
+	Path           []int    `json:"path"`
+	Hostname       []int    `json:"hostname"`
+	UserAgent      []int    `json:"user_agent"`
+	Method         []string `json:"method"`
+	Status         []int16  `json:"status"`
+	ResponseTime   []int16  `json:"response_time"`
+	Location       []string `json:"location"`
+	UserID         []string `json:"user_id"`
+	CreatedAt      []int64  `json:"created_at"`
+	Browser        []string `json:"browser,omitempty"`
+	BrowserVersion []string `json:"browser_version,omitempty"`
+	OS             []string `json:"os,omitempty"`
+	OSVersion      []string `json:"os_version,omitempty"`
+	DeviceType     []string `json:"device_type,omitempty"`
+	IsBot          []bool   `json:"is_bot,omitempty"`
+	BotName        []string `json:"bot_name,omitempty"`
+}
+
+// Assigns each distinct value an index into its dictionary
+type dictionary struct {
+	values  []string
+	indexes map[string]int
+}
+
+func newDictionary() dictionary {
+	return dictionary{make([]string, 0), make(map[string]int)}
+}
+
+func (d *dictionary) index(value string) int {
+	i, ok := d.indexes[value]
+	if !ok {
+		i = len(d.values)
+		d.values = append(d.values, value)
+		d.indexes[value] = i
+	}
+	return i
+}
+
+// Encoder builds a compact response from requests added in order.
+type Encoder struct {
+	details    bool
+	data       Data
+	paths      dictionary
+	hostnames  dictionary
+	userAgents dictionary
+}
+
+// NewEncoder returns an encoder of requests, including the columns of
+// details parsed from user agents if details is set.
+func NewEncoder(details bool) *Encoder {
+	e := &Encoder{
+		details:    details,
+		data:       Data{Version: Version},
+		paths:      newDictionary(),
+		hostnames:  newDictionary(),
+		userAgents: newDictionary(),
+	}
+	columns := &e.data.Columns
+	columns.IPAddress = make([]string, 0)
+	columns.Path = make([]int, 0)
+	columns.Hostname = make([]int, 0)
+	columns.UserAgent = make([]int, 0)
+	columns.Method = make([]string, 0)
+	columns.Status = make([]int16, 0)
+	columns.ResponseTime = make([]int16, 0)
+	columns.Location = make([]string, 0)
+	columns.UserID = make([]string, 0)
+	columns.CreatedAt = make([]int64, 0)
+	return e
+}
+
+// Add appends a request.
+func (e *Encoder) Add(r Request) {
+	columns := &e.data.Columns
+	columns.IPAddress = append(columns.IPAddress, r.IPAddress)
+	columns.Path = append(columns.Path, e.paths.index(r.Path))
+	columns.Hostname = append(columns.Hostname, e.hostnames.index(r.Hostname))
+	columns.UserAgent = append(columns.UserAgent, e.userAgents.index(r.UserAgent))
+	columns.Method = append(columns.Method, r.Method)
+	columns.Status = append(columns.Status, r.Status)
+	columns.ResponseTime = append(columns.ResponseTime, r.ResponseTime)
+	columns.Location = append(columns.Location, r.Location)
+	columns.UserID = append(columns.UserID, r.UserID)
+	columns.CreatedAt = append(columns.CreatedAt, r.CreatedAt.UnixMicro())
+	if e.details {
+		columns.Browser = append(columns.Browser, r.Browser)
+		columns.BrowserVersion = append(columns.BrowserVersion, r.BrowserVersion)
+		columns.OS = append(columns.OS, r.OS)
+		columns.OSVersion = append(columns.OSVersion, r.OSVersion)
+		columns.DeviceType = append(columns.DeviceType, r.DeviceType)
+		columns.IsBot = append(columns.IsBot, r.IsBot)
+		columns.BotName = append(columns.BotName, r.BotName)
+	}
+	e.data.Count++
+}
+
+// Len returns the number of requests added.
+func (e *Encoder) Len() int {
+	return e.data.Count
+}
+
+// Data returns the response holding the requests added.
+func (e *Encoder) Data() Data {
+	data := e.data
+	data.Dictionaries = Dictionaries{
+		Path:      e.paths.values,
+		Hostname:  e.hostnames.values,
+		UserAgent: e.userAgents.values,
+	}
+	return data
+}
+
+// Unmarshal decodes the requests of a compact response body.
+func Unmarshal(body []byte) ([]Request, error) {
+	var data Data
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Decode returns the requests of a compact response.
+func Decode(data Data) ([]Request, error) {
+	if data.Version != Version {
+		return nil, fmt.Errorf("unsupported compact format version %d", data.Version)
+	}
+	if data.Count < 0 {
+		return nil, fmt.Errorf("invalid request count %d", data.Count)
+	}
+
+	c := data.Columns
+	d := data.Dictionaries
+	requests := make([]Request, data.Count)
+	err := decodeColumn("ip_address", c.IPAddress, requests, func(r *Request, v string) { r.IPAddress = v })
+	if err == nil {
+		err = decodeDictionaryColumn("path", c.Path, d.Path, requests, func(r *Request, v string) { r.Path = v })
+	}
+	if err == nil {
+		err = decodeDictionaryColumn("hostname", c.Hostname, d.Hostname, requests, func(r *Request, v string) { r.Hostname = v })
+	}
+	if err == nil {
+		err = decodeDictionaryColumn("user_agent", c.UserAgent, d.UserAgent, requests, func(r *Request, v string) { r.UserAgent = v })
+	}
+	if err == nil {
+		err = decodeColumn("method", c.Method, requests, func(r *Request, v string) { r.Method = v })
+	}
+	if err == nil {
+		err = decodeColumn("status", c.Status, requests, func(r *Request, v int16) { r.Status = v })
+	}
+	if err == nil {
+		err = decodeColumn("response_time", c.ResponseTime, requests, func(r *Request, v int16) { r.ResponseTime = v })
+	}
+	if err == nil {
+		err = decodeColumn("location", c.Location, requests, func(r *Request, v string) { r.Location = v })
+	}
+	if err == nil {
+		err = decodeColumn("user_id", c.UserID, requests, func(r *Request, v string) { r.UserID = v })
+	}
+	if err == nil {
+		err = decodeColumn("created_at", c.CreatedAt, requests, func(r *Request, v int64) { r.CreatedAt = time.UnixMicro(v).UTC() })
+	}
+	if err == nil {
+		err = decodeColumn("browser", c.Browser, requests, func(r *Request, v string) { r.Browser = v })
+	}
+	if err == nil {
+		err = decodeColumn("browser_version", c.BrowserVersion, requests, func(r *Request, v string) { r.BrowserVersion = v })
+	}
+	if err == nil {
+		err = decodeColumn("os", c.OS, requests, func(r *Request, v string) { r.OS = v })
+	}
+	if err == nil {
+		err = decodeColumn("os_version", c.OSVersion, requests, func(r *Request, v string) { r.OSVersion = v })
+	}
+	if err == nil {
+		err = decodeColumn("device_type", c.DeviceType, requests, func(r *Request, v string) { r.DeviceType = v })
+	}
+	if err == nil {
+		err = decodeColumn("is_bot", c.IsBot, requests, func(r *Request, v bool) { r.IsBot = v })
+	}
+	if err == nil {
+		err = decodeColumn("bot_name", c.BotName, requests, func(r *Request, v string) { r.BotName = v })
+	}
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Sets a field of each request from a column, which is either missing or
+// holds a value per request
+func decodeColumn[T any](name string, column []T, requests []Request, set func(*Request, T)) error {
+	if len(column) == 0 {
+		return nil
+	}
+	if len(column) != len(requests) {
+		return fmt.Errorf("column %s holds %d values, expected %d", name, len(column), len(requests))
+	}
+	for i, v := range column {
+		set(&requests[i], v)
+	}
+	return nil
+}
+
+func decodeDictionaryColumn(name string, column []int, dictionary []string, requests []Request, set func(*Request, string)) error {
+	for _, i := range column {
+		if i < 0 || i >= len(dictionary) {
+			return fmt.Errorf("column %s holds index %d outside its dictionary", name, i)
+		}
+	}
+	return decodeColumn(name, column, requests, func(r *Request, i int) { set(r, dictionary[i]) })
+}
@@ -0,0 +1,166 @@
+package compact
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testRequests() []Request {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 15, 123456000, time.UTC)
+	return []Request{
+		{
+			IPAddress:      "203.0.113.7",
+			Path:           "/v1/users",
+			Hostname:       "api.example.com",
+			UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/122.0",
+			Method:         "GET",
+			Status:         200,
+			ResponseTime:   18,
+			Location:       "GB",
+			UserID:         "user-1",
+			CreatedAt:      createdAt,
+			Browser:        "Chrome",
+			BrowserVersion: "122.0",
+			OS:             "Windows",
+			OSVersion:      "10",
+			DeviceType:     "desktop",
+		},
+		{
+			IPAddress:    "2001:db8::1",
+			Path:         "/v1/users",
+			Hostname:     "api.example.com",
+			UserAgent:    "curl/8.4.0",
+			Method:       "POST",
+			Status:       503,
+			ResponseTime: 1204,
+			CreatedAt:    createdAt.Add(time.Second),
+		},
+		{
+			Path:         "/health",
+			Method:       "HEAD",
+			Status:       204,
+			ResponseTime: 1,
+			CreatedAt:    createdAt.Add(time.Minute),
+			UserAgent:    "Googlebot/2.1",
+			IsBot:        true,
+			BotName:      "Googlebot",
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	requests := testRequests()
+	encoder := NewEncoder(true)
+	for _, r := range requests {
+		encoder.Add(r)
+	}
+	body, err := json.Marshal(encoder.Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Unmarshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, requests) {
+		t.Errorf("got %+v, expected %+v", decoded, requests)
+	}
+}
+
+func TestRoundTripWithoutDetails(t *testing.T) {
+	requests := testRequests()
+	encoder := NewEncoder(false)
+	for _, r := range requests {
+		encoder.Add(r)
+	}
+	body, err := json.Marshal(encoder.Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Unmarshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range requests {
+		r.Browser, r.BrowserVersion, r.OS, r.OSVersion, r.DeviceType, r.IsBot, r.BotName = "", "", "", "", "", false, ""
+		if decoded[i] != r {
+			t.Errorf("got %+v, expected %+v", decoded[i], r)
+		}
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	body, err := json.Marshal(NewEncoder(true).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 0 {
+		t.Errorf("got %d requests, expected 0", len(decoded))
+	}
+}
+
+func TestDictionaries(t *testing.T) {
+	encoder := NewEncoder(false)
+	for _, r := range testRequests() {
+		encoder.Add(r)
+	}
+	data := encoder.Data()
+
+	if data.Version != Version || data.Count != 3 {
+		t.Errorf("got version %d count %d, expected version %d count 3", data.Version, data.Count, Version)
+	}
+	wantPaths := []string{"/v1/users", "/health"}
+	if !reflect.DeepEqual(data.Dictionaries.Path, wantPaths) {
+		t.Errorf("got paths %v, expected %v", data.Dictionaries.Path, wantPaths)
+	}
+	if !reflect.DeepEqual(data.Columns.Path, []int{0, 0, 1}) {
+		t.Errorf("got path column %v, expected [0 0 1]", data.Columns.Path)
+	}
+	wantHostnames := []string{"api.example.com", ""}
+	if !reflect.DeepEqual(data.Dictionaries.Hostname, wantHostnames) {
+		t.Errorf("got hostnames %v, expected %v", data.Dictionaries.Hostname, wantHostnames)
+	}
+	if len(data.Dictionaries.UserAgent) != 3 {
+		t.Errorf("got %d user agents, expected 3", len(data.Dictionaries.UserAgent))
+	}
+	if data.Columns.Browser != nil || data.Columns.IsBot != nil {
+		t.Error("got user agent detail columns, expected none")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid := func() Data {
+		encoder := NewEncoder(true)
+		for _, r := range testRequests() {
+			encoder.Add(r)
+		}
+		return encoder.Data()
+	}
+
+	tests := map[string]func(*Data){
+		"version": func(d *Data) { d.Version = Version + 1 },
+		"count":   func(d *Data) { d.Count = -1 },
+		"length":  func(d *Data) { d.Columns.Status = d.Columns.Status[:2] },
+		"index":   func(d *Data) { d.Columns.Path[1] = len(d.Dictionaries.Path) },
+		"negative index": func(d *Data) {
+			d.Columns.Hostname[0] = -1
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := valid()
+			modify(&data)
+			if _, err := Decode(data); err == nil {
+				t.Error("got nil error, expected invalid data")
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package export writes logged requests as CSV, newline-delimited JSON or
+// Parquet, one row at a time so exports of any size can be streamed.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Rows buffered before being written to a Parquet file, and rows per row
+// group, bounding the memory held by a Parquet export
+const (
+	parquetBatchSize    = 1024
+	parquetRowGroupSize = 100_000
+)
+
+// Row is an exported request, with the same fields as the data endpoint.
+// Small integers are widened as Parquet stores them as 32-bit integers.
+type Row struct {
+	IPAddress      string    `json:"ip_address" parquet:"ip_address"`
+	Path           string    `json:"path" parquet:"path"`
+	Hostname       string    `json:"hostname" parquet:"hostname"`
+	UserAgent      string    `json:"user_agent" parquet:"user_agent"`
+	Method         int32     `json:"method" parquet:"method"`
+	Status         int32     `json:"status" parquet:"status"`
+	ResponseTime   int32     `json:"response_time" parquet:"response_time"`
+	Location       string    `json:"location" parquet:"location"`
+	UserID         string    `json:"user_id" parquet:"user_id"`
+	CreatedAt      time.Time `json:"created_at" parquet:"created_at,timestamp(microsecond)"`
+	Browser        string    `json:"browser" parquet:"browser"`
+	BrowserVersion string    `json:"browser_version" parquet:"browser_version"`
+	OS             string    `json:"os" parquet:"os"`
+	OSVersion      string    `json:"os_version" parquet:"os_version"`
+	DeviceType     string    `json:"device_type" parquet:"device_type"`
+	IsBot          bool      `json:"is_bot" parquet:"is_bot"`
+	BotName        string    `json:"bot_name" parquet:"bot_name"`
+}
+
+var csvHeader = []string{"ip_address", "path", "hostname", "user_agent", "method", "status", "response_time", "location", "user_id", "created_at", "browser", "browser_version", "os", "os_version", "device_type", "is_bot", "bot_name"}
+
+func (r Row) csvRecord() []string {
+	return []string{
+		r.IPAddress,
+		r.Path,
+		r.Hostname,
+		r.UserAgent,
+		strconv.Itoa(int(r.Method)),
+		strconv.Itoa(int(r.Status)),
+		strconv.Itoa(int(r.ResponseTime)),
+		r.Location,
+		r.UserID,
+		r.CreatedAt.UTC().Format(time.RFC3339Nano),
+		r.Browser,
+		r.BrowserVersion,
+		r.OS,
+		r.OSVersion,
+		r.DeviceType,
+		strconv.FormatBool(r.IsBot),
+		r.BotName,
+	}
+}
+
+// Writer writes exported rows in a format. Close must be called to flush
+// buffered rows and complete the file, but does not close the underlying
+// writer.
+type Writer interface {
+	Write(row Row) error
+	Close() error
+}
+
+// ValidFormat reports whether format is a supported export format.
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatNDJSON || format == FormatParquet
+}
+
+// ContentType returns the media type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// NewWriter returns a writer of rows in format to w.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatParquet:
+		return newParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := &csvWriter{csv.NewWriter(w)}
+	if err := writer.w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *csvWriter) Write(row Row) error {
+	return w.w.Write(row.csvRecord())
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type ndjsonWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buffer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	return &ndjsonWriter{buffer, encoder}
+}
+
+func (w *ndjsonWriter) Write(row Row) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.buffer.Flush()
+}
+
+// Writes gzip compressed Parquet, with rows written in batches and flushed
+// in row groups
+type parquetWriter struct {
+	w     *parquet.GenericWriter[Row]
+	batch []Row
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	writer := parquet.NewGenericWriter[Row](w,
+		parquet.Compression(&parquet.Gzip),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+	)
+	return &parquetWriter{writer, make([]Row, 0, parquetBatchSize)}
+}
+
+func (w *parquetWriter) Write(row Row) error {
+	w.batch = append(w.batch, row)
+	if len(w.batch) < parquetBatchSize {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *parquetWriter) flush() error {
+	_, err := w.w.Write(w.batch)
+	w.batch = w.batch[:0]
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
@@ -0,0 +1,199 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// ErrTooManySubscribers is returned when the broker is at its subscriber limit.
+var ErrTooManySubscribers = errors.New("too many live tail subscribers")
+
+// Requests buffered per subscriber before further requests are dropped
+const bufferSize = 256
+
+// Filter selects the requests sent to a subscriber. Zero values match all.
+type Filter struct {
+	Status      int16 // Exact status code
+	StatusClass int16 // First digit of the status code, e.g. 5 for 5xx
+	PathPrefix  string
+	Hostname    string
+}
+
+// ParseFilter reads a filter from a status, such as 404 or 4xx, a path prefix
+// and a hostname.
+func ParseFilter(status string, pathPrefix string, hostname string) (Filter, error) {
+	filter := Filter{PathPrefix: pathPrefix, Hostname: hostname}
+	if status == "" {
+		return filter, nil
+	}
+	if len(status) == 3 && strings.HasSuffix(strings.ToLower(status), "xx") && status[0] >= '1' && status[0] <= '5' {
+		filter.StatusClass = int16(status[0] - '0')
+		return filter, nil
+	}
+	code, err := strconv.ParseInt(status, 10, 16)
+	if err != nil || code < 100 || code > 599 {
+		return filter, fmt.Errorf("invalid status %q", status)
+	}
+	filter.Status = int16(code)
+	return filter, nil
+}
+
+// Match reports whether a request passes the filter.
+func (f Filter) Match(request database.LiveRequest) bool {
+	if f.Status != 0 && request.Status != f.Status {
+		return false
+	}
+	if f.StatusClass != 0 && request.Status/100 != f.StatusClass {
+		return false
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(request.Path, f.PathPrefix) {
+		return false
+	}
+	if f.Hostname != "" && request.Hostname != f.Hostname {
+		return false
+	}
+	return true
+}
+
+// Subscriber receives an account's newly stored requests.
+type Subscriber struct {
+	apiKey   string
+	filter   Filter
+	requests chan database.LiveRequest
+	dropped  int
+}
+
+// Requests returns the channel requests are delivered on.
+func (s *Subscriber) Requests() <-chan database.LiveRequest {
+	return s.requests
+}
+
+// Broker fans out requests published by the logger to subscribers of the
+// same account.
+type Broker struct {
+	mu             sync.Mutex
+	subscribers    map[string]map[*Subscriber]struct{}
+	count          int
+	maxSubscribers int
+}
+
+func NewBroker(maxSubscribers int) *Broker {
+	return &Broker{
+		subscribers:    make(map[string]map[*Subscriber]struct{}),
+		maxSubscribers: maxSubscribers,
+	}
+}
+
+// Subscribe registers a subscriber to an account's requests. It must be
+// removed with Unsubscribe once finished.
+func (b *Broker) Subscribe(apiKey string, filter Filter) (*Subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.count >= b.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	s := &Subscriber{
+		apiKey:   apiKey,
+		filter:   filter,
+		requests: make(chan database.LiveRequest, bufferSize),
+	}
+	if b.subscribers[apiKey] == nil {
+		b.subscribers[apiKey] = make(map[*Subscriber]struct{})
+	}
+	b.subscribers[apiKey][s] = struct{}{}
+	b.count++
+	return s, nil
+}
+
+// Unsubscribe removes a subscriber, returning the number of requests dropped
+// because it fell behind.
+func (b *Broker) Unsubscribe(s *Subscriber) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[s.apiKey][s]; !ok {
+		return s.dropped
+	}
+	delete(b.subscribers[s.apiKey], s)
+	if len(b.subscribers[s.apiKey]) == 0 {
+		delete(b.subscribers, s.apiKey)
+	}
+	b.count--
+	return s.dropped
+}
+
+// Publish delivers requests to the account's matching subscribers without
+// blocking, dropping requests for any subscriber whose buffer is full.
+func (b *Broker) Publish(batch database.LiveBatch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers[batch.APIKey] {
+		for _, request := range batch.Requests {
+			if !s.filter.Match(request) {
+				continue
+			}
+			select {
+			case s.requests <- request:
+			default:
+				s.dropped++
+			}
+		}
+	}
+}
+
+// Listen publishes the requests notified by the logger until ctx is
+// cancelled, reconnecting with connect whenever the connection is lost.
+func (b *Broker) Listen(ctx context.Context, connect func(context.Context) (*pgx.Conn, error)) {
+	const retryInterval = 5 * time.Second
+	for {
+		err := b.listen(ctx, connect)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("Live tail listener failed, reconnecting", logging.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (b *Broker) listen(ctx context.Context, connect func(context.Context) (*pgx.Conn, error)) error {
+	conn, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{database.LiveChannel}.Sanitize()+";")
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var batch database.LiveBatch
+		if err := json.Unmarshal([]byte(notification.Payload), &batch); err != nil {
+			slog.Warn("Invalid live tail notification", logging.Err(err))
+			continue
+		}
+		b.Publish(batch)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api"
+
+var (
+	RateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by the per-IP rate limit.",
+	})
+
+	DBErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Failed database operations.",
+	})
+
+	// Logged requests returned by endpoint, dashboard, data or export
+	RowsReturned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_returned_total",
+		Help:      "Logged requests returned to clients.",
+	}, []string{"endpoint"})
+
+	LiveSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "live_subscribers",
+		Help:      "Clients connected to the live tail.",
+	})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Middleware records the latency of each request against its route.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Route templates keep the number of label values bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HandlerDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// Serve serves the registered metrics at /metrics on their own port, kept
+// apart from the service's public routes.
+func Serve(port int) error {
+	app := gin.New()
+	app.GET("/metrics", Handler())
+	return app.Run(fmt.Sprintf(":%d", port))
+}
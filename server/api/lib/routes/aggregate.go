@@ -0,0 +1,273 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Longest date range served with hourly buckets
+const maxHourlyRange = 31 * 24 * time.Hour
+
+// Values returned per dimension by default, and at most
+const (
+	defaultAggregateLimit = 100
+	maxAggregateLimit     = 1000
+)
+
+// AggregateMetrics summarises the requests in a bucket or with a dimension
+// value, with response time percentiles in milliseconds.
+type AggregateMetrics struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+func newAggregateMetrics(count int64, sketch database.Sketch) AggregateMetrics {
+	return AggregateMetrics{
+		Count: count,
+		P50:   sketch.Quantile(0.5),
+		P90:   sketch.Quantile(0.9),
+		P95:   sketch.Quantile(0.95),
+		P99:   sketch.Quantile(0.99),
+	}
+}
+
+type AggregateBucket struct {
+	Bucket time.Time `json:"bucket"`
+	AggregateMetrics
+}
+
+type AggregateValue struct {
+	Value string `json:"value"`
+	AggregateMetrics
+}
+
+type AggregateData struct {
+	Period     string                      `json:"period"`
+	Total      AggregateMetrics            `json:"total"`
+	Series     []AggregateBucket           `json:"series"`
+	Dimensions map[string][]AggregateValue `json:"dimensions"`
+}
+
+type AggregateQueries struct {
+	period      string
+	dateFrom    time.Time
+	dateTo      time.Time // Exclusive
+	hostname    string
+	excludeBots bool
+	limit       int
+}
+
+func getAggregateQueries(c *gin.Context, now time.Time) (AggregateQueries, error) {
+	queries := AggregateQueries{
+		period:      c.DefaultQuery("period", database.PeriodDay),
+		excludeBots: c.Query("excludeBots") == "true",
+		limit:       defaultAggregateLimit,
+	}
+	if queries.period != database.PeriodHour && queries.period != database.PeriodDay {
+		return queries, errors.New("Invalid period, expected hour or day.")
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxAggregateLimit {
+			return queries, fmt.Errorf("Invalid limit, expected 1 to %d.", maxAggregateLimit)
+		}
+		queries.limit = n
+	}
+
+	hostname, err := database.NormaliseHostname(c.Query("hostname"))
+	if err != nil {
+		return queries, errors.New("Invalid hostname.")
+	}
+	queries.hostname = hostname
+
+	// Dates are inclusive, defaulting to all time up to today, or the last
+	// week for hourly buckets
+	queries.dateTo = now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if dateTo := c.Query("dateTo"); dateTo != "" {
+		date := parseQueryDate(dateTo)
+		if date.IsZero() {
+			return queries, errors.New("Invalid dateTo, expected YYYY-MM-DD.")
+		}
+		queries.dateTo = date.AddDate(0, 0, 1)
+	}
+	if dateFrom := c.Query("dateFrom"); dateFrom != "" {
+		queries.dateFrom = parseQueryDate(dateFrom)
+		if queries.dateFrom.IsZero() {
+			return queries, errors.New("Invalid dateFrom, expected YYYY-MM-DD.")
+		}
+	} else if queries.period == database.PeriodHour {
+		queries.dateFrom = queries.dateTo.AddDate(0, 0, -7)
+	}
+
+	if !queries.dateFrom.Before(queries.dateTo) {
+		return queries, errors.New("Invalid date range.")
+	}
+	if queries.period == database.PeriodHour && queries.dateTo.Sub(queries.dateFrom) > maxHourlyRange {
+		return queries, errors.New("Date range too long for hourly buckets, use the day period.")
+	}
+	return queries, nil
+}
+
+// Filters shared by the series and dimension queries
+func buildAggregateFilter(apiKey string, queries AggregateQueries) (string, []any) {
+	arguments := []any{apiKey, queries.period, queries.dateFrom, queries.dateTo}
+	var filter strings.Builder
+	filter.WriteString("api_key = $1 AND period = $2 AND bucket >= $3 AND bucket < $4")
+	if queries.hostname != "" {
+		arguments = append(arguments, queries.hostname)
+		filter.WriteString(fmt.Sprintf(" AND hostname = $%d", len(arguments)))
+	}
+	if queries.excludeBots {
+		filter.WriteString(" AND NOT bot")
+	}
+	return filter.String(), arguments
+}
+
+func getAggregateSeries(ctx context.Context, db *pgxpool.Pool, apiKey string, queries AggregateQueries) ([]AggregateBucket, AggregateMetrics, error) {
+	filter, arguments := buildAggregateFilter(apiKey, queries)
+	query := "SELECT bucket, sum(count)::bigint, sketch_merge_agg(sketch) FROM request_rollups WHERE " + filter +
+		" AND dimension = 'total' GROUP BY bucket ORDER BY bucket;"
+	rows, err := db.Query(ctx, query, arguments...)
+	if err != nil {
+		return nil, AggregateMetrics{}, err
+	}
+	defer rows.Close()
+
+	series := make([]AggregateBucket, 0)
+	total := make(database.Sketch)
+	var count int64
+	for rows.Next() {
+		var bucket time.Time
+		var n int64
+		var sketch database.Sketch
+		if err := rows.Scan(&bucket, &n, &sketch); err != nil {
+			return nil, AggregateMetrics{}, err
+		}
+		series = append(series, AggregateBucket{bucket, newAggregateMetrics(n, sketch)})
+		total.Merge(sketch)
+		count += n
+	}
+	return series, newAggregateMetrics(count, total), rows.Err()
+}
+
+func getAggregateDimensions(ctx context.Context, db *pgxpool.Pool, apiKey string, queries AggregateQueries) (map[string][]AggregateValue, error) {
+	filter, arguments := buildAggregateFilter(apiKey, queries)
+	arguments = append(arguments, queries.limit)
+	query := fmt.Sprintf(`SELECT dimension, value, count, sketch FROM (
+		SELECT dimension, value, sum(count)::bigint AS count, sketch_merge_agg(sketch) AS sketch,
+			row_number() OVER (PARTITION BY dimension ORDER BY sum(count) DESC, value) AS rank
+		FROM request_rollups WHERE %s AND dimension <> 'total'
+		GROUP BY dimension, value
+	) ranked WHERE rank <= $%d ORDER BY dimension, rank;`, filter, len(arguments))
+	rows, err := db.Query(ctx, query, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dimensions := map[string][]AggregateValue{
+		database.DimensionPath:      {},
+		database.DimensionMethod:    {},
+		database.DimensionStatus:    {},
+		database.DimensionLocation:  {},
+		database.DimensionUserAgent: {},
+	}
+	for rows.Next() {
+		var dimension, value string
+		var count int64
+		var sketch database.Sketch
+		if err := rows.Scan(&dimension, &value, &count, &sketch); err != nil {
+			return nil, err
+		}
+		dimensions[dimension] = append(dimensions[dimension], AggregateValue{value, newAggregateMetrics(count, sketch)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// User agents are rolled up by ID
+	userAgentIDs := make(map[int]struct{})
+	for _, v := range dimensions[database.DimensionUserAgent] {
+		if id, err := strconv.Atoi(v.Value); err == nil {
+			userAgentIDs[id] = struct{}{}
+		}
+	}
+	userAgents, err := getUserAgents(ctx, db, userAgentIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range dimensions[database.DimensionUserAgent] {
+		if id, err := strconv.Atoi(v.Value); err == nil {
+			dimensions[database.DimensionUserAgent][i].Value = userAgents[id]
+		}
+	}
+	return dimensions, nil
+}
+
+func getAggregateHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("userID")
+		if userID == "" {
+			logging.FromContext(c).Warn("User ID empty")
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
+
+		queries, err := getAggregateQueries(c, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+			return
+		}
+
+		logging.FromContext(c).Info("Aggregate access", logging.UserID(userID), slog.String("period", queries.period))
+
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, userID)
+		if err != nil {
+			logging.FromContext(c).Warn("No API key associated with user ID", logging.UserID(userID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
+
+		series, total, err := getAggregateSeries(c.Request.Context(), db, apiKey, queries)
+		if err != nil {
+			logDBError(c, "Aggregate series query failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Failed to fetch aggregates."})
+			return
+		}
+		dimensions, err := getAggregateDimensions(c.Request.Context(), db, apiKey, queries)
+		if err != nil {
+			logDBError(c, "Aggregate dimensions query failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Failed to fetch aggregates."})
+			return
+		}
+
+		c.JSON(http.StatusOK, AggregateData{
+			Period:     queries.period,
+			Total:      total,
+			Series:     series,
+			Dimensions: dimensions,
+		})
+
+		logging.FromContext(c).Info("Aggregate access successful", logging.APIKey(apiKey), slog.Int("buckets", len(series)))
+
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "User last access update failed", logging.APIKey(apiKey), logging.Err(err))
+		}
+	}
+}
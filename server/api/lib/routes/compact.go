@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/tom-draper/api-analytics/server/api/lib/compact"
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+func getMethodName(method int16) string {
+	if name := database.MethodName(method); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(method))
+}
+
+func newCompactRequest(request RequestRow) compact.Request {
+	var ip string
+	if request.IPAddress.IPNet != nil {
+		ip = request.IPAddress.IPNet.IP.String()
+	}
+	return compact.Request{
+		IPAddress:      ip,
+		Path:           request.Path,
+		Hostname:       getNullableString(request.Hostname),
+		UserAgent:      getNullableString(request.UserAgent),
+		Method:         getMethodName(request.Method),
+		Status:         request.Status,
+		ResponseTime:   request.ResponseTime,
+		Location:       getNullableString(request.Location),
+		UserID:         getNullableString(request.UserID),
+		CreatedAt:      request.CreatedAt,
+		Browser:        getNullableString(request.Browser),
+		BrowserVersion: getNullableString(request.BrowserVersion),
+		OS:             getNullableString(request.OS),
+		OSVersion:      getNullableString(request.OSVersion),
+		DeviceType:     getNullableString(request.DeviceType),
+		IsBot:          request.IsBot != nil && *request.IsBot,
+		BotName:        getNullableString(request.BotName),
+	}
+}
+
+func buildRequestDataCompact(rows pgx.Rows, page *pageReader) compact.Data {
+	encoder := compact.NewEncoder(true)
+	var request RequestRow
+	for page.Next(rows) {
+		err := scanRequestRow(rows, &request)
+		if err == nil {
+			page.Record(request.CreatedAt, request.RequestID)
+			encoder.Add(newCompactRequest(request))
+		}
+	}
+	return encoder.Data()
+}
+
+// DashboardCompactData holds dashboard requests in the compact format, which
+// carries user agents in its own dictionary.
+type DashboardCompactData struct {
+	compact.Data
+	NextCursor string `json:"next_cursor"` // Empty if no requests have been returned yet
+	HasMore    bool   `json:"has_more"`
+}
+
+// dashboardRequests collects the requests of a dashboard response, either as
+// rows referencing a user agents lookup or in the compact format.
+type dashboardRequests struct {
+	rows         [][10]any
+	encoder      *compact.Encoder // Nil unless compact
+	userAgentIDs map[int]struct{}
+}
+
+func newDashboardRequests(compactFormat bool) *dashboardRequests {
+	requests := &dashboardRequests{rows: [][10]any{}, userAgentIDs: make(map[int]struct{})}
+	if compactFormat {
+		requests.encoder = compact.NewEncoder(false)
+	}
+	return requests
+}
+
+func (r *dashboardRequests) add(request *DashboardRequestRow) {
+	if request.UserAgent != nil {
+		r.userAgentIDs[*request.UserAgent] = struct{}{}
+	}
+
+	var ip string
+	if request.IPAddress.IPNet != nil {
+		ip = request.IPAddress.IPNet.IP.String()
+	}
+	hostname := getNullableString(request.Hostname)
+	location := getNullableString(request.Location)
+	userID := getNullableString(request.UserID)
+	if r.encoder == nil {
+		r.rows = append(r.rows, [10]any{ip, request.Path, hostname, request.UserAgent, request.Method, request.ResponseTime, request.Status, location, userID, request.CreatedAt})
+		return
+	}
+
+	// User agents are added by ID, replaced by their names once looked up
+	var userAgent string
+	if request.UserAgent != nil {
+		userAgent = strconv.Itoa(*request.UserAgent)
+	}
+	r.encoder.Add(compact.Request{
+		IPAddress:    ip,
+		Path:         request.Path,
+		Hostname:     hostname,
+		UserAgent:    userAgent,
+		Method:       getMethodName(request.Method),
+		Status:       request.Status,
+		ResponseTime: request.ResponseTime,
+		Location:     location,
+		UserID:       userID,
+		CreatedAt:    request.CreatedAt,
+	})
+}
+
+func (r *dashboardRequests) len() int {
+	if r.encoder == nil {
+		return len(r.rows)
+	}
+	return r.encoder.Len()
+}
+
+// Returns the response body, with user agents named from their lookup
+func (r *dashboardRequests) body(userAgents UserAgentsLookup, nextCursor string, hasMore bool) any {
+	if r.encoder == nil {
+		// Store user agents in separate lookup table to reduce data transfer size
+		return DashboardData{
+			UserAgents: userAgents,
+			Requests:   r.rows,
+			NextCursor: nextCursor,
+			HasMore:    hasMore,
+		}
+	}
+
+	data := r.encoder.Data()
+	for i, id := range data.Dictionaries.UserAgent {
+		if id, err := strconv.Atoi(id); err == nil {
+			data.Dictionaries.UserAgent[i] = userAgents[id]
+		}
+	}
+	return DashboardCompactData{data, nextCursor, hasMore}
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Cursor marks the last request of a page, with requests ordered by creation
+// time then ID so pages stay stable while requests are stored or deleted.
+type Cursor struct {
+	CreatedAt time.Time
+	RequestID int64
+}
+
+var errInvalidCursor = errors.New("Invalid cursor.")
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c Cursor) Encode() string {
+	value := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + "." + strconv.FormatInt(c.RequestID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeCursor(value string) (Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	createdAt, requestID, ok := strings.Cut(string(decoded), ".")
+	if !ok {
+		return Cursor{}, errInvalidCursor
+	}
+	micros, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(requestID, 10, 64)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	return Cursor{time.UnixMicro(micros).UTC(), id}, nil
+}
+
+// Reads an optional cursor query parameter. An empty cursor starts from the
+// first request.
+func getCursorQuery(value string, present bool) (*Cursor, error) {
+	if !present {
+		return nil, nil
+	}
+	if value == "" {
+		return &Cursor{}, nil
+	}
+	cursor, err := decodeCursor(value)
+	return &cursor, err
+}
+
+// Condition selecting requests after a cursor, bounding created_at alone as
+// well so the index and partitions can be used
+func writeCursorCondition(query *strings.Builder, arguments []any, cursor Cursor, prefix string) []any {
+	if cursor.CreatedAt.IsZero() {
+		return arguments
+	}
+	arguments = append(arguments, cursor.CreatedAt, cursor.RequestID)
+	createdAt, requestID := len(arguments)-1, len(arguments)
+	query.WriteString(fmt.Sprintf(" AND %screated_at >= $%d AND (%screated_at, %srequest_id) > ($%d, $%d)",
+		prefix, createdAt, prefix, prefix, createdAt, requestID))
+	return arguments
+}
+
+// pageReader reads up to limit rows of a page fetched with one extra row,
+// which only reveals whether more requests follow, tracking the cursor of
+// the last request read.
+type pageReader struct {
+	limit   int
+	read    int
+	last    Cursor
+	hasMore bool
+}
+
+// Next advances to the next row of the page, if any.
+func (p *pageReader) Next(rows pgx.Rows) bool {
+	if !rows.Next() {
+		return false
+	}
+	if p.read == p.limit {
+		p.hasMore = true
+		return false
+	}
+	p.read++
+	return true
+}
+
+// Record notes a request as the last read.
+func (p *pageReader) Record(createdAt time.Time, requestID int64) {
+	p.last = Cursor{createdAt, requestID}
+}
+
+// NextCursor returns the cursor to fetch the following page with, continuing
+// from after if the page was empty.
+func (p *pageReader) NextCursor(after *Cursor) string {
+	if !p.last.CreatedAt.IsZero() {
+		return p.last.Encode()
+	}
+	if after != nil && !after.CreatedAt.IsZero() {
+		return after.Encode()
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{time.Date(2024, 1, 1, 12, 30, 0, 123456000, time.UTC), 42},
+		{time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC), 1},
+		{time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 1 << 62},
+	}
+	for _, cursor := range cursors {
+		encoded := cursor.Encode()
+		if strings.ContainsAny(encoded, "+/=") {
+			t.Errorf("got %q, expected a URL-safe cursor", encoded)
+		}
+		decoded, err := decodeCursor(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.RequestID != cursor.RequestID {
+			t.Errorf("got %v, expected %v", decoded, cursor)
+		}
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	encode := func(value string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	for _, value := range []string{
+		"not base64!",
+		encode("1704067200000000"),
+		encode("abc.42"),
+		encode("1704067200000000.abc"),
+		encode("1704067200000000.42; DROP TABLE requests"),
+		encode("."),
+	} {
+		if _, err := decodeCursor(value); err != errInvalidCursor {
+			t.Errorf("%q: got %v, expected %v", value, err, errInvalidCursor)
+		}
+	}
+}
+
+func TestGetCursorQuery(t *testing.T) {
+	if cursor, err := getCursorQuery("", false); cursor != nil || err != nil {
+		t.Errorf("got %v, %v, expected no cursor without the parameter", cursor, err)
+	}
+	if cursor, err := getCursorQuery("", true); err != nil || cursor == nil || !cursor.CreatedAt.IsZero() {
+		t.Errorf("got %v, %v, expected a cursor at the first request", cursor, err)
+	}
+	if _, err := getCursorQuery("invalid!", true); err == nil {
+		t.Error("expected invalid cursor to be rejected")
+	}
+}
+
+func TestWriteCursorCondition(t *testing.T) {
+	var query strings.Builder
+	arguments := writeCursorCondition(&query, []any{"key"}, Cursor{}, "r.")
+	if query.Len() != 0 || len(arguments) != 1 {
+		t.Errorf("got %q with %d arguments, expected no condition for the first page", query.String(), len(arguments))
+	}
+
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	arguments = writeCursorCondition(&query, []any{"key", "example.com"}, Cursor{createdAt, 42}, "r.")
+	expected := " AND r.created_at >= $3 AND (r.created_at, r.request_id) > ($3, $4)"
+	if query.String() != expected {
+		t.Errorf("got %q, expected %q", query.String(), expected)
+	}
+	if len(arguments) != 4 || arguments[2] != createdAt || arguments[3] != int64(42) {
+		t.Errorf("unexpected arguments %v", arguments)
+	}
+}
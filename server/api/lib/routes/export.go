@@ -0,0 +1,163 @@
+package routes
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/api/lib/export"
+	"github.com/tom-draper/api-analytics/server/api/lib/metrics"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Trailer holding the number of requests exported
+const exportRowsTrailer = "X-Export-Rows"
+
+// Selects all of an account's requests matching the filters of queries,
+// starting after any cursor given
+func buildDataExportQuery(apiKey string, queries DataFetchQueries) (string, []any) {
+	query, arguments := buildDataSelect(apiKey, queries)
+	if queries.cursor != nil {
+		arguments = writeCursorCondition(query, arguments, *queries.cursor, "r.")
+	}
+	query.WriteString(" ORDER BY r.created_at, r.request_id;")
+	return query.String(), arguments
+}
+
+func newExportRow(request RequestRow) export.Row {
+	var ip string
+	if request.IPAddress.IPNet != nil {
+		ip = request.IPAddress.IPNet.IP.String()
+	}
+	return export.Row{
+		IPAddress:      ip,
+		Path:           request.Path,
+		Hostname:       getNullableString(request.Hostname),
+		UserAgent:      getNullableString(request.UserAgent),
+		Method:         int32(request.Method),
+		Status:         int32(request.Status),
+		ResponseTime:   int32(request.ResponseTime),
+		Location:       getNullableString(request.Location),
+		UserID:         getNullableString(request.UserID),
+		CreatedAt:      request.CreatedAt,
+		Browser:        getNullableString(request.Browser),
+		BrowserVersion: getNullableString(request.BrowserVersion),
+		OS:             getNullableString(request.OS),
+		OSVersion:      getNullableString(request.OSVersion),
+		DeviceType:     getNullableString(request.DeviceType),
+		IsBot:          request.IsBot != nil && *request.IsBot,
+		BotName:        getNullableString(request.BotName),
+	}
+}
+
+// Writes each row as it is read from the connection, so the result set is
+// never held in memory
+func writeExport(rows pgx.Rows, w io.Writer, format string) (int, error) {
+	writer, err := export.NewWriter(format, w)
+	if err != nil {
+		return 0, err
+	}
+	var request RequestRow
+	count := 0
+	for rows.Next() {
+		if err := scanRequestRow(rows, &request); err != nil {
+			return count, err
+		}
+		if err := writer.Write(newExportRow(request)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
+
+// Closes the connection without ending the response, so clients and proxies
+// see a truncated export fail rather than mistake it for a complete one
+func abortStream(c *gin.Context) {
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		conn.Close()
+	}
+}
+
+func exportDataHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeRead)
+		if !ok {
+			return
+		}
+
+		format := c.DefaultQuery("format", export.FormatCSV)
+		if !export.ValidFormat(format) {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid format, expected csv, ndjson or parquet."})
+			return
+		}
+		// Parquet is always compressed internally
+		compress := c.Query("gzip") == "true" && format != export.FormatParquet
+
+		queries, err := getQueriesFromRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+			return
+		}
+
+		logging.FromContext(c).Info("Export access", logging.APIKey(apiKey), slog.String("format", format))
+
+		query, arguments := buildDataExportQuery(apiKey, queries)
+		rows, err := db.Query(c.Request.Context(), query, arguments...)
+		if err != nil {
+			logDBError(c, "Export query failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Export failed."})
+			return
+		}
+		defer rows.Close()
+
+		filename := "requests." + format
+		contentType := export.ContentType(format)
+		if compress {
+			filename += ".gz"
+			contentType = "application/gzip"
+		}
+		header := c.Writer.Header()
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		header.Set("Trailer", exportRowsTrailer)
+		header.Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+		c.Status(http.StatusOK)
+
+		var count int
+		if compress {
+			gz := gzip.NewWriter(c.Writer)
+			count, err = writeExport(rows, gz, format)
+			if err == nil {
+				err = gz.Close()
+			}
+		} else {
+			count, err = writeExport(rows, c.Writer, format)
+		}
+		metrics.RowsReturned.WithLabelValues("export").Add(float64(count))
+		if err != nil {
+			// Either the query or the client connection failed
+			logging.FromContext(c).Warn("Export failed", logging.APIKey(apiKey), slog.Int("requests", count), logging.Err(err))
+			abortStream(c)
+			return
+		}
+		header.Set(exportRowsTrailer, strconv.Itoa(count))
+
+		logging.FromContext(c).Info("Export access successful", logging.APIKey(apiKey), slog.Int("requests", count))
+
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "User last access update failed", logging.APIKey(apiKey), logging.Err(err))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+// Most values a single filter can be given
+const maxFilterValues = 100
+
+// filter matches requests with any of its included values, if any, and none
+// of its excluded values.
+type filter[T any] struct {
+	include []T
+	exclude []T
+}
+
+// Inclusive range of status codes
+type statusRange struct {
+	min int
+	max int
+}
+
+// Reads a filter from a query parameter. The parameter can be repeated, or
+// when split is set hold a comma-separated list, to give several values, and
+// values prefixed with ! are excluded.
+func parseFilter[T any](c *gin.Context, param string, split bool, parse func(string) (T, error)) (filter[T], error) {
+	var f filter[T]
+	for _, value := range c.QueryArray(param) {
+		values := []string{value}
+		if split {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if split {
+				v = strings.TrimSpace(v)
+			}
+			excluded := strings.HasPrefix(v, "!")
+			v = strings.TrimPrefix(v, "!")
+			parsed, err := parse(v)
+			if err != nil {
+				return f, fmt.Errorf("Invalid %s %q, %w.", param, v, err)
+			}
+			if excluded {
+				f.exclude = append(f.exclude, parsed)
+			} else {
+				f.include = append(f.include, parsed)
+			}
+		}
+	}
+	if len(f.include)+len(f.exclude) > maxFilterValues {
+		return f, fmt.Errorf("Too many %s values, at most %d can be given.", param, maxFilterValues)
+	}
+	return f, nil
+}
+
+// Accepts a status code, a class such as 5xx, or a range such as 500-599
+func parseStatusRange(value string) (statusRange, error) {
+	invalid := errors.New("expected a status code, class such as 5xx, or range such as 500-599")
+	if len(value) == 3 && strings.HasSuffix(strings.ToLower(value), "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err != nil || class < 1 || class > 5 {
+			return statusRange{}, invalid
+		}
+		return statusRange{class * 100, class*100 + 99}, nil
+	}
+
+	from, to, isRange := strings.Cut(value, "-")
+	if !isRange {
+		to = from
+	}
+	min, err := strconv.Atoi(from)
+	if err != nil || !database.ValidStatus(min) {
+		return statusRange{}, invalid
+	}
+	max, err := strconv.Atoi(to)
+	if err != nil || !database.ValidStatus(max) || max < min {
+		return statusRange{}, invalid
+	}
+	return statusRange{min, max}, nil
+}
+
+func parseMethod(value string) (int16, error) {
+	id, ok := database.MethodID(strings.ToUpper(value))
+	if !ok {
+		return 0, errors.New("expected an HTTP method such as GET")
+	}
+	return id, nil
+}
+
+// Accepts an IP address or CIDR block, returned as a block with any host
+// bits cleared as Postgres requires
+func parseIPPrefix(value string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, errors.New("expected an IP address or CIDR block such as 10.0.0.0/8")
+	}
+	return prefix.Masked(), nil
+}
+
+// Converts a path glob, where * matches any characters and ? matches one, to
+// a LIKE pattern. Paths without wildcards are matched exactly.
+func parsePathGlob(value string) (string, error) {
+	if err := database.CheckString("path", value); err != nil || value == "" || len(value) > database.MaxPathLength {
+		return "", errors.New("expected a path, with * and ? as wildcards")
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_")
+	return replacer.Replace(value), nil
+}
+
+func parseHostname(value string) (string, error) {
+	hostname, err := database.NormaliseHostname(value)
+	if err != nil || hostname == "" {
+		return "", errors.New("expected a domain name or IP address")
+	}
+	return hostname, nil
+}
+
+func parseLocation(value string) (string, error) {
+	if !database.ValidLocation(value) {
+		return "", errors.New("expected a two letter country code")
+	}
+	return strings.ToUpper(value), nil
+}
+
+// Accepts any non-empty value that could have been stored
+func parseString(value string) (string, error) {
+	if value == "" {
+		return "", errors.New("expected a value")
+	}
+	if err := database.CheckString("value", value); err != nil {
+		return "", errors.New("expected printable text")
+	}
+	return value, nil
+}
+
+// Appends the conditions of a filter to a query. The include and exclude
+// conditions are formatted with the placeholder of an array of the filter's
+// included or excluded values.
+func writeFilter[T any](query *strings.Builder, arguments []any, f filter[T], include string, exclude string) []any {
+	if len(f.include) > 0 {
+		arguments = append(arguments, f.include)
+		query.WriteString(" and " + fmt.Sprintf(include, fmt.Sprintf("$%d", len(arguments))))
+	}
+	if len(f.exclude) > 0 {
+		arguments = append(arguments, f.exclude)
+		query.WriteString(" and " + fmt.Sprintf(exclude, fmt.Sprintf("$%d", len(arguments))))
+	}
+	return arguments
+}
+
+// Appends a condition matching a column, which cannot be null, against any of
+// several values
+func writeEqualFilter[T any](query *strings.Builder, arguments []any, f filter[T], column string) []any {
+	return writeFilter(query, arguments, f, column+" = ANY(%s)", "NOT "+column+" = ANY(%s)")
+}
+
+// Appends a condition matching a nullable column against any of several
+// values, keeping requests without a value when values are excluded
+func writeNullableEqualFilter[T any](query *strings.Builder, arguments []any, f filter[T], column string) []any {
+	return writeFilter(query, arguments, f, column+" = ANY(%s)", "("+column+" IS NULL OR NOT "+column+" = ANY(%s))")
+}
+
+func writeStatusFilter(query *strings.Builder, arguments []any, f filter[statusRange]) []any {
+	ranges := func(statuses []statusRange) string {
+		conditions := make([]string, len(statuses))
+		for i, s := range statuses {
+			arguments = append(arguments, s.min, s.max)
+			conditions[i] = fmt.Sprintf("r.status BETWEEN $%d AND $%d", len(arguments)-1, len(arguments))
+		}
+		return strings.Join(conditions, " OR ")
+	}
+	if len(f.include) > 0 {
+		query.WriteString(" and (" + ranges(f.include) + ")")
+	}
+	if len(f.exclude) > 0 {
+		query.WriteString(" and NOT (" + ranges(f.exclude) + ")")
+	}
+	return arguments
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected statusRange
+		valid    bool
+	}{
+		{"404", statusRange{404, 404}, true},
+		{"5xx", statusRange{500, 599}, true},
+		{"2XX", statusRange{200, 299}, true},
+		{"400-499", statusRange{400, 499}, true},
+		{"500-400", statusRange{}, false},
+		{"6xx", statusRange{}, false},
+		{"0xx", statusRange{}, false},
+		{"x5x", statusRange{}, false},
+		{"99", statusRange{}, false},
+		{"600", statusRange{}, false},
+		{"400-", statusRange{}, false},
+		{"-400", statusRange{}, false},
+		{"400-499-599", statusRange{}, false},
+		{"", statusRange{}, false},
+	}
+	for _, test := range tests {
+		got, err := parseStatusRange(test.value)
+		if (err == nil) != test.valid || got != test.expected {
+			t.Errorf("%q: got %v (error %v), expected %v (valid %t)", test.value, got, err, test.expected, test.valid)
+		}
+	}
+}
+
+func TestParsePathGlob(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		valid    bool
+	}{
+		{"/v1/users", "/v1/users", true},
+		{"/v1/*", "/v1/%", true},
+		{"/v?/users", "/v_/users", true},
+		// LIKE wildcards and the escape character are matched literally
+		{"/100%", `/100\%`, true},
+		{"/snake_case", `/snake\_case`, true},
+		{`/a\b`, `/a\\b`, true},
+		{`/a\*`, `/a\\%`, true},
+		{"", "", false},
+		{"/users\n", "", false},
+		{"/" + strings.Repeat("a", 255), "", false},
+	}
+	for _, test := range tests {
+		got, err := parsePathGlob(test.value)
+		if (err == nil) != test.valid || got != test.expected {
+			t.Errorf("%q: got %q (error %v), expected %q (valid %t)", test.value, got, err, test.expected, test.valid)
+		}
+	}
+}
+
+func TestParseIPPrefix(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		valid    bool
+	}{
+		{"203.0.113.7", "203.0.113.7/32", true},
+		{"2001:db8::1", "2001:db8::1/128", true},
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"10.1.2.3/8", "10.0.0.0/8", true}, // Host bits cleared
+		{"2001:db8::1/32", "2001:db8::/32", true},
+		{"10.0.0.0/33", "", false},
+		{"example.com", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, err := parseIPPrefix(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: got error %v, expected valid %t", test.value, err, test.valid)
+			continue
+		}
+		if test.valid && got != netip.MustParsePrefix(test.expected) {
+			t.Errorf("%q: got %s, expected %s", test.value, got, test.expected)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := parseFilter(testContext("/api/data?method=get,!options&method=POST"), "method", true, parseMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.include) != 2 || f.include[0] != 0 || f.include[1] != 1 || len(f.exclude) != 1 || f.exclude[0] != 5 {
+		t.Errorf("got include %v and exclude %v, expected [0 1] and [5]", f.include, f.exclude)
+	}
+
+	// Unsplit values keep their commas
+	userIDs, err := parseFilter(testContext("/api/data?userID=a,b"), "userID", false, parseString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userIDs.include) != 1 || userIDs.include[0] != "a,b" {
+		t.Errorf("got %v, expected [a,b]", userIDs.include)
+	}
+
+	if _, err := parseFilter(testContext("/api/data?method=BREW"), "method", true, parseMethod); err == nil {
+		t.Error("expected invalid method to be rejected")
+	}
+	tooMany := "/api/data?status=" + strings.Repeat("200,", maxFilterValues) + "200"
+	if _, err := parseFilter(testContext(tooMany), "status", true, parseStatusRange); err == nil {
+		t.Error("expected too many values to be rejected")
+	}
+}
+
+func TestWriteFilter(t *testing.T) {
+	var query strings.Builder
+	arguments := []any{"key"}
+
+	methods := filter[int16]{include: []int16{0, 1}, exclude: []int16{5}}
+	arguments = writeEqualFilter(&query, arguments, methods, "r.method")
+	locations := filter[string]{exclude: []string{"GB"}}
+	arguments = writeNullableEqualFilter(&query, arguments, locations, "r.location")
+	statuses := filter[statusRange]{include: []statusRange{{500, 599}, {404, 404}}, exclude: []statusRange{{503, 503}}}
+	arguments = writeStatusFilter(&query, arguments, statuses)
+	arguments = writeEqualFilter(&query, arguments, filter[string]{}, "r.path")
+
+	expected := " and r.method = ANY($2) and NOT r.method = ANY($3)" +
+		" and (r.location IS NULL OR NOT r.location = ANY($4))" +
+		" and (r.status BETWEEN $5 AND $6 OR r.status BETWEEN $7 AND $8) and NOT (r.status BETWEEN $9 AND $10)"
+	if query.String() != expected {
+		t.Errorf("got query\n%s\nexpected\n%s", query.String(), expected)
+	}
+	if len(arguments) != 10 || arguments[4] != 500 || arguments[7] != 404 || arguments[8] != 503 {
+		t.Errorf("unexpected arguments %v", arguments)
+	}
+}
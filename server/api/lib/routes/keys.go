@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// RotatedAPIKey holds a new API key, with the time the account's previous
+// keys stop being accepted.
+type RotatedAPIKey struct {
+	APIKey    string    `json:"api_key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func getRotationGrace(c *gin.Context) (time.Duration, bool) {
+	value := c.Query("grace")
+	if value == "" {
+		return database.DefaultRotationGrace, true
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 || grace > database.MaxRotationGrace {
+		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid grace, expected a duration up to 720h such as 48h."})
+		return 0, false
+	}
+	return grace, true
+}
+
+func rotateAPIKeyHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeAdmin)
+		if !ok {
+			return
+		}
+		grace, ok := getRotationGrace(c)
+		if !ok {
+			return
+		}
+
+		apiKey, expiresAt, err := database.RotateAPIKey(c.Request.Context(), db, accountID, grace)
+		if err != nil {
+			logDBError(c, "API key rotation failed", logging.APIKey(accountID), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "API key rotation failed."})
+			return
+		}
+
+		logging.FromContext(c).Info("API key rotated", logging.APIKey(accountID), slog.String("new_api_key", logging.MaskAPIKey(apiKey)), slog.Time("expires_at", expiresAt))
+
+		c.JSON(http.StatusCreated, RotatedAPIKey{apiKey, expiresAt})
+	}
+}
@@ -0,0 +1,240 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Most buckets a single query can cover, and most rows it can return
+const (
+	maxQueryBuckets = 10_000
+	maxQueryRows    = 100_000
+)
+
+var queryBucketSizes = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// Columns requests can be grouped by
+var queryGroupColumns = map[string]string{
+	"path":     "path",
+	"method":   "method",
+	"status":   "status",
+	"hostname": "COALESCE(hostname, '')",
+	"location": "COALESCE(location, '')",
+	"user_id":  "COALESCE(user_id, '')",
+}
+
+// Metrics computed for each bucket and group, with response times in
+// milliseconds. Requests with a 4xx or 5xx status count as errors.
+var queryMetricColumns = map[string]string{
+	"count":        "count(*)::bigint",
+	"error_rate":   "(count(*) FILTER (WHERE status >= 400))::float8 / count(*)",
+	"avg_latency":  "avg(response_time)::float8",
+	"p50_latency":  "percentile_cont(0.5) WITHIN GROUP (ORDER BY response_time)",
+	"p95_latency":  "percentile_cont(0.95) WITHIN GROUP (ORDER BY response_time)",
+	"p99_latency":  "percentile_cont(0.99) WITHIN GROUP (ORDER BY response_time)",
+	"unique_users": "count(DISTINCT user_id)::bigint",
+}
+
+type TimeSeriesQuery struct {
+	from        time.Time
+	to          time.Time
+	bucket      string
+	groupBy     []string
+	metrics     []string
+	hostname    string
+	excludeBots bool
+}
+
+type TimeSeriesData struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Bucket  string           `json:"bucket"`
+	GroupBy []string         `json:"group_by"`
+	Metrics []string         `json:"metrics"`
+	Series  []map[string]any `json:"series"`
+}
+
+// Splits a comma-separated list, rejecting unknown or repeated names
+func parseQueryList(param string, value string, allowed map[string]string) ([]string, error) {
+	names := make([]string, 0)
+	if value == "" {
+		return names, nil
+	}
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := allowed[name]; !ok || seen[name] {
+			return nil, fmt.Errorf("Invalid %s %q.", param, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func getTimeSeriesQuery(c *gin.Context, now time.Time) (TimeSeriesQuery, error) {
+	query := TimeSeriesQuery{
+		bucket:      c.DefaultQuery("bucket", "hour"),
+		excludeBots: c.Query("excludeBots") == "true",
+	}
+	bucketSize, ok := queryBucketSizes[query.bucket]
+	if !ok {
+		return query, errors.New("Invalid bucket, expected minute, hour or day.")
+	}
+
+	// Defaults to the last day
+	query.to = now.UTC()
+	if to := c.Query("to"); to != "" {
+		query.to = parseQueryDateTime(to)
+		if query.to.IsZero() {
+			return query, errors.New("Invalid to, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS.")
+		}
+	}
+	query.from = query.to.Add(-24 * time.Hour)
+	if from := c.Query("from"); from != "" {
+		query.from = parseQueryDateTime(from)
+		if query.from.IsZero() {
+			return query, errors.New("Invalid from, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS.")
+		}
+	}
+	if !query.from.Before(query.to) {
+		return query, errors.New("Invalid time range, from must be before to.")
+	}
+	if query.to.Sub(query.from)/bucketSize > maxQueryBuckets {
+		return query, fmt.Errorf("Time range too long, at most %d buckets can be returned.", maxQueryBuckets)
+	}
+
+	var err error
+	query.groupBy, err = parseQueryList("groupBy", c.Query("groupBy"), queryGroupColumns)
+	if err != nil {
+		return query, err
+	}
+	query.metrics, err = parseQueryList("metric", c.DefaultQuery("metrics", "count"), queryMetricColumns)
+	if err != nil {
+		return query, err
+	}
+	if len(query.metrics) == 0 {
+		return query, errors.New("At least one metric is required.")
+	}
+
+	query.hostname, err = database.NormaliseHostname(c.Query("hostname"))
+	if err != nil {
+		return query, errors.New("Invalid hostname.")
+	}
+	return query, nil
+}
+
+// Builds a query returning a row per bucket and group, with the bucket start,
+// then the group columns, then the metrics. Only whitelisted column
+// expressions are written into the query.
+func buildTimeSeriesQuery(apiKey string, q TimeSeriesQuery) (string, []any) {
+	arguments := []any{apiKey, q.from, q.to, q.bucket}
+	columns := []string{"date_trunc($4, created_at, 'UTC') AS bucket"}
+	groups := []string{"1"}
+	for i, name := range q.groupBy {
+		columns = append(columns, queryGroupColumns[name])
+		groups = append(groups, fmt.Sprint(i+2))
+	}
+	for _, name := range q.metrics {
+		columns = append(columns, queryMetricColumns[name])
+	}
+
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	query.WriteString(strings.Join(columns, ", "))
+	query.WriteString(" FROM requests WHERE api_key = $1 AND created_at >= $2 AND created_at < $3")
+	if q.hostname != "" {
+		arguments = append(arguments, q.hostname)
+		query.WriteString(fmt.Sprintf(" AND hostname = $%d", len(arguments)))
+	}
+	if q.excludeBots {
+		query.WriteString(" AND NOT is_bot")
+	}
+	query.WriteString(" GROUP BY ")
+	query.WriteString(strings.Join(groups, ", "))
+	query.WriteString(" ORDER BY ")
+	query.WriteString(strings.Join(groups, ", "))
+	// One extra row reveals when the limit has been exceeded
+	query.WriteString(fmt.Sprintf(" LIMIT %d;", maxQueryRows+1))
+	return query.String(), arguments
+}
+
+func getTimeSeriesHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeRead)
+		if !ok {
+			return
+		}
+
+		q, err := getTimeSeriesQuery(c, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+			return
+		}
+
+		logging.FromContext(c).Info("Query access", logging.APIKey(apiKey), slog.String("bucket", q.bucket))
+
+		query, arguments := buildTimeSeriesQuery(apiKey, q)
+		rows, err := db.Query(c.Request.Context(), query, arguments...)
+		if err != nil {
+			logDBError(c, "Time series query failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Query failed."})
+			return
+		}
+		defer rows.Close()
+
+		fields := append([]string{"bucket"}, q.groupBy...)
+		fields = append(fields, q.metrics...)
+		series := make([]map[string]any, 0)
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				logDBError(c, "Time series scan failed", logging.APIKey(apiKey), logging.Err(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Query failed."})
+				return
+			}
+			row := make(map[string]any, len(fields))
+			for i, field := range fields {
+				row[field] = values[i]
+			}
+			series = append(series, row)
+		}
+		if err := rows.Err(); err != nil {
+			logDBError(c, "Time series query failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Query failed."})
+			return
+		}
+		if len(series) > maxQueryRows {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Too many results, narrow the time range or group by fewer dimensions."})
+			return
+		}
+
+		c.JSON(http.StatusOK, TimeSeriesData{
+			From:    q.from,
+			To:      q.to,
+			Bucket:  q.bucket,
+			GroupBy: q.groupBy,
+			Metrics: q.metrics,
+			Series:  series,
+		})
+		logging.FromContext(c).Info("Query access successful", logging.APIKey(apiKey), slog.Int("rows", len(series)))
+
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "User last access update failed", logging.APIKey(apiKey), logging.Err(err))
+		}
+	}
+}
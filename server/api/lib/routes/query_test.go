@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(target string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c
+}
+
+func TestGetTimeSeriesQuery(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		target string
+		valid  bool
+	}{
+		{"/api/query", true},
+		{"/api/query?bucket=day&from=2023-12-01&to=2024-01-01&groupBy=path,status&metrics=count,p95_latency&excludeBots=true", true},
+		{"/api/query?bucket=week", false},
+		{"/api/query?from=yesterday", false},
+		{"/api/query?from=2024-01-02&to=2024-01-01", false},
+		{"/api/query?bucket=minute&from=2023-01-01&to=2024-01-01", false}, // Too many buckets
+		{"/api/query?groupBy=path,path", false},
+		{"/api/query?groupBy=api_key", false},
+		{"/api/query?metrics=count%3BDROP%20TABLE%20requests", false},
+		{"/api/query?metrics=", false},
+		{"/api/query?hostname=bad%20host", false},
+	}
+	for _, test := range tests {
+		_, err := getTimeSeriesQuery(testContext(test.target), now)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.target, err, test.valid)
+		}
+	}
+
+	q, err := getTimeSeriesQuery(testContext("/api/query"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.to.Equal(now) || !q.from.Equal(now.Add(-24*time.Hour)) || q.bucket != "hour" {
+		t.Errorf("got from %s to %s by %s, expected the last day by hour", q.from, q.to, q.bucket)
+	}
+	if len(q.metrics) != 1 || q.metrics[0] != "count" {
+		t.Errorf("got metrics %v, expected [count]", q.metrics)
+	}
+}
+
+func TestBuildTimeSeriesQuery(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := TimeSeriesQuery{
+		from:        from,
+		to:          from.Add(24 * time.Hour),
+		bucket:      "hour",
+		groupBy:     []string{"status", "hostname"},
+		metrics:     []string{"count", "error_rate"},
+		hostname:    "example.com",
+		excludeBots: true,
+	}
+	query, arguments := buildTimeSeriesQuery("key", q)
+
+	expected := "SELECT date_trunc($4, created_at, 'UTC') AS bucket, status, COALESCE(hostname, ''), count(*)::bigint, " +
+		"(count(*) FILTER (WHERE status >= 400))::float8 / count(*) FROM requests " +
+		"WHERE api_key = $1 AND created_at >= $2 AND created_at < $3 AND hostname = $5 AND NOT is_bot " +
+		"GROUP BY 1, 2, 3 ORDER BY 1, 2, 3 LIMIT 100001;"
+	if query != expected {
+		t.Errorf("got query\n%s\nexpected\n%s", query, expected)
+	}
+	if len(arguments) != 5 || arguments[0] != "key" || arguments[3] != "hour" || arguments[4] != "example.com" {
+		t.Errorf("unexpected arguments %v", arguments)
+	}
+
+	// Without a hostname, no further placeholders are used
+	q.hostname = ""
+	q.groupBy = nil
+	query, arguments = buildTimeSeriesQuery("key", q)
+	if len(arguments) != 4 || strings.Contains(query, "$5") || !strings.Contains(query, "GROUP BY 1 ORDER BY 1 ") {
+		t.Errorf("unexpected query %s with arguments %v", query, arguments)
+	}
+}
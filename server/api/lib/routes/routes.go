@@ -5,8 +5,13 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
@@ -14,69 +19,56 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v5"
-	"github.com/tom-draper/api-analytics/server/api/lib/log"
-	"github.com/tom-draper/api-analytics/server/api/lib/env"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/api/lib/live"
+	"github.com/tom-draper/api-analytics/server/api/lib/metrics"
 	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
 )
 
-func genAPIKey(c *gin.Context) {
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("API key generation failed - %s", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "API key generation failed."})
-		return
-	}
-	defer connection.Close(context.Background())
+func genAPIKeyHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, err := database.CreateAccount(c.Request.Context(), db)
+		if err != nil {
+			logDBError(c, "API key generation failed", logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "API key generation failed."})
+			return
+		}
 
-	// Fetch all API request data associated with this account
-	query := "INSERT INTO users (api_key, user_id, created_at, last_accessed) VALUES (gen_random_uuid(), gen_random_uuid(), NOW(), NOW()) RETURNING api_key;"
+		logging.FromContext(c).Info("API key generation successful", logging.APIKey(apiKey))
 
-	var apiKey string
-	err = connection.QueryRow(context.Background(), query).Scan(&apiKey)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("API key generation failed - %s", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "API key generation failed."})
-		return
+		// Return API key
+		c.JSON(http.StatusOK, apiKey)
 	}
-
-	log.LogToFile(fmt.Sprintf("key=%s: API key generation successful", apiKey))
-
-	// Return API key
-	c.JSON(http.StatusOK, apiKey)
 }
 
-func getUserID(c *gin.Context) {
-	// Get user ID associated with API key
-	var apiKey string = c.Param("apiKey")
-	if apiKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
-	}
+func getUserIDHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// User IDs grant full dashboard access so are only issued for admin
+		// tokens
+		apiKey, ok := authenticateRequest(c, db, c.Param("apiKey"), database.ScopeAdmin)
+		if !ok {
+			return
+		}
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: User ID fetch failed - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
-	}
-	defer connection.Close(context.Background())
+		// User IDs are stored hashed, so a new one is issued each time
+		userID, _, err := database.CreateToken(c.Request.Context(), db, apiKey, database.DashboardTokenName, database.ScopeDashboard, nil)
+		if err != nil {
+			logDBError(c, "Failed to issue user ID", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Internal server error."})
+			return
+		}
 
-	// Fetch user ID corresponding with API key
-	var userID string
-	query := "SELECT user_id FROM users WHERE api_key = $1;"
-	err = connection.QueryRow(context.Background(), query, apiKey).Scan(&userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
+		// Return user ID
+		c.JSON(http.StatusOK, userID)
 	}
-
-	// Return user ID
-	c.JSON(http.StatusOK, userID)
 }
 
 type DashboardData struct {
 	UserAgents UserAgentsLookup `json:"user_agents"`
 	Requests   [][10]any        `json:"requests"`
+	NextCursor string           `json:"next_cursor"` // Empty if no requests have been returned yet
+	HasMore    bool             `json:"has_more"`
 }
 
 type UserAgentsLookup map[int]string
@@ -92,79 +84,63 @@ type DashboardRequestRow struct {
 	Location     *string     `json:"location"` // Nullable
 	UserID       *string     `json:"user_id"`  // Nullable, custom user identifier field specific to each API service
 	CreatedAt    time.Time   `json:"created_at"`
+	RequestID    int64       `json:"-"`
 }
 
-func getMaxLoad() int {
-	return env.GetIntegerEnvVariable("MAX_LOAD", 1_000_000)
-}
-
-func getPageSize() int {
-	return env.GetIntegerEnvVariable("PAGE_SIZE", 250_000)
-}
-
-func getRequestsHandler() gin.HandlerFunc {
-	var pageSize int = getPageSize()
-	var maxLoad int = getMaxLoad()
-
+func getRequestsHandler(db *pgxpool.Pool, pageSize int, maxLoad int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := c.Param("userID")
 		if userID == "" {
-			log.LogToFile("User ID empty")
+			logging.FromContext(c).Warn("User ID empty")
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid targetPage ID."})
 			return
 		}
 
 		var err error
 		pageQuery := c.Query("page")
-		log.LogToFile(pageQuery)
 		targetPage := 1
 		if pageQuery != "" {
 			targetPage, err = strconv.Atoi(pageQuery)
 			if err != nil {
-				log.LogToFile(fmt.Sprintf("Failed to parse page number '%s' from query", pageQuery))
+				logging.FromContext(c).Warn("Failed to parse page number from query", slog.String("page", pageQuery))
 			}
 		}
 
-		var message string
-		if targetPage == 0 {
-			message = fmt.Sprintf("id=%s: Dashboard access", userID)
-		} else {
-			message = fmt.Sprintf("id=%s: Dashboard page %d access", userID, targetPage)
-		}
-		log.LogToFile(message)
+		excludeBots := c.Query("excludeBots") == "true"
+		compactFormat := c.Query("compact") == "true"
 
-		connection, err := database.NewConnection()
+		// A cursor fetches the single page after it
+		cursor, err := getCursorQuery(c.GetQuery("cursor"))
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("id=%s: Dashboard access failed - %s", userID, err.Error()))
-			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusInternalServerError, "message": "Internal server error."})
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
 			return
 		}
-		defer connection.Close(context.Background())
+
+		logging.FromContext(c).Info("Dashboard access", logging.UserID(userID), slog.Int("page", targetPage))
 
 		// Fetch API key corresponding with user ID
-		apiKey, err := getUserAPIKey(connection, userID)
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, userID)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("id=%s: No API key associated with user ID - %s", userID, err.Error()))
+			logging.FromContext(c).Warn("No API key associated with user ID", logging.UserID(userID), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 			return
 		}
 
-		requests := [][10]any{}
-		userAgentIDs := make(map[int]struct{})
-		var currentPage int
-		if targetPage == 0 {
-			currentPage = 1
-		} else {
-			currentPage = targetPage
-		}
+		requests := newDashboardRequests(compactFormat)
 
+		// All pages are read by cursor unless a page number is given
+		after := cursor
+		if after == nil && targetPage == 0 {
+			after = &Cursor{}
+		}
+		var page *pageReader
 		for {
+			page = &pageReader{limit: pageSize}
 			// Note: table joins currently avoided due to memory limitations
-			query := "SELECT ip_address, path, hostname, user_agent_id, method, response_time, status, location, user_id, created_at FROM requests WHERE api_key = $1 ORDER BY created_at LIMIT $2 OFFSET $3;"
-			offset := (currentPage - 1) * pageSize
-			rows, err := connection.Query(context.Background(), query, apiKey, pageSize, offset)
+			query, arguments := buildDashboardQuery(apiKey, excludeBots, after, targetPage, pageSize)
+			rows, err := db.Query(c.Request.Context(), query, arguments...)
 			if err != nil {
-				log.LogToFile(fmt.Sprintf("key=%s: Invalid API key - %s", apiKey, err.Error()))
+				logging.FromContext(c).Warn("Invalid API key", logging.APIKey(apiKey), logging.Err(err))
 				c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 				return
 			}
@@ -172,62 +148,45 @@ func getRequestsHandler() gin.HandlerFunc {
 			// First value in the list will hold column names
 			request := new(DashboardRequestRow)
 			var count int
-			var skipped int
-			for rows.Next() {
-				err = rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt)
+			for page.Next(rows) {
+				err = rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt, &request.RequestID)
 				if err != nil {
-					skipped++
 					continue
 				}
-
-				var ip string
-				if request.IPAddress.IPNet != nil {
-					ip = request.IPAddress.IPNet.IP.String()
-				}
-				hostname := getNullableString(request.Hostname)
-				location := getNullableString(request.Location)
-				userID := getNullableString(request.UserID)
-				requests = append(requests, [10]any{ip, request.Path, hostname, request.UserAgent, request.Method, request.ResponseTime, request.Status, location, userID, request.CreatedAt})
-				if request.UserAgent != nil {
-					if _, ok := userAgentIDs[*request.UserAgent]; !ok {
-						userAgentIDs[*request.UserAgent] = struct{}{}
-					}
-				}
+				page.Record(request.CreatedAt, request.RequestID)
+				requests.add(request)
 
 				count++
 
 				// Finish page read early if reached data limit
-				if len(requests) >= maxLoad {
+				if requests.len() >= maxLoad {
+					page.hasMore = true
 					break
 				}
 			}
 			rows.Close()
 
-			currentPage++
-
 			// Finish data read if only needed one page, last page read was the final page available, or reached data limit
-			if targetPage != 0 || count+skipped < pageSize || count >= maxLoad {
+			if targetPage != 0 || cursor != nil || !page.hasMore || requests.len() >= maxLoad {
 				break
 			}
+			after = &page.last
 		}
 
 		// Convert user agent IDs to names in-place
-		userAgents, err := getUserAgents(connection, userAgentIDs)
+		userAgents, err := getUserAgents(c.Request.Context(), db, requests.userAgentIDs)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: User agent lookup failed - %s", apiKey, err.Error()))
+			logDBError(c, "User agent lookup failed", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "User agent lookup failed."})
 			return
 		}
 
-		body := DashboardData{
-			UserAgents: userAgents,
-			Requests:   requests,
-		}
+		body := requests.body(userAgents, page.NextCursor(after), page.hasMore)
 
 		// Compress requests with gzip
 		gzipOutput, err := compressJSON(body)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: Compression failed - %s", apiKey, err.Error()))
+			logging.FromContext(c).Error("Compression failed", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusInternalServerError, "message": "Compression failed."})
 			return
 		}
@@ -239,108 +198,97 @@ func getRequestsHandler() gin.HandlerFunc {
 		c.Data(http.StatusOK, "gzip", gzipOutput)
 
 		// Record user dashboard access
-		if targetPage == 0 {
-			message = fmt.Sprintf("key=%s: Dashboard access successful (%d)", apiKey, len(requests))
-		} else {
-			message = fmt.Sprintf("key=%s: Dashboard page %d access successful (%d)", apiKey, targetPage, len(requests))
-		}
-		log.LogToFile(message)
+		logging.FromContext(c).Info("Dashboard access successful", logging.APIKey(apiKey), slog.Int("page", targetPage), slog.Int("requests", requests.len()))
+		metrics.RowsReturned.WithLabelValues("dashboard").Add(float64(requests.len()))
 
-		err = updateLastAccessed(connection, apiKey)
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: User last access update failed - %s", apiKey, err.Error()))
+			logDBError(c, "User last access update failed", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 			return
 		}
 	}
 }
 
-func getPaginatedRequestsHandler() gin.HandlerFunc {
-	var pageSize int = getPageSize()
+// Selects a page of an account's requests, either after a cursor or by page
+// number, fetching one extra row to reveal whether more follow
+func buildDashboardQuery(apiKey string, excludeBots bool, after *Cursor, page int, pageSize int) (string, []any) {
+	var query strings.Builder
+	query.WriteString("SELECT ip_address, path, hostname, user_agent_id, method, response_time, status, location, user_id, created_at, request_id FROM requests WHERE api_key = $1")
+	arguments := []any{apiKey}
+	if excludeBots {
+		query.WriteString(" AND NOT is_bot")
+	}
+	if after != nil {
+		arguments = writeCursorCondition(&query, arguments, *after, "")
+		query.WriteString(fmt.Sprintf(" ORDER BY created_at, request_id LIMIT %d;", pageSize+1))
+	} else {
+		query.WriteString(fmt.Sprintf(" ORDER BY created_at, request_id LIMIT %d OFFSET %d;", pageSize+1, (page-1)*pageSize))
+	}
+	return query.String(), arguments
+}
 
+func getPaginatedRequestsHandler(db *pgxpool.Pool, pageSize int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var userID string = c.Param("userID")
 		if userID == "" {
-			log.LogToFile("User ID empty")
+			logging.FromContext(c).Warn("User ID empty")
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 			return
 		}
 
 		page, err := strconv.Atoi(c.Param("page"))
 		if err != nil || page == 0 {
-			log.LogToFile("Invalid page number")
+			logging.FromContext(c).Warn("Invalid page number")
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid page number."})
 			return
 		}
 
-		log.LogToFile(fmt.Sprintf("id=%s: Dashboard page %d access", userID, page))
-
-		connection, err := database.NewConnection()
-		if err != nil {
-			log.LogToFile(fmt.Sprintf("id=%s: Dashboard page %d access failed - %s", userID, page, err.Error()))
-			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-			return
-		}
-		defer connection.Close(context.Background())
+		logging.FromContext(c).Info("Dashboard access", logging.UserID(userID), slog.Int("page", page))
 
 		// Fetch API key corresponding with user ID
-		apiKey, err := getUserAPIKey(connection, userID)
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, userID)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("id=%s: No API key associated with user ID - %s", userID, err.Error()))
+			logging.FromContext(c).Warn("No API key associated with user ID", logging.UserID(userID), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 			return
 		}
 
-		requests := [][10]any{}
-		userAgentIDs := make(map[int]struct{})
+		requests := newDashboardRequests(c.Query("compact") == "true")
 
-		query := "SELECT ip_address, path, hostname, user_agent_id, method, response_time, status, location, user_id, created_at FROM requests WHERE api_key = $1 ORDER BY created_at LIMIT $2 OFFSET $3;"
-		rows, err := connection.Query(context.Background(), query, apiKey, pageSize, (page-1)*pageSize)
+		// Fetched by page number, but returned with a cursor to continue from
+		reader := &pageReader{limit: pageSize}
+		query, arguments := buildDashboardQuery(apiKey, c.Query("excludeBots") == "true", nil, page, pageSize)
+		rows, err := db.Query(c.Request.Context(), query, arguments...)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: Invalid API key - %s", apiKey, err.Error()))
+			logging.FromContext(c).Warn("Invalid API key", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 			return
 		}
 		request := new(DashboardRequestRow) // Reuseable request struct
-		for rows.Next() {
-			err = rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt)
+		for reader.Next(rows) {
+			err = rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt, &request.RequestID)
 			if err != nil {
 				continue
 			}
-
-			var ip string
-			if request.IPAddress.IPNet != nil {
-				ip = request.IPAddress.IPNet.IP.String()
-			}
-			hostname := getNullableString(request.Hostname)
-			location := getNullableString(request.Location)
-			userID := getNullableString(request.UserID)
-			requests = append(requests, [10]any{ip, request.Path, hostname, request.UserAgent, request.Method, request.ResponseTime, request.Status, location, userID, request.CreatedAt})
-			if request.UserAgent != nil {
-				if _, ok := userAgentIDs[*request.UserAgent]; !ok {
-					userAgentIDs[*request.UserAgent] = struct{}{}
-				}
-			}
+			reader.Record(request.CreatedAt, request.RequestID)
+			requests.add(request)
 		}
 		rows.Close()
 
 		// Convert user agent IDs to names
-		userAgents, err := getUserAgents(connection, userAgentIDs)
+		userAgents, err := getUserAgents(c.Request.Context(), db, requests.userAgentIDs)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: User agent lookup failed - %s", apiKey, err.Error()))
+			logDBError(c, "User agent lookup failed", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "User agent lookup failed."})
 			return
 		}
 
-		// Store user agents in separate lookup table to reduce data transfer size
-		body := DashboardData{
-			UserAgents: userAgents,
-			Requests:   requests,
-		}
+		body := requests.body(userAgents, reader.NextCursor(nil), reader.hasMore)
 
 		gzipOutput, err := compressJSON(body)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: Compression failed - %s", apiKey, err.Error()))
+			logging.FromContext(c).Error("Compression failed", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusInternalServerError, "message": "Compression failed."})
 			return
 		}
@@ -351,27 +299,26 @@ func getPaginatedRequestsHandler() gin.HandlerFunc {
 		c.Writer.Header().Set("Content-Type", "application/json")
 		c.Data(http.StatusOK, "gzip", gzipOutput)
 
-		log.LogToFile(fmt.Sprintf("key=%s: Dashboard page %d access successful (%d)", apiKey, page, len(requests)))
+		logging.FromContext(c).Info("Dashboard access successful", logging.APIKey(apiKey), slog.Int("page", page), slog.Int("requests", requests.len()))
+		metrics.RowsReturned.WithLabelValues("dashboard").Add(float64(requests.len()))
 
 		// Record user dashboard access
-		err = updateLastAccessed(connection, apiKey)
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
 		if err != nil {
-			log.LogToFile(fmt.Sprintf("key=%s: User last access update failed - %s", apiKey, err.Error()))
+			logDBError(c, "User last access update failed", logging.APIKey(apiKey), logging.Err(err))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
 			return
 		}
 	}
 }
 
-func getUserAPIKey(connection *pgx.Conn, userID string) (string, error) {
-	// Avoiding table join due to memory limitations
-	var apiKey string
-	query := "SELECT api_key FROM users WHERE user_id = $1;"
-	err := connection.QueryRow(context.Background(), query, userID).Scan(&apiKey)
-	return apiKey, err
+// Fetches the API key of a user ID's account. Dashboard share tokens are
+// accepted in place of the user ID.
+func getUserAPIKey(ctx context.Context, db database.Querier, userID string) (string, error) {
+	return resolveAPIKey(ctx, db, userID, database.ScopeDashboard)
 }
 
-func getUserAgents(connection *pgx.Conn, userAgentIDs map[int]struct{}) (map[int]string, error) {
+func getUserAgents(ctx context.Context, db database.Querier, userAgentIDs map[int]struct{}) (map[int]string, error) {
 	// Convert user agent int IDs to equivalent strings
 	userAgents := make(map[int]string)
 	if len(userAgentIDs) == 0 {
@@ -391,7 +338,7 @@ func getUserAgents(connection *pgx.Conn, userAgentIDs map[int]struct{}) (map[int
 		i++
 	}
 	userAgentsQuery.WriteString(");")
-	rows, err := connection.Query(context.Background(), userAgentsQuery.String(), arguments...)
+	rows, err := db.Query(ctx, userAgentsQuery.String(), arguments...)
 	if err != nil {
 		return userAgents, err
 	}
@@ -435,103 +382,115 @@ func compressJSON(data any) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func updateLastAccessedByUserID(connection *pgx.Conn, userID string) error {
-	query := "UPDATE users SET last_accessed = NOW() WHERE user_id = $1;"
-	_, err := connection.Exec(context.Background(), query, userID)
-	return err
-}
-
-func updateLastAccessed(connection *pgx.Conn, apiKey string) error {
+func updateLastAccessed(ctx context.Context, db database.Querier, apiKey string) error {
 	query := "UPDATE users SET last_accessed = NOW() WHERE api_key = $1;"
-	_, err := connection.Exec(context.Background(), query, apiKey)
+	_, err := db.Exec(ctx, query, apiKey)
 	return err
 }
 
-func buildRequestDataCompact(rows pgx.Rows, cols [10]any) [][10]any {
-	// First value in list holds column names
-	requests := [][10]any{cols}
-	var request DashboardRequestRow
-	for rows.Next() {
-		err := rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt)
-		if err == nil {
-			requests = append(requests, [10]any{request.IPAddress, request.Path, request.Hostname, request.UserAgent, request.Method, request.ResponseTime, request.Status, request.Location, request.UserID, request.CreatedAt})
-		}
-	}
-	return requests
-}
-
 type DataFetchQueries struct {
-	page      int
-	compact   bool
-	date      time.Time
-	dateFrom  time.Time
-	dateTo    time.Time
-	hostname  string
-	ipAddress string
-	location  string
-	status    int
-	userID    string
+	page            int
+	cursor          *Cursor // Nil to fetch by page number
+	compact         bool    // Columnar format of the compact package
+	date            time.Time
+	dateFrom        time.Time
+	dateTo          time.Time
+	hostname        filter[string]
+	ipAddress       filter[netip.Prefix]
+	location        filter[string]
+	status          filter[statusRange]
+	method          filter[int16]
+	path            filter[string] // LIKE patterns
+	minResponseTime int            // Milliseconds, or -1 for no bound
+	maxResponseTime int            // Milliseconds, or -1 for no bound
+	userID          filter[string]
+	browser         filter[string]
+	os              filter[string]
+	deviceType      filter[string]
+	bot             *bool // Nil to include both bots and other clients
+	excludeBots     bool  // Exclude requests identified as bots by user agent or IP address
 }
 
-func getData(c *gin.Context) {
+// Reads the API key sent with data requests
+func getAPIKeyHeader(c *gin.Context) string {
 	apiKey := c.GetHeader("X-AUTH-TOKEN")
 	if apiKey == "" {
 		// Check old (deprecated) identifier
 		apiKey = c.GetHeader("API-Key")
-		if apiKey == "" {
-			log.LogToFile("API key empty")
-			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-			return
-		}
 	}
+	return apiKey
+}
 
-	log.LogToFile(fmt.Sprintf("key=%s: Data access", apiKey))
+func getDataHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeRead)
+		if !ok {
+			return
+		}
 
-	// Get any queries from url
-	queries := getQueriesFromRequest(c)
+		logging.FromContext(c).Info("Data access", logging.APIKey(apiKey))
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Data access failed - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
-	}
-	defer connection.Close(context.Background())
+		// Get any queries from url
+		queries, err := getQueriesFromRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": err.Error()})
+			return
+		}
 
-	// Fetch all API request data associated with this account
-	query, arguments := buildDataFetchQuery(apiKey, queries)
-	rows, err := connection.Query(context.Background(), query, arguments...)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Queries failed - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
-	}
+		// Fetch all API request data associated with this account
+		query, arguments := buildDataFetchQuery(apiKey, queries)
+		rows, err := db.Query(c.Request.Context(), query, arguments...)
+		if err != nil {
+			logDBError(c, "Queries failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
+			return
+		}
 
-	// Read data into list of objects to return
-	if queries.compact {
-		cols := [10]any{"ip_address", "path", "hostname", "user_agent", "method", "response_time", "status", "location", "user_id", "created_at"}
-		requests := buildRequestDataCompact(rows, cols)
-		log.LogToFile(fmt.Sprintf("key=%s: Data access successful (%d)", apiKey, len(requests)-1))
-		c.JSON(http.StatusOK, requests)
-	} else {
-		requests := buildRequestData(rows)
-		log.LogToFile(fmt.Sprintf("key=%s: Data access successful (%d)", apiKey, len(requests)))
-		c.JSON(http.StatusOK, requests)
-	}
+		// Read data into list of objects to return
+		page := &pageReader{limit: dataPageSize}
+		var requests any
+		var count int
+		if queries.compact {
+			compact := buildRequestDataCompact(rows, page)
+			requests, count = compact, compact.Count
+		} else {
+			full := buildRequestData(rows, page)
+			requests, count = full, len(full)
+		}
+		logging.FromContext(c).Info("Data access successful", logging.APIKey(apiKey), slog.Int("requests", count))
+		metrics.RowsReturned.WithLabelValues("data").Add(float64(count))
+
+		// Pages fetched by cursor are wrapped with the cursor of the next page,
+		// while pages fetched by number are returned alone as before
+		if queries.cursor != nil {
+			c.JSON(http.StatusOK, RequestDataPage{
+				Requests:   requests,
+				NextCursor: page.NextCursor(queries.cursor),
+				HasMore:    page.hasMore,
+			})
+		} else {
+			c.JSON(http.StatusOK, requests)
+		}
 
-	rows.Close()
+		rows.Close()
 
-	err = updateLastAccessed(connection, apiKey)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: User last access update failed - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "User last access update failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
+			return
+		}
 	}
 }
 
-func buildDataFetchQuery(apiKey string, queries DataFetchQueries) (string, []any) {
-	var query strings.Builder
-	query.WriteString("SELECT r.ip_address, r.path, r.hostname, u.user_agent, r.method, r.response_time, r.status, r.location, r.user_id, r.created_at FROM requests r JOIN user_agents u ON r.user_agent_id = u.id WHERE api_key = $1")
+// Requests returned per page of data
+const dataPageSize = 50_000
+
+// Selects an account's requests matching the filters of queries, to be
+// followed by ordering and paging
+func buildDataSelect(apiKey string, queries DataFetchQueries) (*strings.Builder, []any) {
+	query := new(strings.Builder)
+	query.WriteString("SELECT r.ip_address, r.path, r.hostname, u.user_agent, r.method, r.response_time, r.status, r.location, r.user_id, r.created_at, u.browser, u.browser_version, u.os, u.os_version, u.device_type, r.is_bot, u.bot_name, r.request_id FROM requests r JOIN user_agents u ON r.user_agent_id = u.id WHERE api_key = $1")
 
 	arguments := []any{apiKey}
 
@@ -542,85 +501,171 @@ func buildDataFetchQuery(apiKey string, queries DataFetchQueries) (string, []any
 	} else {
 		if !queries.dateFrom.IsZero() && database.ValidDate(queries.dateFrom) {
 			query.WriteString(fmt.Sprintf(" and r.created_at >= $%d", len(arguments)+1))
-			arguments = append(arguments, queries.dateFrom.Format("2006-01-02"))
+			arguments = append(arguments, queries.dateFrom)
 		}
 		if !queries.dateTo.IsZero() && database.ValidDate(queries.dateTo) {
 			query.WriteString(fmt.Sprintf(" and r.created_at <= $%d", len(arguments)+1))
-			arguments = append(arguments, queries.dateTo.Format("2006-01-02"))
+			arguments = append(arguments, queries.dateTo)
 		}
 	}
 
-	if queries.ipAddress != "" && database.ValidIPAddress(queries.ipAddress) {
-		query.WriteString(fmt.Sprintf(" and r.ip_address = $%d", len(arguments)+1))
-		arguments = append(arguments, queries.ipAddress)
-	}
+	arguments = writeFilter(query, arguments, queries.ipAddress, "r.ip_address <<= ANY(%s)", "(r.ip_address IS NULL OR NOT r.ip_address <<= ANY(%s))")
+	arguments = writeNullableEqualFilter(query, arguments, queries.location, "r.location")
+	arguments = writeStatusFilter(query, arguments, queries.status)
+	arguments = writeEqualFilter(query, arguments, queries.method, "r.method")
+	arguments = writeFilter(query, arguments, queries.path, "r.path LIKE ANY(%s)", "NOT r.path LIKE ANY(%s)")
 
-	if queries.location != "" && database.ValidLocation(queries.location) {
-		query.WriteString(fmt.Sprintf(" and r.location = $%d", len(arguments)+1))
-		arguments = append(arguments, queries.location)
+	if queries.minResponseTime >= 0 {
+		query.WriteString(fmt.Sprintf(" and r.response_time >= $%d", len(arguments)+1))
+		arguments = append(arguments, queries.minResponseTime)
 	}
-
-	if queries.status != 0 && database.ValidStatus(queries.status) {
-		query.WriteString(fmt.Sprintf(" and r.status = $%d", len(arguments)+1))
-		arguments = append(arguments, queries.status)
+	if queries.maxResponseTime >= 0 {
+		query.WriteString(fmt.Sprintf(" and r.response_time <= $%d", len(arguments)+1))
+		arguments = append(arguments, queries.maxResponseTime)
 	}
 
-	if queries.hostname != "" && database.ValidString(queries.hostname) {
-		query.WriteString(fmt.Sprintf(" and r.hostname = $%d", len(arguments)+1))
-		arguments = append(arguments, queries.hostname)
+	// Hostnames are matched as they were normalised when stored
+	arguments = writeNullableEqualFilter(query, arguments, queries.hostname, "r.hostname")
+	arguments = writeNullableEqualFilter(query, arguments, queries.userID, "r.user_id")
+	arguments = writeNullableEqualFilter(query, arguments, queries.browser, "u.browser")
+	arguments = writeNullableEqualFilter(query, arguments, queries.os, "u.os")
+	arguments = writeNullableEqualFilter(query, arguments, queries.deviceType, "u.device_type")
+
+	// Both bot filters use the classification by user agent or IP address
+	// made when the request was stored
+	if queries.excludeBots {
+		query.WriteString(" and NOT r.is_bot")
 	}
 
-	if queries.userID != "" && database.ValidString(queries.userID) {
-		query.WriteString(fmt.Sprintf(" and r.user_id = $%d", len(arguments)+1))
-		arguments = append(arguments, queries.userID)
+	if queries.bot != nil {
+		query.WriteString(fmt.Sprintf(" and r.is_bot = $%d", len(arguments)+1))
+		arguments = append(arguments, *queries.bot)
 	}
 
-	const pageSize = 50_000
-	offset := (queries.page - 1) * pageSize
-	query.WriteString(fmt.Sprintf(" ORDER BY created_at LIMIT %d OFFSET %d;", pageSize, offset))
+	return query, arguments
+}
+
+func buildDataFetchQuery(apiKey string, queries DataFetchQueries) (string, []any) {
+	query, arguments := buildDataSelect(apiKey, queries)
+
+	if queries.cursor != nil {
+		// One extra row reveals whether more requests follow
+		arguments = writeCursorCondition(query, arguments, *queries.cursor, "r.")
+		query.WriteString(fmt.Sprintf(" ORDER BY r.created_at, r.request_id LIMIT %d;", dataPageSize+1))
+	} else {
+		offset := (queries.page - 1) * dataPageSize
+		query.WriteString(fmt.Sprintf(" ORDER BY r.created_at, r.request_id LIMIT %d OFFSET %d;", dataPageSize, offset))
+	}
 	return query.String(), arguments
 }
 
-func getQueriesFromRequest(c *gin.Context) DataFetchQueries {
-	pageQuery := c.Query("page")
-	compactQuery := c.Query("compact")
-	dateQuery := c.Query("date")
-	dateFromQuery := c.Query("dateFrom")
-	dateToQuery := c.Query("dateTo")
-	hostname := c.Query("hostname")
-	ipAddressQuery := c.Query("ip")
-	locationQuery := c.Query("location")
-	statusQuery := c.Query("status")
-	userIDQuery := c.Query("userID")
-
-	date := parseQueryDate(dateQuery)
-	dateFrom := parseQueryDate(dateFromQuery)
-	dateTo := parseQueryDate(dateToQuery)
-	status, err := strconv.Atoi(statusQuery)
-	if err != nil {
-		status = 0
+func getQueriesFromRequest(c *gin.Context) (DataFetchQueries, error) {
+	queries := DataFetchQueries{
+		page:            1,
+		compact:         c.Query("compact") == "true",
+		minResponseTime: -1,
+		maxResponseTime: -1,
+		excludeBots:     c.Query("excludeBots") == "true",
 	}
-	page := 1
-	if pageQuery != "" {
-		p, err := strconv.Atoi(pageQuery)
-		if err == nil {
-			page = p
+
+	if page := c.Query("page"); page != "" {
+		p, err := strconv.Atoi(page)
+		if err != nil || p < 1 {
+			return queries, errors.New("Invalid page, expected a positive number.")
 		}
+		queries.page = p
 	}
 
-	queries := DataFetchQueries{
-		page,
-		compactQuery == "true",
-		date,
-		dateFrom,
-		dateTo,
-		hostname,
-		ipAddressQuery,
-		locationQuery,
-		status,
-		userIDQuery,
+	cursor, present := c.GetQuery("cursor")
+	if present && c.Query("page") != "" {
+		return queries, errors.New("Use either page or cursor, not both.")
+	}
+	var err error
+	if queries.cursor, err = getCursorQuery(cursor, present); err != nil {
+		return queries, err
+	}
+
+	if date := c.Query("date"); date != "" {
+		queries.date = parseQueryDate(date)
+		if queries.date.IsZero() {
+			return queries, errors.New("Invalid date, expected YYYY-MM-DD.")
+		}
+	}
+	if dateFrom := c.Query("dateFrom"); dateFrom != "" {
+		queries.dateFrom = parseQueryDateTime(dateFrom)
+		if queries.dateFrom.IsZero() {
+			return queries, errors.New("Invalid dateFrom, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS.")
+		}
+	}
+	if dateTo := c.Query("dateTo"); dateTo != "" {
+		queries.dateTo = parseQueryDateTime(dateTo)
+		if queries.dateTo.IsZero() {
+			return queries, errors.New("Invalid dateTo, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS.")
+		}
+	}
+
+	if queries.minResponseTime, err = parseResponseTime(c, "minResponseTime"); err != nil {
+		return queries, err
+	}
+	if queries.maxResponseTime, err = parseResponseTime(c, "maxResponseTime"); err != nil {
+		return queries, err
+	}
+
+	if bot := c.Query("bot"); bot != "" {
+		b, err := strconv.ParseBool(bot)
+		if err != nil {
+			return queries, errors.New("Invalid bot, expected true or false.")
+		}
+		queries.bot = &b
+	}
+
+	if queries.hostname, err = parseFilter(c, "hostname", true, parseHostname); err != nil {
+		return queries, err
+	}
+	if queries.ipAddress, err = parseFilter(c, "ip", true, parseIPPrefix); err != nil {
+		return queries, err
 	}
-	return queries
+	if queries.location, err = parseFilter(c, "location", true, parseLocation); err != nil {
+		return queries, err
+	}
+	if queries.status, err = parseFilter(c, "status", true, parseStatusRange); err != nil {
+		return queries, err
+	}
+	if queries.method, err = parseFilter(c, "method", true, parseMethod); err != nil {
+		return queries, err
+	}
+	if queries.path, err = parseFilter(c, "path", true, parsePathGlob); err != nil {
+		return queries, err
+	}
+	// User IDs may hold commas, so are only given several values by repeating
+	// the parameter
+	if queries.userID, err = parseFilter(c, "userID", false, parseString); err != nil {
+		return queries, err
+	}
+	if queries.browser, err = parseFilter(c, "browser", true, parseString); err != nil {
+		return queries, err
+	}
+	if queries.os, err = parseFilter(c, "os", true, parseString); err != nil {
+		return queries, err
+	}
+	if queries.deviceType, err = parseFilter(c, "deviceType", true, parseString); err != nil {
+		return queries, err
+	}
+	return queries, nil
+}
+
+// Reads an optional response time bound in milliseconds, returning -1 if
+// missing
+func parseResponseTime(c *gin.Context, param string) (int, error) {
+	value := c.Query(param)
+	if value == "" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > math.MaxInt16 {
+		return -1, fmt.Errorf("Invalid %s, expected milliseconds.", param)
+	}
+	return n, nil
 }
 
 func parseQueryDate(date string) time.Time {
@@ -644,6 +689,9 @@ func parseQueryDateTime(date string) time.Time {
 	if d, err := time.Parse("2006-01-02 15:04:05", date); err == nil {
 		return d
 	}
+	if d, err := time.Parse(time.RFC3339, date); err == nil {
+		return d
+	}
 
 	// Try parse date
 	if d, err := time.Parse("2006-01-02", date); err == nil {
@@ -653,16 +701,31 @@ func parseQueryDateTime(date string) time.Time {
 }
 
 type RequestData struct {
-	Hostname     string    `json:"hostname"`
-	IPAddress    string    `json:"ip_address"`
-	Path         string    `json:"path"`
-	UserAgent    string    `json:"user_agent"`
-	Method       int16     `json:"method"`
-	Status       int16     `json:"status"`
-	ResponseTime int16     `json:"response_time"`
-	Location     string    `json:"location"`
-	UserID       string    `json:"user_id"`
-	CreatedAt    time.Time `json:"created_at"`
+	Hostname       string    `json:"hostname"`
+	IPAddress      string    `json:"ip_address"`
+	Path           string    `json:"path"`
+	UserAgent      string    `json:"user_agent"`
+	Method         int16     `json:"method"`
+	Status         int16     `json:"status"`
+	ResponseTime   int16     `json:"response_time"`
+	Location       string    `json:"location"`
+	UserID         string    `json:"user_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Browser        string    `json:"browser"`
+	BrowserVersion string    `json:"browser_version"`
+	OS             string    `json:"os"`
+	OSVersion      string    `json:"os_version"`
+	DeviceType     string    `json:"device_type"`
+	IsBot          bool      `json:"is_bot"`
+	BotName        string    `json:"bot_name"`
+}
+
+// RequestDataPage holds a page of requests fetched by cursor. NextCursor is
+// empty if no requests have been returned yet.
+type RequestDataPage struct {
+	Requests   any    `json:"requests"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
 }
 
 type RequestRow struct {
@@ -676,14 +739,28 @@ type RequestRow struct {
 	Location     *string     `json:"location"`
 	UserID       *string     `json:"user_id"` // Custom user identifier field specific to each API service
 	CreatedAt    time.Time   `json:"created_at"`
+	// Parsed from the user agent, nullable until the user agent is parsed
+	Browser        *string `json:"browser"`
+	BrowserVersion *string `json:"browser_version"`
+	OS             *string `json:"os"`
+	OSVersion      *string `json:"os_version"`
+	DeviceType     *string `json:"device_type"`
+	IsBot          *bool   `json:"is_bot"` // Classified by user agent or IP address when stored
+	BotName        *string `json:"bot_name"`
+	RequestID      int64   `json:"-"`
 }
 
-func buildRequestData(rows pgx.Rows) []RequestData {
+func scanRequestRow(rows pgx.Rows, request *RequestRow) error {
+	return rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt, &request.Browser, &request.BrowserVersion, &request.OS, &request.OSVersion, &request.DeviceType, &request.IsBot, &request.BotName, &request.RequestID)
+}
+
+func buildRequestData(rows pgx.Rows, page *pageReader) []RequestData {
 	requests := make([]RequestData, 0)
 	var request RequestRow
-	for rows.Next() {
-		err := rows.Scan(&request.IPAddress, &request.Path, &request.Hostname, &request.UserAgent, &request.Method, &request.ResponseTime, &request.Status, &request.Location, &request.UserID, &request.CreatedAt)
+	for page.Next(rows) {
+		err := scanRequestRow(rows, &request)
 		if err == nil {
+			page.Record(request.CreatedAt, request.RequestID)
 			var ip string
 			if request.IPAddress.IPNet != nil {
 				ip = request.IPAddress.IPNet.IP.String()
@@ -693,93 +770,46 @@ func buildRequestData(rows pgx.Rows) []RequestData {
 			location := getNullableString(request.Location)
 			userID := getNullableString(request.UserID)
 			requests = append(requests, RequestData{
-				IPAddress:    ip,
-				Path:         request.Path,
-				Hostname:     hostname,
-				UserAgent:    userAgent,
-				Method:       request.Method,
-				Status:       request.Status,
-				ResponseTime: request.ResponseTime,
-				Location:     location,
-				UserID:       userID,
-				CreatedAt:    request.CreatedAt,
+				IPAddress:      ip,
+				Path:           request.Path,
+				Hostname:       hostname,
+				UserAgent:      userAgent,
+				Method:         request.Method,
+				Status:         request.Status,
+				ResponseTime:   request.ResponseTime,
+				Location:       location,
+				UserID:         userID,
+				CreatedAt:      request.CreatedAt,
+				Browser:        getNullableString(request.Browser),
+				BrowserVersion: getNullableString(request.BrowserVersion),
+				OS:             getNullableString(request.OS),
+				OSVersion:      getNullableString(request.OSVersion),
+				DeviceType:     getNullableString(request.DeviceType),
+				IsBot:          request.IsBot != nil && *request.IsBot,
+				BotName:        getNullableString(request.BotName),
 			})
 		}
 	}
 	return requests
 }
 
-func deleteUserRequests(apiKey string, c *gin.Context, connection *pgx.Conn) error {
-	// Delete all user's API request data
-	query := "DELETE FROM requests WHERE api_key = $1;"
-	_, err := connection.Exec(context.Background(), query, apiKey)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return err
-	}
-	return nil
-}
-
-func deleteUserAccount(apiKey string, c *gin.Context, connection *pgx.Conn) error {
-	// Delete user account record
-	query := "DELETE FROM users WHERE api_key = $1;"
-	_, err := connection.Exec(context.Background(), query, apiKey)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return err
-	}
-	return nil
-}
-
-func deleteUserMonitors(apiKey string, c *gin.Context, connection *pgx.Conn) error {
-	// Delete all user's monitored urls
-	query := "DELETE FROM monitor WHERE api_key = $1;"
-	_, err := connection.Exec(context.Background(), query, apiKey)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return err
-	}
-	return nil
-}
-
-func deleteUserPings(apiKey string, c *gin.Context, connection *pgx.Conn) error {
-	// Delete all user's recorded pings to all monitored urls
-	query := "DELETE FROM pings WHERE api_key = $1;"
-	_, err := connection.Exec(context.Background(), query, apiKey)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return err
-	}
-	return nil
-}
-
-func deleteData(c *gin.Context) {
-	apiKey := c.Param("apiKey")
+func deleteDataHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, c.Param("apiKey"), database.ScopeAdmin)
+		if !ok {
+			return
+		}
 
-	if apiKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-	}
+		err := database.DeleteAccount(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "Data deletion failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
+			return
+		}
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Data deletion failed - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
-		return
-	}
-	defer connection.Close(context.Background())
-
-	if err := deleteUserRequests(apiKey, c, connection); err != nil {
-		return
-	} else if err := deleteUserAccount(apiKey, c, connection); err != nil {
-		return
-	} else if err := deleteUserMonitors(apiKey, c, connection); err != nil {
-		return
-	} else if err := deleteUserPings(apiKey, c, connection); err != nil {
-		return
+		// Return API request data
+		c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Account data deleted successfully."})
 	}
-
-	// Return API request data
-	c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Account data deleted successfully."})
 }
 
 type MonitorRow struct {
@@ -789,43 +819,44 @@ type MonitorRow struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-func getUserMonitor(c *gin.Context) {
-	var userID string = c.Param("userID")
+func getUserMonitorHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID string = c.Param("userID")
 
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Monitor access failed - %s", userID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
-	defer connection.Close(context.Background())
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, userID)
+		if err != nil {
+			logging.FromContext(c).Warn("No API key associated with user ID", logging.UserID(userID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	// Retreive monitors created by this user
-	query := "SELECT url, secure, ping, monitor.created_at FROM monitor INNER JOIN users ON users.api_key = monitor.api_key WHERE users.user_id = $1;"
-	rows, err := connection.Query(context.Background(), query, userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
-	defer rows.Close()
+		// Retreive monitors created by this user
+		query := "SELECT url, secure, ping, created_at FROM monitor WHERE api_key = $1;"
+		rows, err := db.Query(c.Request.Context(), query, apiKey)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
+		defer rows.Close()
 
-	// Read monitors into list to return
-	monitors := make([]MonitorRow, 0)
-	for rows.Next() {
-		var monitor MonitorRow
-		err := rows.Scan(&monitor.URL, &monitor.Secure, &monitor.Ping, &monitor.CreatedAt)
-		if err == nil {
-			monitors = append(monitors, monitor)
+		// Read monitors into list to return
+		monitors := make([]MonitorRow, 0)
+		for rows.Next() {
+			var monitor MonitorRow
+			err := rows.Scan(&monitor.URL, &monitor.Secure, &monitor.Ping, &monitor.CreatedAt)
+			if err == nil {
+				monitors = append(monitors, monitor)
+			}
 		}
-	}
 
-	// Return API request data
-	c.JSON(http.StatusOK, monitors)
+		// Return API request data
+		c.JSON(http.StatusOK, monitors)
+	}
 }
 
 type Monitor struct {
@@ -835,166 +866,147 @@ type Monitor struct {
 	Ping   bool   `json:"ping"`
 }
 
-func addUserMonitor(c *gin.Context) {
-	var monitor Monitor
-	err := c.BindJSON(&monitor)
-	if err != nil {
-		log.LogToFile("Invalid monitor to add")
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid request body."})
-		return
-	}
-
-	if monitor.UserID == "" {
-		log.LogToFile("User ID empty")
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "User ID required."})
-		return
-	}
+func addUserMonitorHandler(db *pgxpool.Pool, monitorLimit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var monitor Monitor
+		err := c.BindJSON(&monitor)
+		if err != nil {
+			logging.FromContext(c).Warn("Invalid monitor to add")
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid request body."})
+			return
+		}
 
-	log.LogToFile(fmt.Sprintf("id=%s: Add monitor", monitor.UserID))
+		if monitor.UserID == "" {
+			logging.FromContext(c).Warn("User ID empty")
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "User ID required."})
+			return
+		}
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Monitor creation failed - %s", monitor.UserID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
-	defer connection.Close(context.Background())
+		logging.FromContext(c).Info("Add monitor", logging.UserID(monitor.UserID))
 
-	// Get API key from user ID
-	var apiKey string
-	query := "SELECT api_key FROM users WHERE user_id = $1;"
-	err = connection.QueryRow(context.Background(), query, monitor.UserID).Scan(&apiKey)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Invalid monitor user ID - %s", monitor.UserID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
+		// Get API key from user ID
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, monitor.UserID)
+		if err != nil {
+			logging.FromContext(c).Warn("Invalid monitor user ID", logging.UserID(monitor.UserID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
 
-	// Check if monitor already exists
-	var count int
-	query = "SELECT count(*) FROM monitor WHERE api_key = $1 AND url = $2;"
-	err = connection.QueryRow(context.Background(), query, apiKey, monitor.URL).Scan(&count)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Failed to get monitor count - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
-	if count == 1 {
-		log.LogToFile(fmt.Sprintf("key=%s: Monitor already exists", apiKey))
-		c.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict, "message": "Monitor already exists."})
-		return
-	}
+		// Check if monitor already exists
+		var count int
+		query := "SELECT count(*) FROM monitor WHERE api_key = $1 AND url = $2;"
+		err = db.QueryRow(c.Request.Context(), query, apiKey, monitor.URL).Scan(&count)
+		if err != nil {
+			logDBError(c, "Failed to get monitor count", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
+		if count == 1 {
+			logging.FromContext(c).Warn("Monitor already exists", logging.APIKey(apiKey))
+			c.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict, "message": "Monitor already exists."})
+			return
+		}
 
-	// Get monitor count
-	var monitorCount int
-	query = "SELECT count(*) FROM monitor WHERE api_key = $1;"
-	err = connection.QueryRow(context.Background(), query, apiKey).Scan(&monitorCount)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Failed to get monitor count - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
-	// Check if existing monitors already at max limit
-	if monitorCount >= 3 {
-		log.LogToFile(fmt.Sprintf("key=%s: Monitor limit reached (%d)", apiKey, monitorCount))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Monitor limit reached."})
-		return
-	}
+		// Get monitor count
+		var monitorCount int
+		query = "SELECT count(*) FROM monitor WHERE api_key = $1;"
+		err = db.QueryRow(c.Request.Context(), query, apiKey).Scan(&monitorCount)
+		if err != nil {
+			logDBError(c, "Failed to get monitor count", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
+		// Check if existing monitors already at the account's limit
+		quota, err := database.GetQuota(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "Failed to get quota, using default", logging.APIKey(apiKey), logging.Err(err))
+			quota.MonitorCount = monitorLimit
+		}
+		if monitorCount >= quota.MonitorCount {
+			logging.FromContext(c).Warn("Monitor limit reached", logging.APIKey(apiKey), slog.Int("monitors", monitorCount))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Monitor limit reached."})
+			return
+		}
 
-	// Insert new monitor into database
-	query = "INSERT INTO monitor (api_key, url, secure, ping, created_at) VALUES ($1, $2, $3, $4, NOW())"
-	_, err = connection.Exec(context.Background(), query, apiKey, monitor.URL, monitor.Secure, monitor.Ping)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Failed to create new monitor - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
+		// Insert new monitor into database
+		query = "INSERT INTO monitor (api_key, url, secure, ping, created_at) VALUES ($1, $2, $3, $4, NOW())"
+		_, err = db.Exec(c.Request.Context(), query, apiKey, monitor.URL, monitor.Secure, monitor.Ping)
+		if err != nil {
+			logDBError(c, "Failed to create new monitor", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
 
-	log.LogToFile(fmt.Sprintf("key=%s: Monitor '%s' created successfully", apiKey, monitor.URL))
+		logging.FromContext(c).Info("Monitor created successfully", logging.APIKey(apiKey), slog.String("url", monitor.URL))
 
-	// Return success response
-	c.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "New monitor created successfully."})
+		// Return success response
+		c.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "New monitor created successfully."})
+	}
 }
 
-func deleteMonitor(apiKey string, url string, c *gin.Context, connection *pgx.Conn) error {
+func deleteMonitor(ctx context.Context, db database.Querier, apiKey string, url string) error {
 	// Delete user's monitor to this specific url
 	query := "DELETE FROM monitor WHERE api_key = $1 AND url = $2;"
-	_, err := connection.Exec(context.Background(), query, apiKey, url)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return err
-	}
-	return nil
+	_, err := db.Exec(ctx, query, apiKey, url)
+	return err
 }
 
-func deletePings(apiKey string, url string, c *gin.Context, connection *pgx.Conn) error {
+func deletePings(ctx context.Context, db database.Querier, apiKey string, url string) error {
 	// Delete user's recorded pings to monitored url
 	query := "DELETE FROM pings WHERE api_key = $1 AND url = $2;"
-	_, err := connection.Exec(context.Background(), query, apiKey, url)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return err
-	}
-	return nil
+	_, err := db.Exec(ctx, query, apiKey, url)
+	return err
 }
 
-func deleteUserMonitor(c *gin.Context) {
-	var body struct {
-		UserID string `json:"user_id"`
-		URL    string `json:"url"`
-	}
-	err := c.BindJSON(&body)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("Invalid monitor to delete - %s", err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid request body."})
-		return
-	}
-
-	if body.UserID == "" {
-		log.LogToFile("User ID empty")
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "User ID required."})
-		return
-	}
+func deleteUserMonitorHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			UserID string `json:"user_id"`
+			URL    string `json:"url"`
+		}
+		err := c.BindJSON(&body)
+		if err != nil {
+			logging.FromContext(c).Warn("Invalid monitor to delete", logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid request body."})
+			return
+		}
 
-	log.LogToFile(fmt.Sprintf("id=%s: Delete monitor", body.UserID))
+		if body.UserID == "" {
+			logging.FromContext(c).Warn("User ID empty")
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "User ID required."})
+			return
+		}
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Monitor deletion failed - %s", body.UserID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
-	defer connection.Close(context.Background())
+		logging.FromContext(c).Info("Delete monitor", logging.UserID(body.UserID))
 
-	// Get API key from user ID
-	var apiKey string
-	query := "SELECT api_key FROM users WHERE user_id = $1;"
-	err = connection.QueryRow(context.Background(), query, body.UserID).Scan(&apiKey)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Invalid monitor user ID - %s", body.UserID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
+		// Get API key from user ID
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, body.UserID)
+		if err != nil {
+			logging.FromContext(c).Warn("Invalid monitor user ID", logging.UserID(body.UserID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
 
-	// Delete monitor from database
-	err = deleteMonitor(apiKey, body.URL, c, connection)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Failed to delete monitor - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
-	// Delete recorded pings from database for this monitor
-	err = deletePings(apiKey, body.URL, c, connection)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("key=%s: Failed to delete pings - %s", apiKey, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-		return
-	}
+		// Delete monitor from database
+		err = deleteMonitor(c.Request.Context(), db, apiKey, body.URL)
+		if err != nil {
+			logDBError(c, "Failed to delete monitor", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
+		// Delete recorded pings from database for this monitor
+		err = deletePings(c.Request.Context(), db, apiKey, body.URL)
+		if err != nil {
+			logDBError(c, "Failed to delete pings", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
+			return
+		}
 
-	log.LogToFile(fmt.Sprintf("key=%s: Monitor '%s' deleted successfully", apiKey, body.URL))
+		logging.FromContext(c).Info("Monitor deleted successfully", logging.APIKey(apiKey), slog.String("url", body.URL))
 
-	// Return success response
-	c.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "Monitor deleted successfully."})
+		// Return success response
+		c.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "Monitor deleted successfully."})
+	}
 }
 
 type PingsRow struct {
@@ -1010,112 +1022,195 @@ type MonitorPing struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
-func getUserPings(c *gin.Context) {
-	var userID string = c.Param("userID")
-	if userID == "" {
-		log.LogToFile("User ID empty")
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
+func getUserPingsHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID string = c.Param("userID")
+		if userID == "" {
+			logging.FromContext(c).Warn("User ID empty")
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	log.LogToFile(fmt.Sprintf("id=%s: Monitor access", userID))
+		logging.FromContext(c).Info("Monitor access", logging.UserID(userID))
 
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Monitor access failed - %s", userID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
-	defer connection.Close(context.Background())
+		// Fetch API key corresponding with user ID
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, userID)
+		if err != nil {
+			logging.FromContext(c).Warn("No API key associated with user ID", logging.UserID(userID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	// Fetch user ID corresponding with API key
-	query := "SELECT url FROM monitor INNER JOIN users ON users.api_key = monitor.api_key WHERE users.user_id = $1;"
-	rows, err := connection.Query(context.Background(), query, userID)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Monitor access failed - %s", userID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
+		query := "SELECT url FROM monitor WHERE api_key = $1;"
+		rows, err := db.Query(c.Request.Context(), query, apiKey)
+		if err != nil {
+			logDBError(c, "Monitor access failed", logging.UserID(userID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	// Initialise monitored URLs
-	monitors := make(map[string][]MonitorPing)
-	for rows.Next() {
-		var url string
-		if err := rows.Scan(&url); err == nil {
-			monitors[url] = make([]MonitorPing, 0)
+		// Initialise monitored URLs
+		monitors := make(map[string][]MonitorPing)
+		for rows.Next() {
+			var url string
+			if err := rows.Scan(&url); err == nil {
+				monitors[url] = make([]MonitorPing, 0)
+			}
 		}
-	}
+		rows.Close()
 
-	// Fetch user ID corresponding with API key
-	query = "SELECT url, response_time, status, pings.created_at FROM pings INNER JOIN users ON users.api_key = pings.api_key WHERE users.user_id = $1;"
-	rows, err = connection.Query(context.Background(), query, userID)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: Ping access failed - %s", userID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
+		query = "SELECT url, response_time, status, created_at FROM pings WHERE api_key = $1;"
+		rows, err = db.Query(c.Request.Context(), query, apiKey)
+		if err != nil {
+			logDBError(c, "Ping access failed", logging.UserID(userID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	// Read pings into list to return
-	for rows.Next() {
-		var url string
-		var ping MonitorPing
-		err := rows.Scan(&url, &ping.ResponseTime, &ping.Status, &ping.CreatedAt)
-		if err == nil {
-			if val, ok := monitors[url]; ok {
-				monitors[url] = append(val, ping)
+		// Read pings into list to return
+		for rows.Next() {
+			var url string
+			var ping MonitorPing
+			err := rows.Scan(&url, &ping.ResponseTime, &ping.Status, &ping.CreatedAt)
+			if err == nil {
+				if val, ok := monitors[url]; ok {
+					monitors[url] = append(val, ping)
+				}
 			}
 		}
-	}
+		rows.Close()
 
-	// Record user pings access
-	err = updateLastAccessedByUserID(connection, userID)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("id=%s: User last access update failed - %s", userID, err.Error()))
-		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
-		return
-	}
+		// Record user pings access
+		err = updateLastAccessed(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "User last access update failed", logging.UserID(userID), logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
 
-	log.LogToFile(fmt.Sprintf("id=%s: Monitor access successful (%d)", userID, len(monitors)))
+		logging.FromContext(c).Info("Monitor access successful", logging.UserID(userID), slog.Int("monitors", len(monitors)))
 
-	// Return API request data
-	c.JSON(http.StatusOK, monitors)
+		// Return API request data
+		c.JSON(http.StatusOK, monitors)
+	}
 }
 
-func checkHealth(c *gin.Context) {
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("Health check failed: %v", err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "unhealthy",
-			"error":  "Database connection failed",
+func checkHealthHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := db.Ping(c.Request.Context())
+		if err != nil {
+			logDBError(c, "Health check failed", logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status": "unhealthy",
+				"error":  "Database connection failed",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "healthy",
 		})
-		return
 	}
-	err = connection.Ping(context.Background())
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("Health check failed: %v", err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "unhealthy",
-			"error":  "Database connection failed",
+}
+
+// Streams an account's newly stored requests as Server-Sent Events until the
+// client disconnects
+func liveTailHandler(db *pgxpool.Pool, broker *live.Broker) gin.HandlerFunc {
+	// Keeps idle connections open through proxies
+	const heartbeatInterval = 15 * time.Second
+
+	return func(c *gin.Context) {
+		userID := c.Param("userID")
+		if userID == "" {
+			logging.FromContext(c).Warn("User ID empty")
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
+
+		filter, err := live.ParseFilter(c.Query("status"), c.Query("path"), c.Query("hostname"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid status filter."})
+			return
+		}
+
+		// Get API key from user ID
+		apiKey, err := getUserAPIKey(c.Request.Context(), db, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid user ID."})
+			return
+		}
+		logging.SetAPIKey(c, apiKey)
+
+		subscriber, err := broker.Subscribe(apiKey, filter)
+		if err != nil {
+			logging.FromContext(c).Warn("Live tail rejected", logging.Err(err))
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable, "message": "Too many live tail connections."})
+			return
+		}
+		metrics.LiveSubscribers.Inc()
+		defer func() {
+			metrics.LiveSubscribers.Dec()
+			if dropped := broker.Unsubscribe(subscriber); dropped > 0 {
+				logging.FromContext(c).Warn("Live tail requests dropped", slog.Int("dropped", dropped))
+			}
+		}()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case request := <-subscriber.Requests():
+				c.SSEvent("request", request)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			return true
 		})
-		return
 	}
+}
+
+// Options holds the limits and shared state the routes are registered with
+type Options struct {
+	PageSize     int // Rows read from the database per query
+	MaxLoad      int // Maximum rows returned to the dashboard
+	MonitorLimit int // Monitors per account when quotas cannot be read
+	Broker       *live.Broker
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
-	})
+func RegisterRouter(r *gin.RouterGroup, db *pgxpool.Pool, options Options) {
+	r.GET("/generate", genAPIKeyHandler(db))
+	r.GET("/generate-api-key", genAPIKeyHandler(db))
+	r.GET("/user-id/:apiKey", getUserIDHandler(db))
+	r.GET("/requests/:userID", getRequestsHandler(db, options.PageSize, options.MaxLoad))
+	r.GET("/requests/:userID/:page", getPaginatedRequestsHandler(db, options.PageSize))
+	r.GET("/aggregate/:userID", getAggregateHandler(db))
+	r.GET("/delete/:apiKey", deleteDataHandler(db))
+	r.GET("/monitor/pings/:userID", getUserPingsHandler(db))
+	r.POST("/monitor/add", addUserMonitorHandler(db, options.MonitorLimit))
+	r.POST("/monitor/delete", deleteUserMonitorHandler(db))
+	r.GET("/live/:userID", liveTailHandler(db, options.Broker))
+	r.GET("/data", getDataHandler(db))
+	r.GET("/export", exportDataHandler(db))
+	r.GET("/query", getTimeSeriesHandler(db))
+	r.POST("/rotate-api-key", rotateAPIKeyHandler(db))
+	r.POST("/tokens", createTokenHandler(db))
+	r.GET("/tokens", listTokensHandler(db))
+	r.POST("/tokens/:id/rotate", rotateTokenHandler(db))
+	r.DELETE("/tokens/:id", revokeTokenHandler(db))
+	r.GET("/health", checkHealthHandler(db))
 }
 
-func RegisterRouter(r *gin.RouterGroup) {
-	r.GET("/generate", genAPIKey)
-	r.GET("/generate-api-key", genAPIKey)
-	r.GET("/user-id/:apiKey", getUserID)
-	r.GET("/requests/:userID", getRequestsHandler())
-	r.GET("/requests/:userID/:page", getPaginatedRequestsHandler())
-	r.GET("/delete/:apiKey", deleteData)
-	r.GET("/monitor/pings/:userID", getUserPings)
-	r.POST("/monitor/add", addUserMonitor)
-	r.POST("/monitor/delete", deleteUserMonitor)
-	r.GET("/data", getData)
-	r.GET("/health", checkHealth)
+// Logs a failed database operation, counting it in the metrics
+func logDBError(c *gin.Context, msg string, args ...any) {
+	metrics.DBErrors.Inc()
+	logging.FromContext(c).Error(msg, args...)
 }
@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Longest name given to a token
+const maxTokenName = 64
+
+var errTokenScope = errors.New("token scope does not allow access")
+
+// Resolves an API key or token to the ID of its account, held in the api_key
+// column of account data. API keys grant full access, while tokens must have
+// a scope allowing the access required.
+func resolveAPIKey(ctx context.Context, db database.Querier, credential string, scope string) (string, error) {
+	accountID, tokenScope, err := database.Authenticate(ctx, db, credential)
+	if err != nil {
+		return "", err
+	}
+	if !database.ScopeAllows(tokenScope, scope) {
+		return "", errTokenScope
+	}
+	return accountID, nil
+}
+
+// Resolves the API key or token sent with a request to the ID of its
+// account, responding with an error unless it allows the access required
+func authenticateRequest(c *gin.Context, db database.Querier, credential string, scope string) (string, bool) {
+	if credential == "" {
+		logging.FromContext(c).Warn("API key empty")
+		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid API key."})
+		return "", false
+	}
+
+	apiKey, err := resolveAPIKey(c.Request.Context(), db, credential, scope)
+	if errors.Is(err, database.ErrUnauthenticated) {
+		logging.FromContext(c).Warn("Invalid API key", logging.APIKey(credential))
+		c.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "message": "Invalid, expired or revoked API key."})
+		return "", false
+	} else if errors.Is(err, errTokenScope) {
+		logging.FromContext(c).Warn("API token scope denied", logging.APIKey(credential), slog.String("required", scope))
+		c.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "message": "API token scope does not allow this request."})
+		return "", false
+	} else if err != nil {
+		logDBError(c, "API token authentication failed", logging.APIKey(credential), logging.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Authentication failed."})
+		return "", false
+	}
+	return apiKey, true
+}
+
+// CreatedToken holds a token, only returned when it is created.
+type CreatedToken struct {
+	Value string `json:"token"`
+	database.Token
+}
+
+type TokenRequest struct {
+	Name      string     `json:"name"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"` // Nil for a token that never expires
+}
+
+func createTokenHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeAdmin)
+		if !ok {
+			return
+		}
+
+		var body TokenRequest
+		if err := c.BindJSON(&body); err != nil {
+			logging.FromContext(c).Warn("Invalid token to create", logging.Err(err))
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid request body."})
+			return
+		}
+		if body.Name == "" || len(body.Name) > maxTokenName {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid name, expected 1 to 64 characters."})
+			return
+		}
+		if !database.ValidScope(body.Scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid scope, expected ingest, read, admin or dashboard."})
+			return
+		}
+		if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid expires_at, expected a time in the future."})
+			return
+		}
+
+		value, token, err := database.CreateToken(c.Request.Context(), db, apiKey, body.Name, body.Scope, body.ExpiresAt)
+		if err != nil {
+			logDBError(c, "Token creation failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Token creation failed."})
+			return
+		}
+
+		logging.FromContext(c).Info("Token created", logging.APIKey(apiKey), slog.Int64("token_id", token.ID), slog.String("scope", token.Scope))
+
+		c.JSON(http.StatusCreated, CreatedToken{value, token})
+	}
+}
+
+func listTokensHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeAdmin)
+		if !ok {
+			return
+		}
+
+		tokens, err := database.ListTokens(c.Request.Context(), db, apiKey)
+		if err != nil {
+			logDBError(c, "Token listing failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Failed to fetch tokens."})
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+func getTokenID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid token ID."})
+		return 0, false
+	}
+	return id, true
+}
+
+func rotateTokenHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeAdmin)
+		if !ok {
+			return
+		}
+		id, ok := getTokenID(c)
+		if !ok {
+			return
+		}
+
+		value, token, err := database.RotateToken(c.Request.Context(), db, apiKey, id)
+		if errors.Is(err, database.ErrTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Token not found."})
+			return
+		} else if err != nil {
+			logDBError(c, "Token rotation failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Token rotation failed."})
+			return
+		}
+
+		logging.FromContext(c).Info("Token rotated", logging.APIKey(apiKey), slog.Int64("token_id", id), slog.Int64("new_token_id", token.ID))
+
+		c.JSON(http.StatusCreated, CreatedToken{value, token})
+	}
+}
+
+func revokeTokenHandler(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := authenticateRequest(c, db, getAPIKeyHeader(c), database.ScopeAdmin)
+		if !ok {
+			return
+		}
+		id, ok := getTokenID(c)
+		if !ok {
+			return
+		}
+
+		err := database.RevokeToken(c.Request.Context(), db, apiKey, id)
+		if errors.Is(err, database.ErrTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "message": "Token not found."})
+			return
+		} else if err != nil {
+			logDBError(c, "Token revocation failed", logging.APIKey(apiKey), logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Token revocation failed."})
+			return
+		}
+
+		logging.FromContext(c).Info("Token revoked", logging.APIKey(apiKey), slog.Int64("token_id", id))
+
+		c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Token revoked successfully."})
+	}
+}
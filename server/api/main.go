@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
-	"github.com/tom-draper/api-analytics/server/api/lib/log"
+	"github.com/tom-draper/api-analytics/server/api/lib/live"
+	"github.com/tom-draper/api-analytics/server/api/lib/metrics"
 	"github.com/tom-draper/api-analytics/server/api/lib/routes"
-	"github.com/tom-draper/api-analytics/server/api/lib/env"
+	"github.com/tom-draper/api-analytics/server/config"
 	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
 
 	ratelimit "github.com/JGLTechnologies/gin-rate-limit"
 	"github.com/gin-contrib/cors"
@@ -20,39 +27,77 @@ func keyFunc(c *gin.Context) string {
 }
 
 func errorHandler(c *gin.Context, info ratelimit.Info) {
+	metrics.RateLimited.Inc()
 	c.String(http.StatusTooManyRequests, "Too many requests. Try again in "+time.Until(info.ResetTime).String())
 }
 
-func getRateLimit() uint {
-	return uint(env.GetIntegerEnvVariable("RATE_LIMIT", 100))
-}
-
 func main() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.LogToFile(fmt.Sprintf("Application crashed: %v", err))
+			slog.Error("Application crashed", slog.Any("error", err))
 		}
 	}()
 
-	log.LogToFile("Starting api...")
+	cfg := defaultConfig()
+	err := config.Load(&cfg, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		os.Exit(2)
+	}
+
+	// JSON logs, rotated once they reach log.max_size megabytes
+	logger, logFile, err := logging.New(cfg.Log)
+	if err != nil {
+		slog.Error("Failed to create logger", logging.Err(err))
+		return
+	}
+	defer logFile.Close()
+	slog.SetDefault(logger)
+
+	slog.Info("Starting api...")
+
+	database.Configure(cfg.Postgres)
 
-	err := database.LoadConfig()
+	// Connection pool shared by all handlers
+	pool, err := database.NewPool(context.Background())
 	if err != nil {
-		log.LogToFile("Failed to load database configuration: " + err.Error())
+		slog.Error("Failed to create database connection pool", logging.Err(err))
+		return
+	}
+	defer pool.Close()
+
+	// Refuse to start against a schema this build was not written for
+	err = database.CheckSchemaVersion(context.Background(), pool)
+	if err != nil {
+		slog.Error("Database schema check failed", logging.Err(err))
 		return
 	}
 
 	gin.SetMode(gin.ReleaseMode)
 	app := gin.New()
 
+	app.Use(logging.Middleware(logger))
+	app.Use(metrics.Middleware())
+
+	// Served on their own port so they are not exposed with the API
+	if cfg.MetricsPort > 0 {
+		go func() {
+			if err := metrics.Serve(cfg.MetricsPort); err != nil {
+				slog.Error("Failed to serve metrics", logging.Err(err))
+			}
+		}()
+	}
+
 	r := app.Group("/api")
 
 	r.Use(cors.Default())
 
-	// Limit a single IP's request logs to 100 per second
+	// Limit a single IP's requests to rate_limit per second
 	store := ratelimit.InMemoryStore(&ratelimit.InMemoryOptions{
 		Rate:  time.Second,
-		Limit: getRateLimit(),
+		Limit: cfg.RateLimit,
 	})
 	rateLimiter := ratelimit.RateLimiter(store, &ratelimit.Options{
 		ErrorHandler: errorHandler,
@@ -60,9 +105,20 @@ func main() {
 	})
 	app.Use(rateLimiter)
 
-	routes.RegisterRouter(r)
+	// Requests published by the logger, streamed to live tail subscribers
+	broker := live.NewBroker(cfg.LiveMaxSubscribers)
+	if cfg.LiveMaxSubscribers > 0 {
+		go broker.Listen(context.Background(), database.NewConnection)
+	}
+
+	routes.RegisterRouter(r, pool, routes.Options{
+		PageSize:     cfg.PageSize,
+		MaxLoad:      cfg.MaxLoad,
+		MonitorLimit: cfg.MonitorLimit,
+		Broker:       broker,
+	})
 
-	if err := app.Run(":3000"); err != nil {
-		log.LogToFile(fmt.Sprintf("Failed to run server: %v", err))
+	if err := app.Run(fmt.Sprintf(":%d", cfg.Port)); err != nil {
+		slog.Error("Failed to run server", logging.Err(err))
 	}
 }
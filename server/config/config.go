@@ -0,0 +1,289 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Validator is implemented by configs that check their values once loaded.
+type Validator interface {
+	Validate() error
+}
+
+// A configurable struct field
+type field struct {
+	key      string // Dotted name used in config files
+	env      string // Environment variable name
+	flag     string // Command-line flag name
+	help     string
+	required bool
+	value    reflect.Value
+}
+
+// Load fills cfg, a pointer to a struct holding its default values, from each
+// source in turn, with later sources overriding earlier ones:
+//
+//  1. a YAML or TOML file given by --config or CONFIG_FILE
+//  2. environment variables, including any set in the .env file given by
+//     --env-file or ENV_FILE, which defaults to .env
+//  3. command-line flags in args
+//
+// Fields are named by their `config` tag, and untagged fields are left as
+// they are. A nested struct's tag prefixes the names of its fields, so a
+// capacity field within a queue struct is read from queue.capacity in a
+// file, QUEUE_CAPACITY from the environment and --queue-capacity as a flag.
+// Fields tagged `required:"true"` must be set by one of the sources.
+func Load(cfg any, args []string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config must be a pointer to a struct")
+	}
+	fields := make([]field, 0)
+	collectFields(v.Elem(), "", &fields)
+
+	flags, configPath, envPath, err := parseFlags(fields, args)
+	if err != nil {
+		return err
+	}
+
+	// Environment variables already set take priority over the .env file
+	if envPath == "" {
+		envPath = os.Getenv("ENV_FILE")
+	}
+	if envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load %s: %w", envPath, err)
+		}
+	} else if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_FILE")
+	}
+	fileValues := map[string]string{}
+	if configPath != "" {
+		fileValues, err = readFile(configPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+		set := false
+		if value, ok := fileValues[f.key]; ok {
+			if err := setValue(f.value, value); err != nil {
+				return fmt.Errorf("invalid %s in %s: %w", f.key, configPath, err)
+			}
+			set = true
+		}
+		if value := os.Getenv(f.env); value != "" {
+			if err := setValue(f.value, value); err != nil {
+				return fmt.Errorf("invalid %s environment variable: %w", f.env, err)
+			}
+			set = true
+		}
+		if value, ok := flags[f.flag]; ok {
+			if err := setValue(f.value, value); err != nil {
+				return fmt.Errorf("invalid --%s flag: %w", f.flag, err)
+			}
+			set = true
+		}
+		if f.required && !set && f.value.IsZero() {
+			return fmt.Errorf("%s is required", f.env)
+		}
+	}
+
+	// Catch misspelt settings rather than silently using the default
+	unknown := make([]string, 0)
+	for key := range fileValues {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings in %s: %s", configPath, strings.Join(unknown, ", "))
+	}
+
+	if validator, ok := cfg.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+func collectFields(v reflect.Value, prefix string, fields *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		name := structField.Tag.Get("config")
+		if name == "" || !structField.IsExported() {
+			continue
+		}
+		key := prefix + name
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct {
+			collectFields(value, key+".", fields)
+			continue
+		}
+
+		*fields = append(*fields, field{
+			key:      key,
+			env:      strings.ToUpper(strings.ReplaceAll(key, ".", "_")),
+			flag:     strings.NewReplacer(".", "-", "_", "-").Replace(key),
+			help:     structField.Tag.Get("help"),
+			required: structField.Tag.Get("required") == "true",
+			value:    value,
+		})
+	}
+}
+
+// Parses command-line flags, returning the values of those given along with
+// the config and .env file paths
+func parseFlags(fields []field, args []string) (map[string]string, string, string, error) {
+	name := filepath.Base(os.Args[0])
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	var configPath, envPath string
+	fs.StringVar(&configPath, "config", "", "YAML or TOML config file")
+	fs.StringVar(&envPath, "env-file", "", "File of environment variables to load (default .env)")
+
+	values := make(map[string]string)
+	for _, f := range fields {
+		f := f
+		help := f.help
+		if help == "" {
+			help = fmt.Sprintf("Sets %s", f.key)
+		}
+		if def := fmt.Sprint(f.value.Interface()); def != "" && !f.value.IsZero() {
+			help += fmt.Sprintf(" (default %s)", def)
+		}
+		fs.Func(f.flag, help+fmt.Sprintf(", or %s", f.env), func(value string) error {
+			values[f.flag] = value
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", "", err
+	}
+	if fs.NArg() > 0 {
+		return nil, "", "", fmt.Errorf("unexpected argument %s", fs.Arg(0))
+	}
+	return values, configPath, envPath, nil
+}
+
+// Reads a YAML or TOML file into values keyed by their dotted names
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	document := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten(document, "", values)
+	return values, nil
+}
+
+func flatten(document map[string]any, prefix string, values map[string]string) {
+	for key, value := range document {
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(value, prefix+key+".", values)
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[prefix+key] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[prefix+key] = fmt.Sprint(value)
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		items := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
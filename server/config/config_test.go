@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Port    int           `config:"port"`
+	Name    string        `config:"name"`
+	Debug   bool          `config:"debug"`
+	Timeout time.Duration `config:"timeout"`
+	Hosts   []string      `config:"hosts"`
+	Queue   struct {
+		Capacity  int `config:"capacity"`
+		BatchSize int `config:"batch_size"`
+	} `config:"queue"`
+	Ignored string
+}
+
+func (c *testConfig) Validate() error {
+	if c.Port <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func newTestConfig() *testConfig {
+	cfg := &testConfig{Port: 8000, Name: "default", Timeout: time.Second}
+	cfg.Queue.Capacity = 10
+	return cfg
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("ENV_FILE", writeFile(t, ".env", ""))
+	cfg := newTestConfig()
+	if err := Load(cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8000 || cfg.Name != "default" || cfg.Queue.Capacity != 10 {
+		t.Errorf("got %+v, expected defaults", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlPath := writeFile(t, "config.yaml", "port: 9000\nname: file\ndebug: true\ntimeout: 5s\nhosts: [a, b]\nqueue:\n  capacity: 20\n  batch_size: 30\n")
+	envPath := writeFile(t, ".env", "NAME=dotenv\nQUEUE_CAPACITY=40\n")
+	t.Setenv("QUEUE_BATCH_SIZE", "50")
+
+	cfg := newTestConfig()
+	err := Load(cfg, []string{"--config", yamlPath, "--env-file", envPath, "--port", "9100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("NAME")
+	os.Unsetenv("QUEUE_CAPACITY")
+
+	expected := newTestConfig()
+	expected.Port = 9100     // Flag
+	expected.Name = "dotenv" // .env file
+	expected.Debug = true    // Config file
+	expected.Timeout = 5 * time.Second
+	expected.Hosts = []string{"a", "b"}
+	expected.Queue.Capacity = 40  // .env file
+	expected.Queue.BatchSize = 50 // Environment
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("got %+v, expected %+v", cfg, expected)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	t.Setenv("ENV_FILE", writeFile(t, ".env", ""))
+	path := writeFile(t, "config.toml", "port = 9000\n[queue]\ncapacity = 20\n")
+	cfg := newTestConfig()
+	if err := Load(cfg, []string{"--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9000 || cfg.Queue.Capacity != 20 {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Setenv("ENV_FILE", writeFile(t, ".env", ""))
+
+	expecteds := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--config", writeFile(t, "config.yaml", "prot: 9000\n")}, "unknown settings"},
+		{[]string{"--config", writeFile(t, "config.yaml", "port: eighty\n")}, "invalid port"},
+		{[]string{"--config", writeFile(t, "config.json", "{}")}, "unsupported config file"},
+		{[]string{"--port", "0"}, "port must be positive"},
+		{[]string{"--timeout", "soon"}, "invalid --timeout flag"},
+		{[]string{"--unknown"}, "flag provided but not defined"},
+	}
+	for _, expected := range expecteds {
+		err := Load(newTestConfig(), expected.args)
+		if err == nil || !strings.Contains(err.Error(), expected.err) {
+			t.Errorf("%v: got %v, expected %q", expected.args, err, expected.err)
+		}
+	}
+}
+
+func TestLoadRequired(t *testing.T) {
+	t.Setenv("ENV_FILE", writeFile(t, ".env", ""))
+	cfg := &struct {
+		URL string `config:"url" required:"true"`
+	}{}
+	if err := Load(cfg, nil); err == nil || !strings.Contains(err.Error(), "URL is required") {
+		t.Errorf("got %v, expected required error", err)
+	}
+
+	t.Setenv("URL", "postgres://localhost")
+	if err := Load(cfg, nil); err != nil || cfg.URL != "postgres://localhost" {
+		t.Errorf("got %v %q", err, cfg.URL)
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/tom-draper/api-analytics/server/config
+
+go 1.21.0
+
+toolchain go1.21.4
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/joho/godotenv v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,63 @@
+package database
+
+// HTTP methods, indexed by the code they are stored as in the method column
+var methodNames = [...]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "HEAD", "TRACE"}
+
+var methodIDs = func() map[string]int16 {
+	ids := make(map[string]int16, len(methodNames))
+	for i, name := range methodNames {
+		ids[name] = int16(i)
+	}
+	return ids
+}()
+
+// MethodID returns the code an upper case HTTP method is stored as.
+func MethodID(method string) (int16, bool) {
+	id, ok := methodIDs[method]
+	return id, ok
+}
+
+// MethodName returns the HTTP method stored as id, or an empty string if id
+// is unknown.
+func MethodName(id int16) string {
+	if id < 0 || int(id) >= len(methodNames) {
+		return ""
+	}
+	return methodNames[id]
+}
+
+// Frameworks logging requests other than through a middleware
+const (
+	FrameworkOpenTelemetry int16 = 18 // Spans received over OTLP
+	FrameworkAccessLog     int16 = 19 // Requests imported from web server access logs
+)
+
+// Codes stored in the framework column, by the name sent by the middleware
+var frameworkIDs = map[string]int16{
+	"FastAPI":       0,
+	"Flask":         1,
+	"Gin":           2,
+	"Echo":          3,
+	"Express":       4,
+	"Fastify":       5,
+	"Koa":           6,
+	"Chi":           7,
+	"Fiber":         8,
+	"Actix":         9,
+	"Axum":          10,
+	"Tornado":       11,
+	"Django":        12,
+	"Rails":         13,
+	"Laravel":       14,
+	"Sinatra":       15,
+	"Rocket":        16,
+	"ASP.NET Core":  17,
+	"OpenTelemetry": FrameworkOpenTelemetry,
+	"Access Log":    FrameworkAccessLog,
+}
+
+// FrameworkID returns the code a framework is stored as.
+func FrameworkID(framework string) (int16, bool) {
+	id, ok := frameworkIDs[framework]
+	return id, ok
+}
@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestMethodID(t *testing.T) {
+	for _, name := range methodNames {
+		id, ok := MethodID(name)
+		if !ok || MethodName(id) != name {
+			t.Errorf("got %d for %s, expected its name back", id, name)
+		}
+	}
+	if _, ok := MethodID("get"); ok {
+		t.Error("expected lower case method to be unknown")
+	}
+	if name := MethodName(int16(len(methodNames))); name != "" {
+		t.Errorf("got %q for an unknown ID, expected empty", name)
+	}
+}
+
+func TestFrameworkID(t *testing.T) {
+	if id, ok := FrameworkID("Access Log"); !ok || id != FrameworkAccessLog {
+		t.Errorf("got %d, expected %d", id, FrameworkAccessLog)
+	}
+	if _, ok := FrameworkID("Unknown"); ok {
+		t.Error("expected unknown framework")
+	}
+}
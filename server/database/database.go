@@ -2,34 +2,54 @@ package database
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net/url"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/joho/godotenv"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/config"
 )
 
 var dbURL string
 
+// Config holds the database connection settings
+type Config struct {
+	URL string `config:"url" help:"Postgres connection URL, which may set pool_max_conns" required:"true"`
+}
+
+// LoadConfig reads the database settings from the environment and .env file,
+// for tools without settings of their own. Services include a Config in
+// their settings and pass it to Configure instead.
 func LoadConfig() error {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Println("warning: could not load .env file")
+	var cfg struct {
+		Postgres Config `config:"postgres"`
 	}
-
-	// Get the POSTGRES_URL environment variable
-	dbURL = os.Getenv("POSTGRES_URL")
-	if dbURL == "" {
-		return fmt.Errorf("POSTGRES_URL is not set in the environment.")
+	if err := config.Load(&cfg, nil); err != nil {
+		return err
 	}
+	Configure(cfg.Postgres)
+	return nil
+}
 
-	log.Printf("POSTGRES_URL=%s\n", dbURL)
+// Configure sets the database connected to by NewConnection and NewPool.
+func Configure(cfg Config) {
+	dbURL = cfg.URL
+	slog.Debug("Database configured", slog.String("url", redactURL(dbURL)))
+}
 
-	return nil
+// Hides the password in a connection URL so it can be logged
+func redactURL(connString string) string {
+	u, err := url.Parse(connString)
+	if err != nil || u.Scheme == "" {
+		// Keyword/value connection strings are not logged at all
+		return "[redacted]"
+	}
+	return u.Redacted()
 }
 
-func NewConnection() (*pgx.Conn, error) {
+// NewConnection opens a single connection, for tools and listeners that need
+// one to themselves. Services should share a pool from NewPool instead.
+func NewConnection(ctx context.Context) (*pgx.Conn, error) {
 	if dbURL == "" {
 		err := LoadConfig()
 		if err != nil {
@@ -37,57 +57,112 @@ func NewConnection() (*pgx.Conn, error) {
 		}
 	}
 
-	conn, err := pgx.Connect(context.Background(), dbURL)
+	conn, err := pgx.Connect(ctx, dbURL)
 	if err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
 
-func DeleteUser(apiKey string) error {
-	conn, err := NewConnection()
+// NewPool creates a connection pool intended to be shared for the lifetime of
+// a service. Pool size can be tuned with pool_max_conns in POSTGRES_URL.
+func NewPool(ctx context.Context) (*pgxpool.Pool, error) {
+	if dbURL == "" {
+		err := LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pool, err := pgxpool.New(ctx, dbURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer conn.Close(context.Background())
+	return pool, nil
+}
+
+// Beginner is satisfied by a *pgx.Conn, *pgxpool.Pool or pgx.Tx
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// DB is satisfied by a *pgx.Conn, *pgxpool.Pool or pgx.Tx
+type DB interface {
+	Querier
+	Beginner
+}
 
+// DeleteAccount removes an account and everything stored for it in a single
+// transaction.
+func DeleteAccount(ctx context.Context, db Beginner, apiKey string) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if err := DeleteRequests(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		if err := DeleteRollups(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		if err := DeletePings(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		if err := DeleteMonitors(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		if err := DeleteQuota(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		if err := DeleteTokens(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		if err := DeleteAPIKeys(ctx, tx, apiKey); err != nil {
+			return err
+		}
+		return DeleteUser(ctx, tx, apiKey)
+	})
+}
+
+func DeleteUser(ctx context.Context, db Querier, apiKey string) error {
 	query := "DELETE FROM users WHERE api_key = $1;"
-	_, err = conn.Exec(context.Background(), query, apiKey)
+	_, err := db.Exec(ctx, query, apiKey)
 	return err
 }
 
-func DeleteRequests(apiKey string) error {
-	conn, err := NewConnection()
+func DeleteRollups(ctx context.Context, db Querier, apiKey string) error {
+	_, err := db.Exec(ctx, "DELETE FROM rollup_pending WHERE api_key = $1;", apiKey)
 	if err != nil {
 		return err
 	}
-	defer conn.Close(context.Background())
-
-	query := "DELETE FROM requests WHERE api_key = $1;"
-	_, err = conn.Exec(context.Background(), query, apiKey)
+	_, err = db.Exec(ctx, "DELETE FROM request_rollups WHERE api_key = $1;", apiKey)
 	return err
 }
 
-func DeleteMonitors(apiKey string) error {
-	conn, err := NewConnection()
+func DeleteRequests(ctx context.Context, db Querier, apiKey string) error {
+	query := "DELETE FROM requests WHERE api_key = $1;"
+	_, err := db.Exec(ctx, query, apiKey)
+	if err != nil {
+		return err
+	}
+
+	// Allow deleted requests to be imported again
+	query = "DELETE FROM imported_requests WHERE api_key = $1;"
+	_, err = db.Exec(ctx, query, apiKey)
 	if err != nil {
 		return err
 	}
-	defer conn.Close(context.Background())
 
-	query := "DELETE FROM monitor WHERE api_key = $1;"
-	_, err = conn.Exec(context.Background(), query, apiKey)
+	query = "DELETE FROM ingested_batches WHERE api_key = $1;"
+	_, err = db.Exec(ctx, query, apiKey)
 	return err
 }
 
-func DeletePings(apiKey string) error {
-	conn, err := NewConnection()
-	if err != nil {
-		return err
-	}
-	defer conn.Close(context.Background())
+func DeleteMonitors(ctx context.Context, db Querier, apiKey string) error {
+	query := "DELETE FROM monitor WHERE api_key = $1;"
+	_, err := db.Exec(ctx, query, apiKey)
+	return err
+}
 
+func DeletePings(ctx context.Context, db Querier, apiKey string) error {
 	query := "DELETE FROM pings WHERE api_key = $1;"
-	_, err = conn.Exec(context.Background(), query, apiKey)
+	_, err := db.Exec(ctx, query, apiKey)
 	return err
-}
\ No newline at end of file
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Accounts are identified by a random ID, held in the api_key column of every
+// table storing account data. API keys are only stored hashed, mapped to their
+// account in the api_keys table so they can be rotated without moving data.
+
+// Rotated keys stay valid for a grace period, giving clients time to be
+// updated with the new key
+const (
+	DefaultRotationGrace = 24 * time.Hour
+	MaxRotationGrace     = 30 * 24 * time.Hour
+)
+
+// Characters of an API key kept as its hint
+const keyHintLength = 8
+
+// ErrUnauthenticated is returned for API keys and tokens that are unknown,
+// expired or revoked.
+var ErrUnauthenticated = errors.New("unknown, expired or revoked credential")
+
+// HashAPIKey returns the hash an API key is stored as. Keys are UUIDs, which
+// are matched regardless of case.
+func HashAPIKey(apiKey string) []byte {
+	return HashToken(strings.ToLower(apiKey))
+}
+
+func insertAPIKey(ctx context.Context, db Querier, apiKey string, accountID string) error {
+	query := "INSERT INTO api_keys (key_hash, account_id, hint, created_at) VALUES ($1, $2, $3, NOW());"
+	_, err := db.Exec(ctx, query, HashAPIKey(apiKey), accountID, strings.ToLower(apiKey)[:keyHintLength])
+	return err
+}
+
+// CreateAccount creates an account, returning its API key.
+func CreateAccount(ctx context.Context, db Beginner) (string, error) {
+	var apiKey string
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		var accountID string
+		query := "INSERT INTO users (api_key, created_at, last_accessed) VALUES (gen_random_uuid(), NOW(), NOW()) RETURNING api_key::text;"
+		if err := tx.QueryRow(ctx, query).Scan(&accountID); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(ctx, "SELECT gen_random_uuid()::text;").Scan(&apiKey); err != nil {
+			return err
+		}
+		return insertAPIKey(ctx, tx, apiKey, accountID)
+	})
+	return apiKey, err
+}
+
+// AuthenticateAPIKey returns the ID of the account an unexpired API key
+// belongs to, or ErrUnauthenticated.
+func AuthenticateAPIKey(ctx context.Context, db Querier, apiKey string) (string, error) {
+	var accountID string
+	query := "SELECT account_id::text FROM api_keys WHERE key_hash = $1 AND (expires_at IS NULL OR expires_at > NOW());"
+	err := db.QueryRow(ctx, query, HashAPIKey(apiKey)).Scan(&accountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrUnauthenticated
+	}
+	return accountID, err
+}
+
+// Authenticate returns the ID of the account an API key, token or dashboard
+// user ID belongs to, and the scope it grants. API keys grant full access,
+// and user IDs the dashboard scope. Returns
+// ErrUnauthenticated if the credential is unknown, expired or revoked.
+func Authenticate(ctx context.Context, db Querier, credential string) (string, string, error) {
+	if IsToken(credential) {
+		return authenticateToken(ctx, db, HashToken(credential))
+	}
+	accountID, err := AuthenticateAPIKey(ctx, db, credential)
+	if errors.Is(err, ErrUnauthenticated) {
+		// Dashboard user IDs are stored as tokens hashed in the same way as
+		// API keys
+		return authenticateToken(ctx, db, HashAPIKey(credential))
+	}
+	return accountID, ScopeAdmin, err
+}
+
+// RotateAPIKey issues a new API key for an account. The account's other keys
+// expire after the grace period, or sooner if they were already set to.
+func RotateAPIKey(ctx context.Context, db Beginner, accountID string, grace time.Duration) (string, time.Time, error) {
+	var apiKey string
+	var expiresAt time.Time
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, "SELECT gen_random_uuid()::text;").Scan(&apiKey); err != nil {
+			return err
+		}
+		query := "SELECT NOW() + make_interval(secs => $1);"
+		if err := tx.QueryRow(ctx, query, grace.Seconds()).Scan(&expiresAt); err != nil {
+			return err
+		}
+		query = "UPDATE api_keys SET expires_at = $2 WHERE account_id = $1 AND (expires_at IS NULL OR expires_at > $2);"
+		if _, err := tx.Exec(ctx, query, accountID, expiresAt); err != nil {
+			return err
+		}
+		return insertAPIKey(ctx, tx, apiKey, accountID)
+	})
+	return apiKey, expiresAt, err
+}
+
+// DeleteAPIKeys removes all of an account's API keys.
+func DeleteAPIKeys(ctx context.Context, db Querier, accountID string) error {
+	_, err := db.Exec(ctx, "DELETE FROM api_keys WHERE account_id = $1;", accountID)
+	return err
+}
+
+// DeleteExpiredAPIKeys removes API keys whose grace period has ended,
+// returning the number removed.
+func DeleteExpiredAPIKeys(ctx context.Context, db Querier) (int64, error) {
+	tag, err := db.Exec(ctx, "DELETE FROM api_keys WHERE expires_at <= NOW();")
+	return tag.RowsAffected(), err
+}
@@ -0,0 +1,17 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	apiKey := "0b3c1c0e-8c1d-4f3a-9e2b-7d6c5b4a3f21"
+	if !bytes.Equal(HashAPIKey(apiKey), HashAPIKey("0B3C1C0E-8C1D-4F3A-9E2B-7D6C5B4A3F21")) {
+		t.Error("got different hashes of the same key in different cases")
+	}
+	// Matches the hash of existing keys computed by the api_keys migration
+	if !bytes.Equal(HashAPIKey(apiKey), HashToken(apiKey)) {
+		t.Error("got a different hash of a lowercase key")
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// LiveChannel is the Postgres channel newly stored requests are published to
+// for live tailing
+const LiveChannel = "live_requests"
+
+// Postgres rejects NOTIFY payloads of 8000 bytes or more
+const maxNotifyPayload = 7900
+
+// LiveRequest is a stored request as published to live tail subscribers. IP
+// addresses are never published.
+type LiveRequest struct {
+	Hostname     string `json:"hostname,omitempty"`
+	Path         string `json:"path"`
+	Method       string `json:"method"`
+	Status       int16  `json:"status"`
+	ResponseTime int16  `json:"response_time"`
+	Location     string `json:"location,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+	UserAgent    string `json:"user_agent,omitempty"`
+	Bot          bool   `json:"bot"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// LiveBatch is the payload of a single notification on LiveChannel
+type LiveBatch struct {
+	APIKey   string        `json:"api_key"`
+	Requests []LiveRequest `json:"requests"`
+}
+
+// NotifyRequests publishes stored requests, grouped by account, on
+// LiveChannel, split across as many notifications as needed to fit the
+// payload limit. Notifications are sent in a single statement, as each
+// committing transaction that notifies takes a database-wide lock.
+func NotifyRequests(ctx context.Context, db Querier, requests map[string][]LiveRequest) error {
+	payloads := make([]string, 0)
+	for apiKey, accountRequests := range requests {
+		accountPayloads, err := liveBatchPayloads(apiKey, accountRequests)
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, accountPayloads...)
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, "SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload;", LiveChannel, payloads)
+	return err
+}
+
+// Encodes requests as LiveBatch JSON payloads each below the payload limit
+func liveBatchPayloads(apiKey string, requests []LiveRequest) ([]string, error) {
+	prefix, err := json.Marshal(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	header := `{"api_key":` + string(prefix) + `,"requests":[`
+	const footer = "]}"
+
+	payloads := make([]string, 0)
+	var payload bytes.Buffer
+	count := 0
+	flush := func() {
+		if count > 0 {
+			payload.WriteString(footer)
+			payloads = append(payloads, payload.String())
+		}
+		payload.Reset()
+		count = 0
+	}
+
+	for _, request := range requests {
+		encoded, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		if len(header)+len(encoded)+len(footer) > maxNotifyPayload {
+			// Too large to publish on its own
+			continue
+		}
+		if count > 0 && payload.Len()+1+len(encoded)+len(footer) > maxNotifyPayload {
+			flush()
+		}
+		if count == 0 {
+			payload.WriteString(header)
+		} else {
+			payload.WriteByte(',')
+		}
+		payload.Write(encoded)
+		count++
+	}
+	flush()
+	return payloads, nil
+}
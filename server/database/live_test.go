@@ -0,0 +1,57 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLiveBatchPayloads(t *testing.T) {
+	requests := make([]LiveRequest, 200)
+	for i := range requests {
+		requests[i] = LiveRequest{
+			Path:      "/v1/" + strings.Repeat("a", 100),
+			Method:    "GET",
+			Status:    200,
+			CreatedAt: "2024-01-01T00:00:00Z",
+		}
+	}
+	// Too large to publish
+	requests = append(requests, LiveRequest{Path: strings.Repeat("b", maxNotifyPayload)})
+
+	payloads, err := liveBatchPayloads("key", requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) < 2 {
+		t.Fatalf("got %d payloads, expected requests split across several", len(payloads))
+	}
+
+	total := 0
+	for _, payload := range payloads {
+		if len(payload) > maxNotifyPayload {
+			t.Errorf("payload of %d bytes exceeds limit", len(payload))
+		}
+		var batch LiveBatch
+		if err := json.Unmarshal([]byte(payload), &batch); err != nil {
+			t.Fatal(err)
+		}
+		if batch.APIKey != "key" {
+			t.Errorf("got API key %q", batch.APIKey)
+		}
+		total += len(batch.Requests)
+	}
+	if total != 200 {
+		t.Errorf("got %d requests, expected 200", total)
+	}
+}
+
+func TestLiveBatchPayloadsEmpty(t *testing.T) {
+	payloads, err := liveBatchPayloads("key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 0 {
+		t.Errorf("got %d payloads, expected none", len(payloads))
+	}
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Arbitrary key for the advisory lock held while migrating, so concurrent
+// runs wait for each other rather than applying a migration twice
+const migrationLockKey = 7265436101
+
+// First line of a migration file run outside a transaction, for data
+// migrations too large to apply at once. Such a migration is a single DO
+// block committing its own progress, so must be safe to run again if
+// interrupted.
+const noTransactionMarker = "-- migrate:no-transaction"
+
+// Migration is a numbered schema change with the SQL to apply and revert it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState reports whether a migration has been applied.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// SchemaVersionError is returned when the database schema is not at the
+// version this build expects.
+type SchemaVersionError struct {
+	Current  int
+	Expected int
+}
+
+func (e *SchemaVersionError) Error() string {
+	if e.Current < e.Expected {
+		return fmt.Sprintf("database schema is at version %d, expected %d: run `migrate up` to upgrade", e.Current, e.Expected)
+	}
+	return fmt.Sprintf("database schema is at version %d, newer than the expected %d: upgrade this service", e.Current, e.Expected)
+}
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	return parseMigrations(migrationFiles, "migrations")
+}
+
+// Reads migrations from files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, requiring both halves of every version and
+// versions numbered from 1 without gaps
+func parseMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		filename := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(filename, ".sql"), ".")
+		if !ok || !strings.HasSuffix(filename, ".sql") || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration filename %s", filename)
+		}
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration filename %s", filename)
+		}
+
+		sql, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// LatestVersion returns the schema version this build expects.
+func LatestVersion() int {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func createMigrationsTable(ctx context.Context, db Querier) error {
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp with time zone DEFAULT now() NOT NULL
+	);`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 if no
+// migrations have been applied.
+func SchemaVersion(ctx context.Context, db Querier) (int, error) {
+	var exists bool
+	err := db.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL;").Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	err = db.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations;").Scan(&version)
+	return version, err
+}
+
+// CheckSchemaVersion returns a *SchemaVersionError unless the database has
+// exactly the migrations embedded in this build applied.
+func CheckSchemaVersion(ctx context.Context, db Querier) error {
+	current, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if expected := LatestVersion(); current != expected {
+		return &SchemaVersionError{Current: current, Expected: expected}
+	}
+	return nil
+}
+
+// MigrationStatus lists every embedded migration and when it was applied.
+func MigrationStatus(ctx context.Context, db Querier) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i].Migration = m
+		if appliedAt, ok := applied[m.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+func appliedMigrations(ctx context.Context, db Querier) (map[int]time.Time, error) {
+	if err := createMigrationsTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, "SELECT version, applied_at FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Holds the migration lock on conn while fn runs
+func withMigrationLock(ctx context.Context, conn *pgx.Conn, fn func() error) (err error) {
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1);", migrationLockKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, unlockErr := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1);", migrationLockKey)
+		err = errors.Join(err, unlockErr)
+	}()
+	return fn()
+}
+
+func inTransaction(sql string) bool {
+	return !strings.HasPrefix(sql, noTransactionMarker)
+}
+
+// Runs a migration's SQL and records it in schema_migrations, together in
+// one transaction unless the SQL is marked to run outside of one
+func runMigration(ctx context.Context, conn *pgx.Conn, sql string, record string, args ...any) error {
+	if !inTransaction(sql) {
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			return err
+		}
+		_, err := conn.Exec(ctx, record, args...)
+		return err
+	}
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, args...)
+		return err
+	})
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction unless marked to run outside of one, returning those applied.
+func MigrateUp(ctx context.Context, conn *pgx.Conn) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withMigrationLock(ctx, conn, func() error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := runMigration(ctx, conn, m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2);", m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts up to steps of the most recently applied migrations,
+// each in its own transaction unless marked to run outside of one, returning
+// those reverted.
+func MigrateDown(ctx context.Context, conn *pgx.Conn, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withMigrationLock(ctx, conn, func() error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			err := runMigration(ctx, conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1;", m.Version)
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	if LatestVersion() != len(migrations) {
+		t.Errorf("got latest version %d, expected %d", LatestVersion(), len(migrations))
+	}
+}
+
+func TestParseMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_b.up.sql":   {Data: []byte("up b")},
+		"m/0002_b.down.sql": {Data: []byte("down b")},
+		"m/0001_a.up.sql":   {Data: []byte("up a")},
+		"m/0001_a.down.sql": {Data: []byte("down a")},
+	}
+	migrations, err := parseMigrations(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("got %d migrations, expected 2", len(migrations))
+	}
+	want := Migration{Version: 1, Name: "a", Up: "up a", Down: "down a"}
+	if migrations[0] != want {
+		t.Errorf("got %+v, expected %+v", migrations[0], want)
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "b" {
+		t.Errorf("got %+v, expected version 2 b", migrations[1])
+	}
+}
+
+func TestParseMigrationsInvalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"m/0001_a.up.sql": {Data: []byte("up")},
+		},
+		"gap": {
+			"m/0001_a.up.sql":   {Data: []byte("up")},
+			"m/0001_a.down.sql": {Data: []byte("down")},
+			"m/0003_c.up.sql":   {Data: []byte("up")},
+			"m/0003_c.down.sql": {Data: []byte("down")},
+		},
+		"bad name": {
+			"m/initial.up.sql": {Data: []byte("up")},
+		},
+		"bad direction": {
+			"m/0001_a.sideways.sql": {Data: []byte("up")},
+		},
+		"conflicting names": {
+			"m/0001_a.up.sql":   {Data: []byte("up")},
+			"m/0001_b.down.sql": {Data: []byte("down")},
+		},
+	}
+	for name, fsys := range tests {
+		if _, err := parseMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestInTransaction(t *testing.T) {
+	if !inTransaction("CREATE TABLE a (id integer);") {
+		t.Error("expected a migration to run in a transaction by default")
+	}
+	if inTransaction(noTransactionMarker + "\nDO $$ BEGIN COMMIT; END $$;") {
+		t.Error("expected a marked migration to run outside a transaction")
+	}
+}
+
+func TestSchemaVersionError(t *testing.T) {
+	older := &SchemaVersionError{Current: 1, Expected: 2}
+	newer := &SchemaVersionError{Current: 3, Expected: 2}
+	if older.Error() == newer.Error() {
+		t.Error("expected different messages for older and newer schemas")
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS pings;
+DROP TABLE IF EXISTS monitor;
+DROP TABLE IF EXISTS requests;
+DROP TABLE IF EXISTS user_agents;
+DROP TABLE IF EXISTS users;
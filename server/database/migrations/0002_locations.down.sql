@@ -0,0 +1,5 @@
+ALTER TABLE requests
+    DROP COLUMN IF EXISTS region,
+    DROP COLUMN IF EXISTS city,
+    DROP COLUMN IF EXISTS asn,
+    DROP COLUMN IF EXISTS organisation;
@@ -0,0 +1,5 @@
+ALTER TABLE requests
+    ADD COLUMN IF NOT EXISTS region varchar(255),
+    ADD COLUMN IF NOT EXISTS city varchar(255),
+    ADD COLUMN IF NOT EXISTS asn bigint,
+    ADD COLUMN IF NOT EXISTS organisation varchar(255);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS plans (
+    name varchar(64) NOT NULL PRIMARY KEY,
+    rows_per_minute integer NOT NULL,
+    rows_per_day integer NOT NULL,
+    retention_rows integer NOT NULL,
+    monitor_count integer NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS quotas (
+    api_key uuid NOT NULL PRIMARY KEY,
+    plan varchar(64) DEFAULT 'default' NOT NULL REFERENCES plans (name),
+    rows_per_minute integer,
+    rows_per_day integer,
+    retention_rows integer,
+    monitor_count integer,
+    updated_at timestamp with time zone
+);
+
+INSERT INTO plans (name, rows_per_minute, rows_per_day, retention_rows, monitor_count)
+VALUES ('default', 2000, 1500000, 1500000, 3)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,8 @@
+ALTER TABLE user_agents
+    DROP COLUMN IF EXISTS browser,
+    DROP COLUMN IF EXISTS browser_version,
+    DROP COLUMN IF EXISTS os,
+    DROP COLUMN IF EXISTS os_version,
+    DROP COLUMN IF EXISTS device_type,
+    DROP COLUMN IF EXISTS is_bot,
+    DROP COLUMN IF EXISTS bot_name;
@@ -0,0 +1,8 @@
+ALTER TABLE user_agents
+    ADD COLUMN IF NOT EXISTS browser varchar(64),
+    ADD COLUMN IF NOT EXISTS browser_version varchar(32),
+    ADD COLUMN IF NOT EXISTS os varchar(64),
+    ADD COLUMN IF NOT EXISTS os_version varchar(32),
+    ADD COLUMN IF NOT EXISTS device_type varchar(16),
+    ADD COLUMN IF NOT EXISTS is_bot boolean,
+    ADD COLUMN IF NOT EXISTS bot_name varchar(64);
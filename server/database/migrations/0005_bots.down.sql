@@ -0,0 +1,3 @@
+ALTER TABLE quotas DROP COLUMN IF EXISTS drop_bots;
+ALTER TABLE plans DROP COLUMN IF EXISTS drop_bots;
+ALTER TABLE requests DROP COLUMN IF EXISTS is_bot;
@@ -0,0 +1,3 @@
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS is_bot boolean DEFAULT false NOT NULL;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS drop_bots boolean DEFAULT false NOT NULL;
+ALTER TABLE quotas ADD COLUMN IF NOT EXISTS drop_bots boolean;
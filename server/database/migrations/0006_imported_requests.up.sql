@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS imported_requests (
+    api_key uuid NOT NULL,
+    fingerprint bytea NOT NULL,
+    imported_at timestamp with time zone,
+    PRIMARY KEY (api_key, fingerprint)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ingested_batches;
+DROP INDEX IF EXISTS requests_client_request_id_index;
+ALTER TABLE requests DROP COLUMN IF EXISTS client_request_id;
@@ -0,0 +1,11 @@
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS client_request_id varchar(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS requests_client_request_id_index ON requests (api_key, client_request_id)
+    WHERE client_request_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS ingested_batches (
+    api_key uuid NOT NULL,
+    batch_id varchar(64) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (api_key, batch_id)
+);
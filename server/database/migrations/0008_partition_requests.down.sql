@@ -0,0 +1,41 @@
+ALTER TABLE requests RENAME TO requests_partitioned;
+ALTER INDEX requests_pkey RENAME TO requests_partitioned_pkey;
+ALTER INDEX requests_client_request_id_index RENAME TO requests_partitioned_client_request_id_index;
+
+ALTER TABLE requests_partitioned ALTER COLUMN request_id DROP DEFAULT;
+ALTER SEQUENCE requests_request_id_seq OWNED BY NONE;
+
+CREATE TABLE requests (
+    request_id integer NOT NULL DEFAULT nextval('requests_request_id_seq') PRIMARY KEY,
+    api_key uuid NOT NULL,
+    method smallint NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    path varchar(255) NOT NULL,
+    status smallint NOT NULL,
+    response_time smallint NOT NULL,
+    framework smallint NOT NULL,
+    hostname varchar(255),
+    ip_address cidr,
+    location varchar(2),
+    user_id varchar(255),
+    user_agent_id integer REFERENCES user_agents (id),
+    region varchar(255),
+    city varchar(255),
+    asn bigint,
+    organisation varchar(255),
+    is_bot boolean DEFAULT false NOT NULL,
+    client_request_id varchar(64)
+);
+
+INSERT INTO requests (request_id, api_key, method, created_at, path, status, response_time, framework, hostname, ip_address, location, user_id, user_agent_id, region, city, asn, organisation, is_bot, client_request_id)
+SELECT request_id, api_key, method, created_at, path, status, response_time, framework, hostname, ip_address, location, user_id, user_agent_id, region, city, asn, organisation, is_bot, client_request_id
+FROM requests_partitioned;
+
+-- Fails if more IDs have been issued than an integer can hold
+ALTER SEQUENCE requests_request_id_seq AS integer OWNED BY requests.request_id;
+
+CREATE INDEX api_key_index ON requests USING hash (api_key);
+CREATE UNIQUE INDEX requests_client_request_id_index ON requests (api_key, client_request_id)
+    WHERE client_request_id IS NOT NULL;
+
+DROP TABLE requests_partitioned;
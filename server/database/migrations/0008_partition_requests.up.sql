@@ -0,0 +1,77 @@
+-- Partitions requests by month of created_at. Partitions are named
+-- requests_pYYYYMM and cover months in UTC. Rows outside every partition are
+-- stored in requests_default until a partition is created for them.
+SET LOCAL TIME ZONE 'UTC';
+
+ALTER TABLE requests RENAME TO requests_unpartitioned;
+ALTER INDEX requests_pkey RENAME TO requests_unpartitioned_pkey;
+ALTER INDEX IF EXISTS requests_client_request_id_index RENAME TO requests_unpartitioned_client_request_id_index;
+DROP INDEX IF EXISTS api_key_index;
+
+-- Keep issuing IDs from the existing sequence, widened so it cannot overflow
+ALTER TABLE requests_unpartitioned ALTER COLUMN request_id DROP DEFAULT;
+ALTER SEQUENCE requests_request_id_seq AS bigint OWNED BY NONE;
+
+CREATE TABLE requests (
+    request_id bigint NOT NULL DEFAULT nextval('requests_request_id_seq'),
+    api_key uuid NOT NULL,
+    method smallint NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    path varchar(255) NOT NULL,
+    status smallint NOT NULL,
+    response_time smallint NOT NULL,
+    framework smallint NOT NULL,
+    hostname varchar(255),
+    ip_address cidr,
+    location varchar(2),
+    user_id varchar(255),
+    user_agent_id integer REFERENCES user_agents (id),
+    region varchar(255),
+    city varchar(255),
+    asn bigint,
+    organisation varchar(255),
+    is_bot boolean DEFAULT false NOT NULL,
+    client_request_id varchar(64),
+    PRIMARY KEY (request_id, created_at)
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE requests_request_id_seq OWNED BY requests.request_id;
+
+CREATE INDEX requests_api_key_created_at_index ON requests (api_key, created_at);
+
+-- Unique indexes on a partitioned table must include the partition key.
+-- Retried requests are resent with the same timestamp, so are still skipped.
+CREATE UNIQUE INDEX requests_client_request_id_index ON requests (api_key, client_request_id, created_at)
+    WHERE client_request_id IS NOT NULL;
+
+CREATE TABLE requests_default PARTITION OF requests DEFAULT;
+
+-- A partition for every month holding requests, and the next three months.
+-- Requests over ten years old, such as those from clients with broken clocks,
+-- are left in requests_default rather than creating a partition per month.
+DO $$
+DECLARE
+    month timestamp with time zone;
+BEGIN
+    FOR month IN
+        SELECT generate_series(
+            date_trunc('month', GREATEST(
+                COALESCE((SELECT min(created_at) FROM requests_unpartitioned), now()),
+                now() - interval '10 years'
+            )),
+            date_trunc('month', now()) + interval '3 months',
+            interval '1 month'
+        )
+    LOOP
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF requests FOR VALUES FROM (%L) TO (%L)',
+            'requests_p' || to_char(month, 'YYYYMM'), month, month + interval '1 month'
+        );
+    END LOOP;
+END $$;
+
+INSERT INTO requests (request_id, api_key, method, created_at, path, status, response_time, framework, hostname, ip_address, location, user_id, user_agent_id, region, city, asn, organisation, is_bot, client_request_id)
+SELECT request_id, api_key, method, created_at, path, status, response_time, framework, hostname, ip_address, location, user_id, user_agent_id, region, city, asn, organisation, is_bot, client_request_id
+FROM requests_unpartitioned;
+
+DROP TABLE requests_unpartitioned;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS rollup_pending;
+DROP TABLE IF EXISTS request_rollups;
+DROP AGGREGATE IF EXISTS sketch_merge_agg(jsonb);
+DROP FUNCTION IF EXISTS sketch_merge(jsonb, jsonb);
@@ -0,0 +1,53 @@
+-- Latency sketches map each bin index to the number of response times in it,
+-- where a response time t falls in bin ceil(log(t) / log(1.02 / 0.98)), or 0
+-- for t <= 1. Sketches are merged by adding the counts of matching bins.
+CREATE FUNCTION sketch_merge(a jsonb, b jsonb) RETURNS jsonb
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT COALESCE(jsonb_object_agg(bin, total), '{}'::jsonb)
+    FROM (
+        SELECT bin, sum(n::bigint) AS total
+        FROM (
+            SELECT * FROM jsonb_each_text(COALESCE(a, '{}'::jsonb))
+            UNION ALL
+            SELECT * FROM jsonb_each_text(COALESCE(b, '{}'::jsonb))
+        ) AS bins (bin, n)
+        GROUP BY bin
+    ) AS merged
+$$;
+
+CREATE AGGREGATE sketch_merge_agg(jsonb) (
+    SFUNC = sketch_merge,
+    STYPE = jsonb,
+    INITCOND = '{}'
+);
+
+-- Request counts and latency sketches per API key, hour or day (in UTC),
+-- hostname and bot flag, broken down by the value of each dimension. The
+-- total dimension has an empty value and counts every request.
+CREATE TABLE request_rollups (
+    api_key uuid NOT NULL,
+    period varchar(4) NOT NULL,
+    bucket timestamp with time zone NOT NULL,
+    hostname varchar(255) NOT NULL,
+    bot boolean NOT NULL,
+    dimension varchar(16) NOT NULL,
+    value varchar(255) NOT NULL,
+    count bigint NOT NULL,
+    sketch jsonb NOT NULL,
+    PRIMARY KEY (api_key, period, bucket, hostname, bot, dimension, value)
+);
+
+-- Hours with requests stored since their rollups were last rebuilt. The
+-- version is incremented whenever an hour is marked again, so a rebuild only
+-- clears the mark if no requests arrived while it ran.
+CREATE TABLE rollup_pending (
+    api_key uuid NOT NULL,
+    hour timestamp with time zone NOT NULL,
+    version bigint DEFAULT 1 NOT NULL,
+    PRIMARY KEY (api_key, hour)
+);
+
+-- Existing requests are rolled up by the logger in the background
+INSERT INTO rollup_pending (api_key, hour)
+SELECT DISTINCT api_key, date_trunc('hour', created_at, 'UTC')
+FROM requests;
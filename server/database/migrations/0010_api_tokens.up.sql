@@ -0,0 +1,17 @@
+-- Scoped tokens granting access to an account, identified by its API key.
+-- Only a SHA-256 hash of each token is stored, with its start kept as a hint
+-- to tell tokens apart.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id bigserial PRIMARY KEY,
+    api_key uuid NOT NULL,
+    name varchar(64) NOT NULL,
+    scope varchar(16) NOT NULL,
+    hint varchar(16) NOT NULL,
+    token_hash bytea NOT NULL UNIQUE,
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    revoked_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_api_key_index ON api_tokens (api_key);
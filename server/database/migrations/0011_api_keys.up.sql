@@ -0,0 +1,20 @@
+-- API keys mapped to the account they grant access to, so a key can be
+-- replaced without moving the account's data. Accounts are identified by the
+-- API key they were created with, held in the api_key column of every table
+-- storing account data, but only keys in this table are accepted. Keys are
+-- stored as a SHA-256 hash of their lowercase form.
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_hash bytea PRIMARY KEY,
+    account_id uuid NOT NULL,
+    hint varchar(16) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone -- Set when the key is rotated
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_account_id_index ON api_keys (account_id);
+
+-- Every existing account keeps the key it was created with
+INSERT INTO api_keys (key_hash, account_id, hint, created_at)
+SELECT sha256(convert_to(api_key::text, 'UTF8')), api_key, left(api_key::text, 8), COALESCE(created_at, NOW())
+FROM users
+ON CONFLICT (key_hash) DO NOTHING;
@@ -0,0 +1 @@
+ALTER TABLE user_agents DROP COLUMN IF EXISTS parsed;
@@ -0,0 +1,2 @@
+ALTER TABLE user_agents ADD COLUMN IF NOT EXISTS parsed boolean DEFAULT false NOT NULL;
+UPDATE user_agents SET parsed = true WHERE device_type IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Accounts cannot be moved back onto the key they were created with, as keys
+-- are only stored hashed
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 13 cannot be reverted: API keys are only stored hashed';
+END $$;
@@ -0,0 +1,39 @@
+-- migrate:no-transaction
+-- Gives every account a random ID in place of the API key it was created
+-- with, so keys are only stored hashed in api_keys. Requests are rewritten a
+-- partition at a time, and every other table at once, committing after each.
+-- The mapping from keys to IDs is kept until the end, so an interrupted run
+-- carries on where it stopped.
+DO $$
+DECLARE
+    part regclass;
+    tbl text;
+BEGIN
+    IF to_regclass('account_ids') IS NULL THEN
+        CREATE TABLE account_ids (
+            api_key uuid PRIMARY KEY,
+            account_id uuid NOT NULL
+        );
+        INSERT INTO account_ids (api_key, account_id)
+        SELECT api_key, gen_random_uuid() FROM users;
+        COMMIT;
+    END IF;
+
+    FOR part IN SELECT inhrelid::regclass FROM pg_inherits WHERE inhparent = 'requests'::regclass LOOP
+        EXECUTE format('UPDATE %s t SET api_key = a.account_id FROM account_ids a WHERE t.api_key = a.api_key', part);
+        COMMIT;
+    END LOOP;
+
+    FOREACH tbl IN ARRAY ARRAY['monitor', 'pings', 'quotas', 'imported_requests', 'ingested_batches', 'request_rollups', 'rollup_pending', 'api_tokens'] LOOP
+        EXECUTE format('UPDATE %I t SET api_key = a.account_id FROM account_ids a WHERE t.api_key = a.api_key', tbl);
+        COMMIT;
+    END LOOP;
+
+    UPDATE api_keys k SET account_id = a.account_id FROM account_ids a WHERE k.account_id = a.api_key;
+    COMMIT;
+
+    -- Accounts are moved in the same transaction the mapping is dropped in,
+    -- so a run interrupted before this point maps the same accounts again
+    UPDATE users u SET api_key = a.account_id FROM account_ids a WHERE u.api_key = a.api_key;
+    DROP TABLE account_ids;
+END $$;
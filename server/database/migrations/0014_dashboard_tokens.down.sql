@@ -0,0 +1,5 @@
+-- Stored IDs are only hashed, so accounts are given new user IDs
+ALTER TABLE users ADD COLUMN IF NOT EXISTS user_id uuid DEFAULT gen_random_uuid() NOT NULL;
+ALTER TABLE users ALTER COLUMN user_id DROP DEFAULT;
+
+DELETE FROM api_tokens WHERE name = 'Dashboard' AND scope = 'dashboard' AND hint NOT LIKE 'aat_%';
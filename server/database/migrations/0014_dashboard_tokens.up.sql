@@ -0,0 +1,9 @@
+-- Dashboard user IDs become dashboard-scoped tokens, so they are only stored
+-- hashed. IDs are hashed in lowercase, as API keys are, and keep working in
+-- dashboard links.
+INSERT INTO api_tokens (api_key, name, scope, hint, token_hash, created_at)
+SELECT api_key, 'Dashboard', 'dashboard', left(user_id::text, 8), sha256(convert_to(lower(user_id::text), 'UTF8')), COALESCE(created_at, NOW())
+FROM users
+ON CONFLICT (token_hash) DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Requests are partitioned by month of created_at in UTC, in partitions named
+// requests_pYYYYMM. Rows outside every partition are held in requests_default.
+const (
+	partitionPrefix  = "requests_p"
+	partitionLayout  = "200601"
+	defaultPartition = "requests_default"
+)
+
+// Arbitrary key for the advisory lock held while changing partitions, so
+// several loggers can maintain partitions at once
+const partitionLockKey = 7265436102
+
+// Partition is a monthly partition of the requests table covering
+// [From, To).
+type Partition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// MonthPartition returns the partition holding requests created at t.
+func MonthPartition(t time.Time) Partition {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Partition{
+		Name: partitionPrefix + from.Format(partitionLayout),
+		From: from,
+		To:   from.AddDate(0, 1, 0),
+	}
+}
+
+// Reads the month covered by a partition from its name
+func parsePartition(name string) (Partition, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok {
+		return Partition{}, false
+	}
+	month, err := time.Parse(partitionLayout, suffix)
+	if err != nil {
+		return Partition{}, false
+	}
+	partition := MonthPartition(month)
+	return partition, partition.Name == name
+}
+
+// RequestPartitions lists the monthly partitions of the requests table, oldest
+// first.
+func RequestPartitions(ctx context.Context, db Querier) ([]Partition, error) {
+	query := `SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'requests'::regclass;`
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	partitions := make([]Partition, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if partition, ok := parsePartition(name); ok {
+			partitions = append(partitions, partition)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].From.Before(partitions[j].From)
+	})
+	return partitions, nil
+}
+
+func timestampLiteral(t time.Time) string {
+	return "'" + t.UTC().Format(time.RFC3339) + "'"
+}
+
+// CreatePartitions creates any missing partitions from the month of now to
+// monthsAhead months later, returning those created. Requests already held
+// in the default partition for a new month are moved into it.
+func CreatePartitions(ctx context.Context, db DB, now time.Time, monthsAhead int) ([]Partition, error) {
+	existing, err := RequestPartitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, partition := range existing {
+		exists[partition.Name] = true
+	}
+
+	created := make([]Partition, 0)
+	for i := 0; i <= monthsAhead; i++ {
+		partition := MonthPartition(now.UTC().AddDate(0, i, 0))
+		if exists[partition.Name] {
+			continue
+		}
+		ok, err := createPartition(ctx, db, partition)
+		if err != nil {
+			return created, fmt.Errorf("failed to create partition %s: %w", partition.Name, err)
+		}
+		if ok {
+			created = append(created, partition)
+		}
+	}
+	return created, nil
+}
+
+// Creates a partition as a standalone table, moves any of its rows out of the
+// default partition, then attaches it. Attaching directly would fail if the
+// default partition held rows for the month. Returns false if another
+// process created the partition first.
+func createPartition(ctx context.Context, db DB, partition Partition) (bool, error) {
+	created := false
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1);", partitionLockKey); err != nil {
+			return err
+		}
+		var exists bool
+		err := tx.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL;", partition.Name).Scan(&exists)
+		if err != nil || exists {
+			return err
+		}
+
+		name := pgx.Identifier{partition.Name}.Sanitize()
+		from, to := timestampLiteral(partition.From), timestampLiteral(partition.To)
+
+		// Block inserts into the default partition while its rows are moved
+		_, err = tx.Exec(ctx, "LOCK TABLE "+defaultPartition+" IN SHARE ROW EXCLUSIVE MODE;")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "CREATE TABLE "+name+" (LIKE requests INCLUDING DEFAULTS INCLUDING CONSTRAINTS);")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "WITH moved AS (DELETE FROM "+defaultPartition+" WHERE created_at >= $1 AND created_at < $2 RETURNING *) INSERT INTO "+name+" SELECT * FROM moved;", partition.From, partition.To)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "ALTER TABLE requests ATTACH PARTITION "+name+" FOR VALUES FROM ("+from+") TO ("+to+");")
+		if err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// DropPartitions detaches and drops every partition ending on or before
+// cutoff, and deletes requests before cutoff held in the default partition,
+// returning the partitions dropped. Rollups of the dropped requests are
+// deleted with them.
+func DropPartitions(ctx context.Context, db DB, cutoff time.Time) ([]Partition, error) {
+	existing, err := RequestPartitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped := make([]Partition, 0)
+	for _, partition := range existing {
+		if partition.To.After(cutoff) {
+			break
+		}
+		err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			name := pgx.Identifier{partition.Name}.Sanitize()
+			if _, err := tx.Exec(ctx, "ALTER TABLE requests DETACH PARTITION "+name+";"); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "DROP TABLE "+name+";"); err != nil {
+				return err
+			}
+			return deleteRollupsBefore(ctx, tx, partition.To)
+		})
+		if err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", partition.Name, err)
+		}
+		dropped = append(dropped, partition)
+	}
+
+	err = pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		query := "DELETE FROM " + defaultPartition + " WHERE created_at < $1;"
+		if _, err := tx.Exec(ctx, query, cutoff); err != nil {
+			return err
+		}
+		return deleteRollupsBefore(ctx, tx, cutoff)
+	})
+	return dropped, err
+}
+
+// Deletes rollups, and marks to rebuild them, of hours before cutoff
+func deleteRollupsBefore(ctx context.Context, tx pgx.Tx, cutoff time.Time) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM request_rollups WHERE bucket < $1;", cutoff); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, "DELETE FROM rollup_pending WHERE hour < $1;", cutoff)
+	return err
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthPartition(t *testing.T) {
+	// Just before midnight UTC on the last day of January, but February in
+	// the local zone
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	created := time.Date(2024, 2, 1, 1, 30, 0, 0, zone)
+
+	partition := MonthPartition(created)
+	if partition.Name != "requests_p202401" {
+		t.Errorf("got %s, expected requests_p202401", partition.Name)
+	}
+	if !partition.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got from %s", partition.From)
+	}
+	if !partition.To.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got to %s", partition.To)
+	}
+
+	december := MonthPartition(time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC))
+	if december.Name != "requests_p202412" || december.To.Year() != 2025 {
+		t.Errorf("got %+v, expected December 2024 ending in 2025", december)
+	}
+}
+
+func TestParsePartition(t *testing.T) {
+	partition, ok := parsePartition("requests_p202403")
+	if !ok || !partition.From.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %+v, %v", partition, ok)
+	}
+
+	for _, name := range []string{"requests_default", "requests_p2024", "requests_p202413", "requests_p202403_old", "pings"} {
+		if _, ok := parsePartition(name); ok {
+			t.Errorf("%s: expected not to be a monthly partition", name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Querier is satisfied by a *pgx.Conn, *pgxpool.Pool or pgx.Tx
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Quota holds the limits applied to a single account
+type Quota struct {
+	Plan          string `json:"plan"`
+	RowsPerMinute int    `json:"rows_per_minute"` // Logged requests accepted per minute
+	RowsPerDay    int    `json:"rows_per_day"`    // Logged requests accepted per day
+	RetentionRows int    `json:"retention_rows"`  // Logged requests kept before the oldest are deleted
+	MonitorCount  int    `json:"monitor_count"`   // Monitored URLs allowed
+	DropBots      bool   `json:"drop_bots"`       // Discard requests from bots rather than storing them
+}
+
+const DefaultPlan = "default"
+
+// Used if the default plan is missing from the plans table
+var DefaultQuota = Quota{
+	Plan:          DefaultPlan,
+	RowsPerMinute: 2000,
+	RowsPerDay:    1_500_000,
+	RetentionRows: 1_500_000,
+	MonitorCount:  3,
+}
+
+// QuotaOverrides holds an account's plan and any individual limits that
+// differ from it. Nil fields fall back to the plan.
+type QuotaOverrides struct {
+	Plan          string
+	RowsPerMinute *int
+	RowsPerDay    *int
+	RetentionRows *int
+	MonitorCount  *int
+	DropBots      *bool
+}
+
+// GetQuota returns the limits for an API key, taking any account overrides
+// over its plan, and its plan over the defaults.
+func GetQuota(ctx context.Context, db Querier, apiKey string) (Quota, error) {
+	query := `SELECT p.name,
+		COALESCE(q.rows_per_minute, p.rows_per_minute),
+		COALESCE(q.rows_per_day, p.rows_per_day),
+		COALESCE(q.retention_rows, p.retention_rows),
+		COALESCE(q.monitor_count, p.monitor_count),
+		COALESCE(q.drop_bots, p.drop_bots)
+		FROM plans p LEFT JOIN quotas q ON q.api_key = $1
+		WHERE p.name = COALESCE(q.plan, $2);`
+
+	var quota Quota
+	err := db.QueryRow(ctx, query, apiKey, DefaultPlan).Scan(&quota.Plan, &quota.RowsPerMinute, &quota.RowsPerDay, &quota.RetentionRows, &quota.MonitorCount, &quota.DropBots)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultQuota, nil
+	} else if err != nil {
+		return DefaultQuota, err
+	}
+	return quota, nil
+}
+
+// SetQuota stores an account's plan and overrides, replacing any existing
+// overrides.
+func SetQuota(ctx context.Context, db Querier, apiKey string, overrides QuotaOverrides) error {
+	if overrides.Plan == "" {
+		overrides.Plan = DefaultPlan
+	}
+
+	query := `INSERT INTO quotas (api_key, plan, rows_per_minute, rows_per_day, retention_rows, monitor_count, drop_bots, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (api_key) DO UPDATE SET
+		plan = EXCLUDED.plan,
+		rows_per_minute = EXCLUDED.rows_per_minute,
+		rows_per_day = EXCLUDED.rows_per_day,
+		retention_rows = EXCLUDED.retention_rows,
+		monitor_count = EXCLUDED.monitor_count,
+		drop_bots = EXCLUDED.drop_bots,
+		updated_at = EXCLUDED.updated_at;`
+
+	_, err := db.Exec(ctx, query, apiKey, overrides.Plan, overrides.RowsPerMinute, overrides.RowsPerDay, overrides.RetentionRows, overrides.MonitorCount, overrides.DropBots)
+	return err
+}
+
+// GetQuotaOverrides returns the plan and overrides stored for an account.
+func GetQuotaOverrides(ctx context.Context, db Querier, apiKey string) (QuotaOverrides, error) {
+	query := "SELECT plan, rows_per_minute, rows_per_day, retention_rows, monitor_count, drop_bots FROM quotas WHERE api_key = $1;"
+
+	var overrides QuotaOverrides
+	err := db.QueryRow(ctx, query, apiKey).Scan(&overrides.Plan, &overrides.RowsPerMinute, &overrides.RowsPerDay, &overrides.RetentionRows, &overrides.MonitorCount, &overrides.DropBots)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return QuotaOverrides{Plan: DefaultPlan}, nil
+	}
+	return overrides, err
+}
+
+// DeleteQuota removes an account's overrides, returning it to the default
+// plan.
+func DeleteQuota(ctx context.Context, db Querier, apiKey string) error {
+	query := "DELETE FROM quotas WHERE api_key = $1;"
+	_, err := db.Exec(ctx, query, apiKey)
+	return err
+}
+
+// SetPlan creates or updates a named plan.
+func SetPlan(ctx context.Context, db Querier, plan Quota) error {
+	query := `INSERT INTO plans (name, rows_per_minute, rows_per_day, retention_rows, monitor_count, drop_bots)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET
+		rows_per_minute = EXCLUDED.rows_per_minute,
+		rows_per_day = EXCLUDED.rows_per_day,
+		retention_rows = EXCLUDED.retention_rows,
+		monitor_count = EXCLUDED.monitor_count,
+		drop_bots = EXCLUDED.drop_bots;`
+
+	_, err := db.Exec(ctx, query, plan.Plan, plan.RowsPerMinute, plan.RowsPerDay, plan.RetentionRows, plan.MonitorCount, plan.DropBots)
+	return err
+}
+
+// GetPlans returns all available plans.
+func GetPlans(ctx context.Context, db Querier) ([]Quota, error) {
+	query := "SELECT name, rows_per_minute, rows_per_day, retention_rows, monitor_count, drop_bots FROM plans ORDER BY name;"
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := make([]Quota, 0)
+	for rows.Next() {
+		var plan Quota
+		if err := rows.Scan(&plan.Plan, &plan.RowsPerMinute, &plan.RowsPerDay, &plan.RetentionRows, &plan.MonitorCount, &plan.DropBots); err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+// MinRetentionRows returns the smallest retention limit of any plan or
+// account, below which no account can be over its quota.
+func MinRetentionRows(ctx context.Context, db Querier) (int, error) {
+	query := "SELECT COALESCE(LEAST((SELECT MIN(retention_rows) FROM plans), (SELECT MIN(retention_rows) FROM quotas)), $1);"
+
+	var rows int
+	err := db.QueryRow(ctx, query, DefaultQuota.RetentionRows).Scan(&rows)
+	return rows, err
+}
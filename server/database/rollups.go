@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Rollup periods
+const (
+	PeriodHour = "hour"
+	PeriodDay  = "day"
+)
+
+// Dimensions requests are counted by in rollups, besides the total
+const (
+	DimensionTotal     = "total"
+	DimensionPath      = "path"
+	DimensionMethod    = "method"
+	DimensionStatus    = "status"
+	DimensionLocation  = "location"
+	DimensionUserAgent = "user_agent"
+)
+
+// Rebuilds an account's hourly rollups for the hour starting at $2 from its
+// stored requests, binning response times as Sketch does
+const rollupHourQuery = `WITH binned AS (
+		SELECT hostname, is_bot, path, method, status, location, user_agent_id,
+			CASE WHEN response_time <= 1 THEN 0
+				ELSE ceil(ln(response_time::double precision) / ln(1.02::double precision / 0.98))::int
+			END AS bin
+		FROM requests
+		WHERE api_key = $1 AND created_at >= $2::timestamptz AND created_at < $2::timestamptz + interval '1 hour'
+	), bins AS (
+		SELECT COALESCE(hostname, '') AS hostname, is_bot, d.dimension, d.value, bin, count(*) AS n
+		FROM binned CROSS JOIN LATERAL (VALUES
+			('total', ''),
+			('path', path),
+			('method', method::text),
+			('status', status::text),
+			('location', COALESCE(location, '')),
+			('user_agent', COALESCE(user_agent_id::text, ''))
+		) AS d (dimension, value)
+		GROUP BY 1, 2, 3, 4, 5
+	)
+	INSERT INTO request_rollups (api_key, period, bucket, hostname, bot, dimension, value, count, sketch)
+	SELECT $1, 'hour', $2, hostname, is_bot, dimension, value, sum(n)::bigint, jsonb_object_agg(bin, n)
+	FROM bins
+	GROUP BY hostname, is_bot, dimension, value;`
+
+// Rebuilds an account's daily rollups for the day starting at $2 from its
+// hourly rollups
+const rollupDayQuery = `INSERT INTO request_rollups (api_key, period, bucket, hostname, bot, dimension, value, count, sketch)
+	SELECT api_key, 'day', $2, hostname, bot, dimension, value, sum(count)::bigint, sketch_merge_agg(sketch)
+	FROM request_rollups
+	WHERE api_key = $1 AND period = 'hour' AND bucket >= $2::timestamptz AND bucket < $2::timestamptz + interval '1 day'
+	GROUP BY api_key, hostname, bot, dimension, value;`
+
+// MarkRollups records the hours of requests just stored so their rollups are
+// rebuilt. Timestamps are parsed by Postgres in the same way as when the
+// requests were inserted. Run as the last statement of the transaction
+// storing the requests, so requests are never stored unmarked and the marks'
+// row locks are only held while it commits.
+func MarkRollups(ctx context.Context, db Querier, apiKeys []string, createdAt []string) error {
+	if len(apiKeys) == 0 {
+		return nil
+	}
+	// Ordered to take row locks in the same order as concurrent inserts
+	query := `INSERT INTO rollup_pending (api_key, hour)
+		SELECT DISTINCT k::uuid, date_trunc('hour', t::timestamptz, 'UTC') FROM unnest($1::text[], $2::text[]) AS r(k, t)
+		ORDER BY 1, 2
+		ON CONFLICT (api_key, hour) DO UPDATE SET version = rollup_pending.version + 1;`
+	_, err := db.Exec(ctx, query, apiKeys, createdAt)
+	return err
+}
+
+// DeleteOldestRequests deletes an account's count oldest requests, marking
+// the hours they were stored in so their rollups are rebuilt without them.
+func DeleteOldestRequests(ctx context.Context, db Querier, apiKey string, count int) error {
+	// Matching on the full key lets each row be found within its partition
+	query := `WITH deleted AS (
+			DELETE FROM requests WHERE (request_id, created_at) IN (SELECT request_id, created_at FROM requests WHERE api_key = $1 ORDER BY created_at LIMIT $2)
+			RETURNING api_key, created_at
+		)
+		INSERT INTO rollup_pending (api_key, hour)
+		SELECT DISTINCT api_key, date_trunc('hour', created_at, 'UTC') FROM deleted
+		ORDER BY 1, 2
+		ON CONFLICT (api_key, hour) DO UPDATE SET version = rollup_pending.version + 1;`
+	_, err := db.Exec(ctx, query, apiKey, count)
+	return err
+}
+
+type pendingRollup struct {
+	apiKey  string
+	hour    time.Time
+	version int64
+}
+
+// RefreshRollups rebuilds the rollups of up to limit marked hours, oldest
+// first, returning the number rebuilt.
+func RefreshRollups(ctx context.Context, db DB, limit int) (int, error) {
+	query := "SELECT api_key::text, hour, version FROM rollup_pending ORDER BY hour LIMIT $1;"
+	rows, err := db.Query(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	pending, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pendingRollup, error) {
+		var p pendingRollup
+		err := row.Scan(&p.apiKey, &p.hour, &p.version)
+		return p, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rebuilt := 0
+	for _, p := range pending {
+		ok, err := rebuildRollups(ctx, db, p)
+		if err != nil {
+			return rebuilt, err
+		}
+		if ok {
+			rebuilt++
+		}
+	}
+	return rebuilt, nil
+}
+
+// Rebuilds the hourly rollups of a marked hour and the daily rollups of its
+// day, clearing the mark unless the hour was marked again meanwhile. Returns
+// false if another process is rebuilding the same day.
+func rebuildRollups(ctx context.Context, db DB, p pendingRollup) (bool, error) {
+	hour := p.hour.UTC()
+	day := time.Date(hour.Year(), hour.Month(), hour.Day(), 0, 0, 0, 0, time.UTC)
+
+	rebuilt := false
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		var locked bool
+		err := tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock(hashtextextended($1 || extract(epoch FROM $2::timestamptz)::text, 0));", p.apiKey, day).Scan(&locked)
+		if err != nil || !locked {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM request_rollups WHERE api_key = $1 AND period = 'hour' AND bucket = $2;", p.apiKey, hour)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, rollupHourQuery, p.apiKey, hour); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "DELETE FROM request_rollups WHERE api_key = $1 AND period = 'day' AND bucket = $2;", p.apiKey, day)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, rollupDayQuery, p.apiKey, day); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM rollup_pending WHERE api_key = $1 AND hour = $2 AND version = $3;", p.apiKey, p.hour, p.version)
+		rebuilt = err == nil
+		return err
+	})
+	return rebuilt, err
+}
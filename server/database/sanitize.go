@@ -2,45 +2,198 @@ package database
 
 import (
 	"net"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum lengths, in characters, of the logged request columns
+const (
+	MaxHostnameLength  = 255
+	MaxPathLength      = 255
+	MaxUserAgentLength = 255
+	MaxUserIDLength    = 255
+)
+
+// ValidationError reports which field of a logged request was rejected and why.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field + ": " + e.Reason
+}
+
+// Reasons a value is rejected
+const (
+	ReasonInvalidUTF8      = "invalid UTF-8"
+	ReasonControlCharacter = "control character"
+	ReasonInvalidHostname  = "not a domain name or IP address"
+	ReasonInvalidPath      = "not a path"
 )
 
 func ValidDate(date time.Time) bool {
 	return !date.IsZero()
 }
 
-func ValidString(value string) bool {
-	value = strings.ToUpper(value)
-	return (!strings.Contains(value, "DROP TABLE") &&
-		!strings.Contains(value, "INSERT") &&
-		!strings.Contains(value, "UPDATE") &&
-		!strings.Contains(value, "SELECT") &&
-		!strings.Contains(value, "--") &&
-		!strings.Contains(value, "'"))
+// CheckString rejects values that cannot be stored or displayed safely:
+// invalid UTF-8, which Postgres refuses, and control characters, which could
+// forge lines in logs and exports.
+func CheckString(field string, value string) error {
+	if !utf8.ValidString(value) {
+		return &ValidationError{field, ReasonInvalidUTF8}
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return &ValidationError{field, ReasonControlCharacter}
+		}
+	}
+	return nil
 }
 
-func ValidHostname(hostname string) bool {
-	return ValidString(hostname)
+// Truncate shortens a value to at most maxLength characters without
+// splitting a multi-byte character.
+func Truncate(value string, maxLength int) string {
+	count := 0
+	for i := range value {
+		if count == maxLength {
+			return value[:i]
+		}
+		count++
+	}
+	return value
 }
 
-func ValidPath(path string) bool {
-	return ValidString(path)
+// NormaliseHostname lowercases a hostname and removes any trailing dot,
+// rejecting anything other than a domain name or IP address with an optional
+// port. An empty hostname is allowed.
+func NormaliseHostname(hostname string) (string, error) {
+	if hostname == "" {
+		return "", nil
+	}
+	if err := CheckString("hostname", hostname); err != nil {
+		return "", err
+	}
+	if len(hostname) > MaxHostnameLength {
+		return "", &ValidationError{"hostname", ReasonInvalidHostname}
+	}
+
+	host, port := hostname, ""
+	if h, p, err := net.SplitHostPort(hostname); err == nil {
+		host, port = h, p
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return "", &ValidationError{"hostname", ReasonInvalidHostname}
+		}
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) == nil && !validDomain(host) {
+		return "", &ValidationError{"hostname", ReasonInvalidHostname}
+	}
+	if port == "" {
+		return host, nil
+	}
+	return net.JoinHostPort(host, port), nil
 }
 
-func ValidUserAgent(userAgent string) bool {
-	return ValidString(userAgent)
+// Reports whether a lowercased name is made of valid DNS labels, allowing
+// underscores as used by some internal hostnames
+func validDomain(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NormalisePath ensures a path is absolute, collapses repeated slashes and
+// resolves dot segments so equivalent paths are counted together, then
+// truncates it to MaxPathLength characters. Any trailing slash or query
+// string is kept.
+func NormalisePath(path string) (string, error) {
+	if err := CheckString("path", path); err != nil {
+		return "", err
+	}
+	if path == "" {
+		return "/", nil
+	}
+	if path == "*" {
+		// Server-wide OPTIONS requests
+		return path, nil
+	}
+	if strings.Contains(path, "://") {
+		return "", &ValidationError{"path", ReasonInvalidPath}
+	}
+
+	query := ""
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+
+	trailingSlash := strings.HasSuffix(path, "/")
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(path, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+
+	normalised := "/" + strings.Join(segments, "/")
+	if trailingSlash && len(segments) > 0 {
+		normalised += "/"
+	}
+	return Truncate(normalised+query, MaxPathLength), nil
+}
+
+// NormaliseUserAgent truncates a user agent to MaxUserAgentLength characters.
+func NormaliseUserAgent(userAgent string) (string, error) {
+	if err := CheckString("user_agent", userAgent); err != nil {
+		return "", err
+	}
+	return Truncate(userAgent, MaxUserAgentLength), nil
 }
 
-func ValidUserID(userID string) bool {
-	return ValidString(userID)
+// NormaliseUserID truncates a custom user ID to MaxUserIDLength characters.
+func NormaliseUserID(userID string) (string, error) {
+	if err := CheckString("user_id", userID); err != nil {
+		return "", err
+	}
+	return Truncate(userID, MaxUserIDLength), nil
 }
 
+// ValidLocation reports whether a location is a two letter country code.
 func ValidLocation(location string) bool {
-	return (len(location) == 2 &&
-		!strings.Contains(location, "--") &&
-		!strings.Contains(location, ";") &&
-		!strings.Contains(location, "'"))
+	if len(location) != 2 {
+		return false
+	}
+	for i := 0; i < len(location); i++ {
+		c := location[i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	return true
 }
 
 func ValidStatus(status int) bool {
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckString(t *testing.T) {
+	expecteds := []struct {
+		value  string
+		reason string
+	}{
+		{"/api/update-profile", ""},
+		{"SELECT * FROM users; DROP TABLE users; --", ""},
+		{"O'Brien", ""},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", ""},
+		{"日本語のパス", ""},
+		{"line\nbreak", ReasonControlCharacter},
+		{"null\x00byte", ReasonControlCharacter},
+		{"escape\x1b[31m", ReasonControlCharacter},
+		{"bad\xffutf8", ReasonInvalidUTF8},
+	}
+	for _, expected := range expecteds {
+		err := CheckString("value", expected.value)
+		if expected.reason == "" {
+			if err != nil {
+				t.Errorf("%q: got %v, expected valid", expected.value, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Reason != expected.reason || validationErr.Field != "value" {
+			t.Errorf("%q: got %v, expected %q", expected.value, err, expected.reason)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	expecteds := []struct {
+		value     string
+		maxLength int
+		expected  string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"héllo", 2, "hé"},
+		{"日本語", 2, "日本"},
+		{"", 3, ""},
+	}
+	for _, expected := range expecteds {
+		if got := Truncate(expected.value, expected.maxLength); got != expected.expected {
+			t.Errorf("Truncate(%q, %d) = %q, expected %q", expected.value, expected.maxLength, got, expected.expected)
+		}
+	}
+
+	// Counted in characters rather than bytes, as with varchar columns
+	long := strings.Repeat("é", 300)
+	if got := Truncate(long, MaxPathLength); len([]rune(got)) != MaxPathLength {
+		t.Errorf("got %d characters, expected %d", len([]rune(got)), MaxPathLength)
+	}
+}
+
+func TestNormaliseHostname(t *testing.T) {
+	expecteds := []struct {
+		hostname string
+		expected string
+		valid    bool
+	}{
+		{"", "", true},
+		{"example.com", "example.com", true},
+		{"API.Example.COM.", "api.example.com", true},
+		{"localhost:8080", "localhost:8080", true},
+		{"internal_service", "internal_service", true},
+		{"192.168.0.1", "192.168.0.1", true},
+		{"[::1]:3000", "[::1]:3000", true},
+		{"[::1]", "::1", true},
+		{"::1", "::1", true},
+		{"update.example.com", "update.example.com", true},
+		{"example.com:99999", "", false},
+		{"example.com:", "", false},
+		{"exa mple.com", "", false},
+		{"-example.com", "", false},
+		{"example..com", "", false},
+		{"example.com/path", "", false},
+		{"example.com\r\nX-Injected: 1", "", false},
+		{strings.Repeat("a", 64) + ".com", "", false},
+		{strings.Repeat("a.", 150) + "com", "", false},
+	}
+	for _, expected := range expecteds {
+		got, err := NormaliseHostname(expected.hostname)
+		if expected.valid && (err != nil || got != expected.expected) {
+			t.Errorf("%q: got %q, %v, expected %q", expected.hostname, got, err, expected.expected)
+		} else if !expected.valid && err == nil {
+			t.Errorf("%q: got %q, expected error", expected.hostname, got)
+		}
+	}
+}
+
+func TestNormalisePath(t *testing.T) {
+	expecteds := []struct {
+		path     string
+		expected string
+		valid    bool
+	}{
+		{"/api/update-profile", "/api/update-profile", true},
+		{"/api/users/select", "/api/users/select", true},
+		{"/search?q=it's", "/search?q=it's", true},
+		{"", "/", true},
+		{"/", "/", true},
+		{"*", "*", true},
+		{"users", "/users", true},
+		{"//users///1", "/users/1", true},
+		{"/users/./1/", "/users/1/", true},
+		{"/a/b/../c", "/a/c", true},
+		{"/../../etc", "/etc", true},
+		{"/files?path=../x", "/files?path=../x", true},
+		{"/page#section", "/page#section", true},
+		{"/ünïcödé", "/ünïcödé", true},
+		{"https://example.com/users", "", false},
+		{"/users\n", "", false},
+		{"/\xff", "", false},
+	}
+	for _, expected := range expecteds {
+		got, err := NormalisePath(expected.path)
+		if expected.valid && (err != nil || got != expected.expected) {
+			t.Errorf("%q: got %q, %v, expected %q", expected.path, got, err, expected.expected)
+		} else if !expected.valid && err == nil {
+			t.Errorf("%q: got %q, expected error", expected.path, got)
+		}
+	}
+
+	got, err := NormalisePath("/" + strings.Repeat("ü", 300))
+	if err != nil || len([]rune(got)) != MaxPathLength {
+		t.Errorf("got %d characters, %v, expected %d", len([]rune(got)), err, MaxPathLength)
+	}
+}
+
+func TestNormaliseUserAgent(t *testing.T) {
+	userAgent := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	if got, err := NormaliseUserAgent(userAgent); err != nil || got != userAgent {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := NormaliseUserAgent(strings.Repeat("x", 300)); err != nil || len(got) != MaxUserAgentLength {
+		t.Errorf("got %d characters, %v", len(got), err)
+	}
+	if _, err := NormaliseUserAgent("agent\x00"); err == nil {
+		t.Error("expected error for control character")
+	}
+}
+
+func TestNormaliseUserID(t *testing.T) {
+	if got, err := NormaliseUserID("user--1'"); err != nil || got != "user--1'" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := NormaliseUserID("user\t1"); err == nil {
+		t.Error("expected error for control character")
+	}
+}
+
+func TestValidLocation(t *testing.T) {
+	for location, expected := range map[string]bool{
+		"GB":  true,
+		"us":  true,
+		"":    false,
+		"G":   false,
+		"GBR": false,
+		"G'":  false,
+		"1A":  false,
+	} {
+		if got := ValidLocation(location); got != expected {
+			t.Errorf("ValidLocation(%q) = %t, expected %t", location, got, expected)
+		}
+	}
+}
+
+func TestValidStatus(t *testing.T) {
+	for status, expected := range map[int]bool{99: false, 100: true, 200: true, 599: true, 600: false, -1: false} {
+		if got := ValidStatus(status); got != expected {
+			t.Errorf("ValidStatus(%d) = %t, expected %t", status, got, expected)
+		}
+	}
+}
+
+func TestValidIPAddress(t *testing.T) {
+	for ipAddress, expected := range map[string]bool{
+		"127.0.0.1":   true,
+		"2001:db8::1": true,
+		"":            false,
+		"256.0.0.1":   false,
+		"localhost":   false,
+	} {
+		if got := ValidIPAddress(ipAddress); got != expected {
+			t.Errorf("ValidIPAddress(%q) = %t, expected %t", ipAddress, got, expected)
+		}
+	}
+}
+
+func TestValidDate(t *testing.T) {
+	if ValidDate(time.Time{}) {
+		t.Error("expected zero date to be invalid")
+	}
+	if !ValidDate(time.Now()) {
+		t.Error("expected current date to be valid")
+	}
+}
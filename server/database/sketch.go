@@ -0,0 +1,76 @@
+package database
+
+import (
+	"math"
+	"sort"
+)
+
+// Ratio between the bounds of consecutive sketch bins, giving quantiles
+// within 2% of the true response time. Must match the binning of response
+// times in rollupHourQuery.
+const sketchGamma = 1.02 / 0.98
+
+// Sketch is a mergeable summary of response times, mapping each bin to the
+// number of response times in it. Response times of at most 1ms share bin 0.
+type Sketch map[int]int64
+
+func sketchBin(responseTime float64) int {
+	if responseTime <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(responseTime) / math.Log(sketchGamma)))
+}
+
+// Add records a response time in milliseconds.
+func (s Sketch) Add(responseTime float64) {
+	s[sketchBin(responseTime)]++
+}
+
+// Merge adds the response times recorded in other.
+func (s Sketch) Merge(other Sketch) {
+	for bin, count := range other {
+		s[bin] += count
+	}
+}
+
+// Count returns the number of response times recorded.
+func (s Sketch) Count() int64 {
+	var count int64
+	for _, n := range s {
+		count += n
+	}
+	return count
+}
+
+// Quantile estimates the q-th quantile of the recorded response times, to
+// the nearest millisecond, or 0 if none are recorded.
+func (s Sketch) Quantile(q float64) float64 {
+	count := s.Count()
+	if count == 0 {
+		return 0
+	}
+
+	bins := make([]int, 0, len(s))
+	for bin := range s {
+		bins = append(bins, bin)
+	}
+	sort.Ints(bins)
+
+	rank := int64(q * float64(count-1))
+	var seen int64
+	for _, bin := range bins {
+		seen += s[bin]
+		if seen > rank {
+			return binValue(bin)
+		}
+	}
+	return binValue(bins[len(bins)-1])
+}
+
+// Midpoint of a bin relative to its bounds
+func binValue(bin int) float64 {
+	if bin <= 0 {
+		return 1
+	}
+	return math.Round(2 * math.Pow(sketchGamma, float64(bin)) / (sketchGamma + 1))
+}
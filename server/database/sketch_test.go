@@ -0,0 +1,66 @@
+package database
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestSketchQuantile(t *testing.T) {
+	s := make(Sketch)
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+	if s.Count() != 1000 {
+		t.Fatalf("got count %d, expected 1000", s.Count())
+	}
+
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99} {
+		expected := q * 1000
+		got := s.Quantile(q)
+		if math.Abs(got-expected)/expected > 0.03 {
+			t.Errorf("q%.2f: got %.0f, expected about %.0f", q, got, expected)
+		}
+	}
+}
+
+func TestSketchSmallValues(t *testing.T) {
+	s := make(Sketch)
+	s.Add(0)
+	s.Add(1)
+	if got := s.Quantile(0.5); got != 1 {
+		t.Errorf("got %.0f, expected 1", got)
+	}
+	if got := make(Sketch).Quantile(0.5); got != 0 {
+		t.Errorf("got %.0f for an empty sketch, expected 0", got)
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	a, b, all := make(Sketch), make(Sketch), make(Sketch)
+	for i := 1; i <= 500; i++ {
+		a.Add(float64(i))
+		all.Add(float64(i))
+	}
+	for i := 501; i <= 1000; i++ {
+		b.Add(float64(i))
+		all.Add(float64(i))
+	}
+	a.Merge(b)
+	for _, q := range []float64{0.5, 0.99} {
+		if a.Quantile(q) != all.Quantile(q) {
+			t.Errorf("q%.2f: merged %.0f, expected %.0f", q, a.Quantile(q), all.Quantile(q))
+		}
+	}
+}
+
+func TestSketchJSON(t *testing.T) {
+	// Sketches are stored as JSON objects keyed by bin, as built by Postgres
+	var s Sketch
+	if err := json.Unmarshal([]byte(`{"0": 2, "100": 3}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s[0] != 2 || s[100] != 3 {
+		t.Errorf("got %v", s)
+	}
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Scopes granted to API tokens
+const (
+	ScopeIngest    = "ingest"    // Log requests
+	ScopeRead      = "read"      // Read requests through the data, export and query endpoints
+	ScopeAdmin     = "admin"     // Everything, including deleting data and managing tokens
+	ScopeDashboard = "dashboard" // View the dashboard and manage monitors, as a user ID does
+)
+
+// Name of the dashboard tokens issued as user IDs
+const DashboardTokenName = "Dashboard"
+
+// TokenPrefix starts every API token, telling tokens apart from API keys and
+// user IDs.
+const TokenPrefix = "aat_"
+
+// Characters of a token kept as its hint, including the prefix
+const tokenHintLength = len(TokenPrefix) + 8
+
+// How often a token's last used time is updated
+const tokenLastUsedInterval = time.Minute
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// Token describes an API token without the token itself, which is only
+// returned when created.
+type Token struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Hint       string     `json:"hint"` // Start of the token
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // Nil if the token never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// ValidScope reports whether scope can be granted to a token.
+func ValidScope(scope string) bool {
+	return scope == ScopeIngest || scope == ScopeRead || scope == ScopeAdmin || scope == ScopeDashboard
+}
+
+// ScopeAllows reports whether a token granted scope can be used where
+// required is needed. Admin tokens can be used anywhere.
+func ScopeAllows(scope string, required string) bool {
+	return scope == required || scope == ScopeAdmin
+}
+
+// IsToken reports whether value has the form of an API token.
+func IsToken(value string) bool {
+	return strings.HasPrefix(value, TokenPrefix)
+}
+
+// GenerateToken returns a new random token.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hash a token is stored as. Tokens are random, so a
+// fast hash is enough to keep them from being recovered.
+func HashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+const tokenColumns = "id, name, scope, hint, created_at, expires_at, last_used_at, revoked_at"
+
+func scanToken(row pgx.Row, token *Token) error {
+	return row.Scan(&token.ID, &token.Name, &token.Scope, &token.Hint, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt)
+}
+
+// CreateToken issues a token for an account, returning the token alongside
+// its description.
+func CreateToken(ctx context.Context, db Querier, apiKey string, name string, scope string, expiresAt *time.Time) (string, Token, error) {
+	value, err := GenerateToken()
+	if err != nil {
+		return "", Token{}, err
+	}
+	query := `INSERT INTO api_tokens (api_key, name, scope, hint, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6) RETURNING ` + tokenColumns + ";"
+	var token Token
+	err = scanToken(db.QueryRow(ctx, query, apiKey, name, scope, value[:tokenHintLength], HashToken(value), expiresAt), &token)
+	if err != nil {
+		return "", Token{}, err
+	}
+	return value, token, nil
+}
+
+// ListTokens returns an account's tokens, including those expired or revoked.
+func ListTokens(ctx context.Context, db Querier, apiKey string) ([]Token, error) {
+	query := "SELECT " + tokenColumns + " FROM api_tokens WHERE api_key = $1 ORDER BY created_at, id;"
+	rows, err := db.Query(ctx, query, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]Token, 0)
+	for rows.Next() {
+		var token Token
+		if err := scanToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeToken revokes one of an account's tokens. Returns ErrTokenNotFound if
+// the account has no such active token.
+func RevokeToken(ctx context.Context, db Querier, apiKey string, id int64) error {
+	query := "UPDATE api_tokens SET revoked_at = NOW() WHERE api_key = $1 AND id = $2 AND revoked_at IS NULL;"
+	tag, err := db.Exec(ctx, query, apiKey, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// RotateToken replaces one of an account's active tokens with a new token of
+// the same name, scope and expiry, revoking the old token.
+func RotateToken(ctx context.Context, db Beginner, apiKey string, id int64) (string, Token, error) {
+	var value string
+	var token Token
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		var name, scope string
+		var expiresAt *time.Time
+		query := `UPDATE api_tokens SET revoked_at = NOW()
+			WHERE api_key = $1 AND id = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+			RETURNING name, scope, expires_at;`
+		err := tx.QueryRow(ctx, query, apiKey, id).Scan(&name, &scope, &expiresAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTokenNotFound
+		} else if err != nil {
+			return err
+		}
+		value, token, err = CreateToken(ctx, tx, apiKey, name, scope, expiresAt)
+		return err
+	})
+	return value, token, err
+}
+
+// Returns the ID of the account the active token with a hash belongs to, and
+// the token's scope
+func authenticateToken(ctx context.Context, db Querier, hash []byte) (string, string, error) {
+	var id int64
+	var apiKey, scope string
+	var lastUsedAt *time.Time
+	query := `SELECT id, api_key::text, scope, last_used_at FROM api_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW());`
+	err := db.QueryRow(ctx, query, hash).Scan(&id, &apiKey, &scope, &lastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrUnauthenticated
+	} else if err != nil {
+		return "", "", err
+	}
+
+	// Avoid a write on every request
+	if lastUsedAt == nil || time.Since(*lastUsedAt) >= tokenLastUsedInterval {
+		_, err = db.Exec(ctx, "UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1;", id)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return apiKey, scope, nil
+}
+
+// DeleteTokens removes all of an account's tokens.
+func DeleteTokens(ctx context.Context, db Querier, apiKey string) error {
+	_, err := db.Exec(ctx, "DELETE FROM api_tokens WHERE api_key = $1;", apiKey)
+	return err
+}
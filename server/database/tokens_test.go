@@ -0,0 +1,75 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateToken(t *testing.T) {
+	a, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("got the same token twice")
+	}
+	if !IsToken(a) || len(a) != len(TokenPrefix)+43 {
+		t.Errorf("got %q, expected %s followed by 43 characters", a, TokenPrefix)
+	}
+	if strings.ContainsAny(a, "+/=") {
+		t.Errorf("got %q, expected a URL-safe token", a)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	token := TokenPrefix + "example"
+	if !bytes.Equal(HashToken(token), HashToken(token)) {
+		t.Error("got different hashes of the same token")
+	}
+	if bytes.Equal(HashToken(token), HashToken(token+"x")) {
+		t.Error("got the same hash of different tokens")
+	}
+	if len(HashToken(token)) != 32 {
+		t.Errorf("got %d byte hash, expected 32", len(HashToken(token)))
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	expecteds := []struct {
+		scope    string
+		required string
+		allowed  bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeAdmin, ScopeRead, true},
+		{ScopeAdmin, ScopeIngest, true},
+		{ScopeAdmin, ScopeDashboard, true},
+		{ScopeIngest, ScopeRead, false},
+		{ScopeRead, ScopeIngest, false},
+		{ScopeRead, ScopeAdmin, false},
+		{ScopeDashboard, ScopeRead, false},
+	}
+	for _, expected := range expecteds {
+		if allowed := ScopeAllows(expected.scope, expected.required); allowed != expected.allowed {
+			t.Errorf("%s for %s: got %v, expected %v", expected.scope, expected.required, allowed, expected.allowed)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range []string{ScopeIngest, ScopeRead, ScopeAdmin, ScopeDashboard} {
+		if !ValidScope(scope) {
+			t.Errorf("%s: got invalid, expected valid", scope)
+		}
+	}
+	for _, scope := range []string{"", "write", "ADMIN"} {
+		if ValidScope(scope) {
+			t.Errorf("%q: got valid, expected invalid", scope)
+		}
+	}
+}
@@ -4,30 +4,35 @@ import (
 	"errors"
 	"fmt"
 	"net/smtp"
-	"os"
 
-	"github.com/joho/godotenv"
+	"github.com/tom-draper/api-analytics/server/config"
 )
 
-func getEmailLogin() (string, string) {
-	err := godotenv.Load(".env")
-	if err != nil {
-		panic(err)
-	}
+// Config holds the SMTP server and accounts used to send emails
+type Config struct {
+	SMTPHost string `config:"smtp_host" help:"SMTP server to send emails through"`
+	SMTPPort int    `config:"smtp_port" help:"SMTP server port"`
+	Address  string `config:"automation_email_address" help:"Account emails are sent from" required:"true"`
+	Password string `config:"automation_email_password" help:"Password of the account emails are sent from" required:"true"`
+	To       string `config:"email_address" help:"Address emails are sent to"`
+}
 
-	address := os.Getenv("AUTOMATION_EMAIL_ADDRESS")
-	password := os.Getenv("AUTOMATION_EMAIL_PASSWORD")
-	return address, password
+// LoadConfig reads the email settings from the environment and .env file.
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		SMTPHost: "smtp-mail.outlook.com",
+		SMTPPort: 587,
+	}
+	err := config.Load(&cfg, nil)
+	return cfg, err
 }
 
 func GetEmailAddress() string {
-	err := godotenv.Load(".env")
+	cfg, err := LoadConfig()
 	if err != nil {
 		panic(err)
 	}
-
-	address := os.Getenv("EMAIL_ADDRESS")
-	return address
+	return cfg.To
 }
 
 // Login solution provided by andelf
@@ -60,22 +65,19 @@ func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
 }
 
 func SendEmail(subject string, body string, dest string) error {
-	server := "smtp-mail.outlook.com"
-	port := 587
-	address, password := getEmailLogin()
-	from := address
-
-	fmt.Println(address, password)
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	from := cfg.Address
 
-	auth := LoginAuth(address, password)
-	fmt.Println("logged in")
+	auth := LoginAuth(cfg.Address, cfg.Password)
 
 	to := []string{dest}
 
 	msg := []byte(fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\nOK", from, dest, subject))
 
-	endpoint := fmt.Sprintf("%s:%d", server, port)
-	fmt.Println(endpoint)
-	err := smtp.SendMail(endpoint, auth, from, to, msg)
+	endpoint := fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort)
+	err = smtp.SendMail(endpoint, auth, from, to, msg)
 	return err
 }
@@ -1,5 +1,15 @@
 module github.com/tom-draper/api-analytics/server/email
 
-go 1.20
+go 1.21.0
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/tom-draper/api-analytics/server/config v0.0.0
+)
+
+require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/tom-draper/api-analytics/server/config => ../config
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Config holds the logger's settings, read from an optional config file,
+// environment variables and command-line flags
+type Config struct {
+	Port          int             `config:"port" help:"Port to listen on"`
+	MetricsPort   int             `config:"metrics_port" help:"Port serving Prometheus metrics at /metrics, 0 to disable"`
+	MaxInsert     int             `config:"max_insert" help:"Requests logged per minute by accounts when quotas cannot be read"`
+	BatchIDWindow time.Duration   `config:"batch_id_window" help:"How long accepted batch IDs are held in memory to skip retried batches"`
+	LiveTail      bool            `config:"live_tail" help:"Publish stored requests to the API for live tailing"`
+	Queue         QueueConfig     `config:"queue"`
+	Partitions    PartitionConfig `config:"partitions"`
+	Postgres      database.Config `config:"postgres"`
+	Log           logging.Options `config:"log"`
+}
+
+// QueueConfig holds the settings of the ingestion queue
+type QueueConfig struct {
+	Capacity  int    `config:"capacity" help:"Payloads waiting to be stored before new payloads are rejected"`
+	Workers   int    `config:"workers" help:"Workers storing queued payloads concurrently"`
+	BatchSize int    `config:"batch_size" help:"Maximum requests combined into a single insert"`
+	WAL       string `config:"wal" help:"Optional file to persist queued payloads until stored"`
+}
+
+// PartitionConfig holds the settings of the monthly requests partitions
+type PartitionConfig struct {
+	MonthsAhead     int `config:"months_ahead" help:"Months of partitions created ahead of the current month"`
+	RetentionMonths int `config:"retention_months" help:"Months of requests kept before the current month, 0 keeps all"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:          8000,
+		MaxInsert:     2000,
+		BatchIDWindow: 15 * time.Minute,
+		LiveTail:      false,
+		Queue: QueueConfig{
+			Capacity:  10_000,
+			Workers:   4,
+			BatchSize: 10_000,
+		},
+		Partitions: PartitionConfig{
+			MonthsAhead: 3,
+		},
+		Log: logging.Options{
+			Path:       "./requests.log",
+			Level:      "info",
+			MaxSize:    100,
+			MaxBackups: 5,
+		},
+	}
+}
+
+// Validate checks the settings are usable before the logger starts.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.MetricsPort < 0 || c.MetricsPort > 65535 || c.MetricsPort == c.Port {
+		errs = append(errs, fmt.Errorf("metrics_port must be between 1 and 65535 and differ from port, or 0, got %d", c.MetricsPort))
+	}
+	if c.MaxInsert <= 0 {
+		errs = append(errs, fmt.Errorf("max_insert must be positive, got %d", c.MaxInsert))
+	}
+	if c.BatchIDWindow <= 0 {
+		errs = append(errs, fmt.Errorf("batch_id_window must be positive, got %s", c.BatchIDWindow))
+	}
+	if c.Queue.Capacity <= 0 || c.Queue.Workers <= 0 || c.Queue.BatchSize <= 0 {
+		errs = append(errs, errors.New("queue capacity, workers and batch_size must be positive"))
+	}
+	if c.Partitions.MonthsAhead < 1 || c.Partitions.RetentionMonths < 0 {
+		errs = append(errs, errors.New("partitions months_ahead must be positive and retention_months cannot be negative"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.Log.Level))
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxBackups < 0 {
+		errs = append(errs, errors.New("log max_size and max_backups cannot be negative"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logger/lib/bots"
+	"github.com/tom-draper/api-analytics/server/logger/lib/geoip"
+	"github.com/tom-draper/api-analytics/server/logger/lib/metrics"
+	"github.com/tom-draper/api-analytics/server/logger/lib/queue"
+	"github.com/tom-draper/api-analytics/server/logger/lib/useragent"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// Validated payload waiting in the ingestion queue to be stored
+type Batch struct {
+	APIKey       string        `json:"api_key"`
+	Framework    int16         `json:"framework"`
+	PrivacyLevel PrivacyLevel  `json:"privacy_level"`
+	Requests     []RequestData `json:"requests"`
+	Received     int           `json:"received"` // Number of requests in the original payload
+	BatchID      string        `json:"batch_id"`
+}
+
+// Maximum length of client-generated batch and request IDs
+const maxIDLength int = 64
+
+// Maximum time a worker waits for more batches to coalesce into one insert
+const flushInterval = 200 * time.Millisecond
+
+// Maximum time taken to store coalesced batches before the attempt is
+// abandoned and retried
+const storeTimeout = 30 * time.Second
+
+// Delay before batches failing to store are first retried
+const retryDelay = time.Second
+
+func newIngestQueue(options QueueConfig, liveTail bool, pool *pgxpool.Pool, locator *geoip.Locator, detector *bots.Detector) (*queue.Queue[Batch], error) {
+	return queue.New(queue.Options[Batch]{
+		Capacity:      options.Capacity,
+		Workers:       options.Workers,
+		MaxBatchSize:  options.BatchSize,
+		FlushInterval: flushInterval,
+		WALPath:       options.WAL,
+		Size: func(batch Batch) int {
+			return len(batch.Requests)
+		},
+		Retryable:  transientError,
+		RetryDelay: retryDelay,
+		Process: func(batches []Batch) error {
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			defer cancel()
+			err := storeBatches(ctx, pool, locator, detector, batches, liveTail)
+			if err != nil {
+				metrics.DBErrors.WithLabelValues("store").Inc()
+				return err
+			}
+			metrics.InsertDuration.Observe(time.Since(start).Seconds())
+			size := 0
+			for _, batch := range batches {
+				size += len(batch.Requests)
+			}
+			metrics.BatchSize.Observe(float64(size))
+			return nil
+		},
+		OnError: func(batches []Batch, err error) {
+			for _, batch := range batches {
+				slog.Error("Failed to store requests", logging.APIKey(batch.APIKey), slog.Int("requests", len(batch.Requests)), logging.Err(err))
+			}
+			if len(batches) == 0 {
+				slog.Error("Ingestion queue error", logging.Err(err))
+			}
+		},
+	})
+}
+
+// Reports whether storing failed for a reason that may pass, such as the
+// database being unreachable, rather than because of the requests stored
+func transientError(err error) bool {
+	var validationErr *database.ValidationError
+	if errors.As(err, &validationErr) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Connection, transaction rollback, resource, operator intervention
+		// and system error classes
+		switch pgErr.Code[:2] {
+		case "08", "40", "53", "57", "58":
+			return true
+		}
+		return false
+	}
+	// Connection failures and timeouts
+	return true
+}
+
+// Normalises logged requests, dropping any that are invalid and counting the
+// reasons they were rejected
+func validateRequests(requests []RequestData) ([]RequestData, map[string]int) {
+	valid := make([]RequestData, 0, len(requests))
+	rejected := make(map[string]int)
+	for _, request := range requests {
+		request, err := normaliseRequest(request)
+		if err != nil {
+			rejected[err.Error()]++
+			continue
+		}
+		valid = append(valid, request)
+	}
+	return valid, rejected
+}
+
+func normaliseRequest(request RequestData) (RequestData, error) {
+	if _, ok := database.MethodID(request.Method); !ok {
+		return request, &database.ValidationError{Field: "method", Reason: "not supported"}
+	}
+	if len(request.RequestID) > maxIDLength {
+		return request, &database.ValidationError{Field: "request_id", Reason: "too long"}
+	}
+
+	createdAt, ok := parseCreatedAt(request.CreatedAt)
+	if !ok {
+		return request, &database.ValidationError{Field: "created_at", Reason: "not a timestamp"}
+	}
+	// Stored in one form so Postgres reads it as parsed here
+	request.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+
+	var err error
+	if request.UserAgent, err = database.NormaliseUserAgent(request.UserAgent); err != nil {
+		return request, err
+	}
+	if request.UserID, err = database.NormaliseUserID(request.UserID); err != nil {
+		return request, err
+	}
+	if request.Hostname, err = database.NormaliseHostname(request.Hostname); err != nil {
+		return request, err
+	}
+	if request.Path, err = database.NormalisePath(request.Path); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+// Removes logged requests sent by bots, identified by user agent or IP address
+func dropBots(requests []RequestData, detector *bots.Detector) []RequestData {
+	humans := make([]RequestData, 0, len(requests))
+	for _, request := range requests {
+		if _, ok := detector.Match(request.UserAgent, request.IPAddress); !ok {
+			humans = append(humans, request)
+		}
+	}
+	return humans
+}
+
+// Stores one or more queued batches in a single transaction, publishing the
+// stored requests to live tail subscribers if liveTail is set
+func storeBatches(ctx context.Context, pool *pgxpool.Pool, locator *geoip.Locator, detector *bots.Detector, batches []Batch, liveTail bool) error {
+	// Store user agents and logged requests together so a failed insert
+	// leaves nothing behind
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Skip batches already stored, such as those retried by a client after
+	// the in-memory window
+	batches, err = recordBatches(ctx, tx, batches)
+	if err != nil {
+		return err
+	}
+	if len(batches) == 0 {
+		return tx.Commit(ctx)
+	}
+
+	rows := make([][]any, 0)
+	userAgents := make([]string, 0)
+	uniqueUserAgents := map[string]struct{}{}
+	live := make(map[string][]database.LiveRequest)
+	for _, batch := range batches {
+		for _, request := range batch.Requests {
+			var location geoip.Location
+			if batch.PrivacyLevel < P3 {
+				// Location inferred from IP and stored for privacy level P1 and P2
+				location = locator.Lookup(request.IPAddress)
+			}
+
+			// Classified before the IP address may be discarded
+			_, isBot := detector.Match(request.UserAgent, request.IPAddress)
+
+			if batch.PrivacyLevel > P1 {
+				// Client IP address discarded for privacy level P2 and P3
+				request.IPAddress = ""
+			}
+
+			// Validated before being queued
+			method, _ := database.MethodID(request.Method)
+
+			// Register user agent to be stored in the database
+			uniqueUserAgents[request.UserAgent] = struct{}{}
+			// Temp store for user agents in each row for conversion to user agent IDs
+			userAgents = append(userAgents, request.UserAgent)
+
+			rows = append(rows, []any{
+				batch.APIKey,
+				request.Path,
+				request.Hostname,
+				nullableString(request.IPAddress),
+				request.Status,
+				request.ResponseTime,
+				method,
+				batch.Framework,
+				location.Country,
+				nullableString(location.Region),
+				nullableString(location.City),
+				nullableASN(location.ASN),
+				nullableString(location.Organisation),
+				request.UserID,
+				request.CreatedAt,
+				nil,
+				isBot,
+				nullableString(request.RequestID),
+			})
+
+			if liveTail {
+				live[batch.APIKey] = append(live[batch.APIKey], database.LiveRequest{
+					Hostname:     request.Hostname,
+					Path:         request.Path,
+					Method:       request.Method,
+					Status:       request.Status,
+					ResponseTime: request.ResponseTime,
+					Location:     location.Country,
+					UserID:       request.UserID,
+					UserAgent:    request.UserAgent,
+					Bot:          isBot,
+					CreatedAt:    request.CreatedAt,
+				})
+			}
+		}
+	}
+
+	// Get associated IDs for user agents, storing any new user agents found
+	userAgentIDs, err := useragent.IDs(ctx, tx, uniqueUserAgents, detector.MatchUserAgent)
+	if err != nil {
+		return err
+	}
+	// Insert user agent IDs into rows
+	for i, userAgent := range userAgents {
+		if id, ok := userAgentIDs[userAgent]; ok {
+			rows[i][userAgentIDColumn] = id
+		}
+	}
+
+	err = insertRequests(ctx, tx, rows)
+	if err != nil {
+		return err
+	}
+
+	// Rollups for the hours stored are rebuilt in the background. Marked last
+	// so workers storing the same account only wait on each other's marks
+	// while committing.
+	apiKeys := make([]string, len(rows))
+	createdAt := make([]string, len(rows))
+	for i, row := range rows {
+		apiKeys[i] = row[0].(string)
+		createdAt[i] = row[createdAtColumn].(string)
+	}
+	if err := database.MarkRollups(ctx, tx, apiKeys, createdAt); err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Published after the insert commits so workers' commits are not
+	// serialised by the notification lock. The requests are stored, so a
+	// failure only affects live tail subscribers.
+	if err := database.NotifyRequests(ctx, pool, live); err != nil {
+		slog.Warn("Failed to publish stored requests", logging.Err(err))
+	}
+
+	// Record in log file for debugging
+	for _, batch := range batches {
+		slog.Info("Requests stored", logging.APIKey(batch.APIKey), slog.Int("stored", len(batch.Requests)), slog.Int("received", batch.Received))
+	}
+	return nil
+}
+
+func nullableString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func nullableASN(asn uint) any {
+	if asn == 0 {
+		return nil
+	}
+	return int64(asn)
+}
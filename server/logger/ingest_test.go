@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+func TestValidateRequests(t *testing.T) {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05.999999999")
+	requests := []RequestData{
+		{Method: "GET", Path: "/api/update-profile", Hostname: "API.example.com", UserAgent: "curl/8.0", CreatedAt: now},
+		{Method: "POST", Path: "//users/./1", UserID: "O'Brien", CreatedAt: now},
+		{Method: "GET", Path: "/" + strings.Repeat("é", 300), CreatedAt: now},
+		{Method: "BREW", Path: "/", CreatedAt: now},
+		{Method: "GET", Path: "/users\n", CreatedAt: now},
+		{Method: "GET", Path: "/", Hostname: "bad host", CreatedAt: now},
+		{Method: "GET", Path: "/", RequestID: strings.Repeat("a", maxIDLength+1), CreatedAt: now},
+		{Method: "GET", Path: "/", CreatedAt: "yesterday"},
+		// Late and skewed timestamps are kept, outside any month's partition
+		{Method: "GET", Path: "/", CreatedAt: "2001-01-01T00:00:00Z"},
+		{Method: "GET", Path: "/", CreatedAt: "2999-12-31T23:59:59Z"},
+	}
+
+	valid, rejected := validateRequests(requests)
+	if len(valid) != 5 {
+		t.Fatalf("got %d valid requests, expected 5", len(valid))
+	}
+	if valid[0].Hostname != "api.example.com" || valid[1].Path != "/users/1" || valid[1].UserID != "O'Brien" {
+		t.Errorf("requests not normalised: %+v", valid[:2])
+	}
+	if n := len([]rune(valid[2].Path)); n != 255 {
+		t.Errorf("got path of %d characters, expected 255", n)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, valid[0].CreatedAt); err != nil {
+		t.Errorf("created_at not normalised: %q", valid[0].CreatedAt)
+	}
+
+	for _, reason := range []string{
+		"invalid method: not supported",
+		"invalid path: control character",
+		"invalid hostname: not a domain name or IP address",
+		"invalid request_id: too long",
+		"invalid created_at: not a timestamp",
+	} {
+		if rejected[reason] != 1 {
+			t.Errorf("expected 1 rejection for %q, got %v", reason, rejected)
+		}
+	}
+}
+
+func TestInvalidRequestsMessage(t *testing.T) {
+	message := invalidRequestsMessage(map[string]int{"invalid path: not a path": 1, "invalid hostname: not a domain name or IP address": 3})
+	if message != "Invalid request data, invalid hostname: not a domain name or IP address." {
+		t.Errorf("got %q", message)
+	}
+	if message := invalidRequestsMessage(nil); message != "Invalid request data." {
+		t.Errorf("got %q", message)
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	tests := []struct {
+		err       error
+		transient bool
+	}{
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("failed to connect: %w", &pgconn.ConnectError{}), true},
+		{&pgconn.PgError{Code: "57P01"}, true},  // admin_shutdown
+		{&pgconn.PgError{Code: "40P01"}, true},  // deadlock_detected
+		{&pgconn.PgError{Code: "22007"}, false}, // invalid_datetime_format
+		{&pgconn.PgError{Code: "23505"}, false}, // unique_violation
+		{&database.ValidationError{Field: "path", Reason: database.ReasonInvalidPath}, false},
+	}
+	for _, test := range tests {
+		if got := transientError(test.err); got != test.transient {
+			t.Errorf("transientError(%v) = %t, expected %t", test.err, got, test.transient)
+		}
+	}
+}
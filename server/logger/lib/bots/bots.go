@@ -0,0 +1,207 @@
+package bots
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Signature file looked up relative to the working directory, replacing the
+// built-in signatures when present
+const SignaturesFile = "bots.json"
+
+//go:embed signatures.json
+var defaultSignatures []byte
+
+// Signatures identifying bots by user agent pattern or by known crawler IP
+// ranges. User agent patterns are checked in order.
+type Signatures struct {
+	UserAgents []UserAgentSignature `json:"user_agents"`
+	IPRanges   []IPRangeSignature   `json:"ip_ranges"`
+}
+
+type UserAgentSignature struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"` // Go regular expression
+}
+
+type IPRangeSignature struct {
+	Name  string   `json:"name"`
+	CIDRs []string `json:"cidrs"`
+}
+
+type userAgentPattern struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+type ipRange struct {
+	name   string
+	prefix netip.Prefix
+}
+
+// Detector classifies requests as bots against a signature list that can be
+// updated on disk without restarting the service.
+type Detector struct {
+	mu         sync.RWMutex
+	path       string
+	modTime    time.Time
+	userAgents []userAgentPattern
+	ipRanges   []ipRange
+	done       chan struct{}
+}
+
+func NewDetector() (*Detector, error) {
+	return NewDetectorWithPath(SignaturesFile)
+}
+
+// NewDetectorWithPath creates a detector using the signature file at path, or
+// the built-in signatures if the file does not exist.
+func NewDetectorWithPath(path string) (*Detector, error) {
+	d := &Detector{path: path, done: make(chan struct{})}
+	if err := d.load(defaultSignatures, time.Time{}); err != nil {
+		return nil, err
+	}
+	if _, err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload reads the signature file if it has changed on disk since it was last
+// read. Returns true if the signatures were replaced. An invalid file is
+// reported and the current signatures kept.
+func (d *Detector) Reload() (bool, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		// Fall back to the built-in signatures if the file is removed
+		d.mu.RLock()
+		custom := !d.modTime.IsZero()
+		d.mu.RUnlock()
+		if custom {
+			return true, d.load(defaultSignatures, time.Time{})
+		}
+		return false, nil
+	}
+
+	d.mu.RLock()
+	unchanged := info.ModTime().Equal(d.modTime)
+	d.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return false, err
+	}
+	if err := d.load(data, info.ModTime()); err != nil {
+		return false, fmt.Errorf("%s: %w", d.path, err)
+	}
+	return true, nil
+}
+
+func (d *Detector) load(data []byte, modTime time.Time) error {
+	var signatures Signatures
+	if err := json.Unmarshal(data, &signatures); err != nil {
+		return err
+	}
+
+	userAgents := make([]userAgentPattern, 0, len(signatures.UserAgents))
+	for _, signature := range signatures.UserAgents {
+		regex, err := regexp.Compile(signature.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern for %s: %w", signature.Name, err)
+		}
+		userAgents = append(userAgents, userAgentPattern{signature.Name, regex})
+	}
+
+	ipRanges := make([]ipRange, 0)
+	for _, signature := range signatures.IPRanges {
+		for _, cidr := range signature.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return fmt.Errorf("invalid IP range for %s: %w", signature.Name, err)
+			}
+			ipRanges = append(ipRanges, ipRange{signature.Name, prefix.Masked()})
+		}
+	}
+
+	d.mu.Lock()
+	d.userAgents = userAgents
+	d.ipRanges = ipRanges
+	d.modTime = modTime
+	d.mu.Unlock()
+	return nil
+}
+
+// Watch checks the signature file for changes at the given interval until
+// Close is called. Errors reading the file are passed to onError.
+func (d *Detector) Watch(interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := d.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-d.done:
+				return
+			}
+		}
+	}()
+}
+
+func (d *Detector) Close() {
+	close(d.done)
+}
+
+// MatchUserAgent returns the name of the bot a user agent belongs to.
+func (d *Detector) MatchUserAgent(userAgent string) (string, bool) {
+	if userAgent == "" {
+		return "", false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, pattern := range d.userAgents {
+		if pattern.regex.MatchString(userAgent) {
+			return pattern.name, true
+		}
+	}
+	return "", false
+}
+
+// MatchIP returns the name of the crawler an IP address belongs to.
+func (d *Detector) MatchIP(ipAddress string) (string, bool) {
+	addr, err := netip.ParseAddr(ipAddress)
+	if err != nil {
+		return "", false
+	}
+	addr = addr.Unmap()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, r := range d.ipRanges {
+		if r.prefix.Contains(addr) {
+			return r.name, true
+		}
+	}
+	return "", false
+}
+
+// Match returns the name of the bot that sent a request, identified by either
+// its user agent or its IP address.
+func (d *Detector) Match(userAgent string, ipAddress string) (string, bool) {
+	if name, ok := d.MatchUserAgent(userAgent); ok {
+		return name, true
+	}
+	return d.MatchIP(ipAddress)
+}
@@ -0,0 +1,84 @@
+package bots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	d, err := NewDetectorWithPath(filepath.Join(t.TempDir(), SignaturesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expecteds := []struct {
+		userAgent string
+		ipAddress string
+		name      string
+		ok        bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "", "Googlebot", true},
+		{"Mozilla/5.0 (compatible; ExampleCrawler/1.0)", "", "Other", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36", "66.249.66.1", "Googlebot", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36", "::ffff:66.249.66.1", "Googlebot", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36", "192.168.0.1", "", false},
+		{"curl/8.4.0", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, expected := range expecteds {
+		name, ok := d.Match(expected.userAgent, expected.ipAddress)
+		if name != expected.name || ok != expected.ok {
+			t.Errorf("%q %q: got (%q, %t), expected (%q, %t)", expected.userAgent, expected.ipAddress, name, ok, expected.name, expected.ok)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SignaturesFile)
+	d, err := NewDetectorWithPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatures := `{"user_agents": [{"name": "Example", "pattern": "ExampleAgent"}], "ip_ranges": [{"name": "Example", "cidrs": ["10.0.0.0/8"]}]}`
+	if err := os.WriteFile(path, []byte(signatures), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := d.Reload(); !reloaded || err != nil {
+		t.Fatalf("expected reload, got %t %v", reloaded, err)
+	}
+	if name, _ := d.Match("ExampleAgent/1.0", ""); name != "Example" {
+		t.Errorf("got %q, expected Example", name)
+	}
+	if name, _ := d.MatchIP("10.1.2.3"); name != "Example" {
+		t.Errorf("got %q, expected Example", name)
+	}
+	if _, ok := d.MatchUserAgent("Googlebot/2.1"); ok {
+		t.Error("expected built-in signatures to be replaced")
+	}
+
+	// Invalid files keep the current signatures
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, []byte(`{"user_agents": [{"name": "Bad", "pattern": "("}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+	if _, err := d.Reload(); err == nil {
+		t.Error("expected invalid pattern error")
+	}
+	if name, _ := d.Match("ExampleAgent/1.0", ""); name != "Example" {
+		t.Errorf("got %q, expected Example", name)
+	}
+
+	// Removing the file restores the built-in signatures
+	os.Remove(path)
+	if reloaded, err := d.Reload(); !reloaded || err != nil {
+		t.Fatalf("expected reload, got %t %v", reloaded, err)
+	}
+	if name, _ := d.MatchUserAgent("Googlebot/2.1"); name != "Googlebot" {
+		t.Errorf("got %q, expected Googlebot", name)
+	}
+}
@@ -0,0 +1,94 @@
+// Package credentials resolves the API keys and tokens sent with logged
+// requests to the ID of their account, caching results briefly to avoid a
+// database read for every payload.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+// How long a resolved credential is used before being read from the database
+// again, bounding how long an expired or revoked credential is still accepted
+const cacheDuration = time.Minute
+
+// Credentials cached before stale entries are dropped
+const maxEntries = 10_000
+
+// ErrScope is returned for tokens whose scope does not allow logging requests.
+var ErrScope = errors.New("token scope does not allow logging requests")
+
+// Resolver resolves credentials allowing requests to be logged.
+type Resolver struct {
+	db      database.Querier
+	mu      sync.Mutex
+	entries map[string]entry // Keyed by credential hash
+	now     func() time.Time
+}
+
+type entry struct {
+	accountID string
+	err       error // Unauthenticated or scope denied
+	fetched   time.Time
+}
+
+// NewResolver creates a resolver reading credentials from the database.
+func NewResolver(db database.Querier) *Resolver {
+	return &Resolver{
+		db:      db,
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// Resolve returns the ID of the account an API key or token belongs to.
+// Returns database.ErrUnauthenticated if the credential is unknown, expired
+// or revoked, and ErrScope if it cannot be used to log requests.
+func (r *Resolver) Resolve(ctx context.Context, credential string) (string, error) {
+	key := string(database.HashAPIKey(credential))
+	if database.IsToken(credential) {
+		key = string(database.HashToken(credential))
+	}
+	now := r.now()
+
+	r.mu.Lock()
+	e, ok := r.entries[key]
+	r.mu.Unlock()
+	if ok && now.Sub(e.fetched) < cacheDuration {
+		return e.accountID, e.err
+	}
+
+	// Read outside the lock to avoid blocking other credentials
+	accountID, scope, err := database.Authenticate(ctx, r.db, credential)
+	if err == nil && !database.ScopeAllows(scope, database.ScopeIngest) {
+		accountID, err = "", ErrScope
+	}
+	if err != nil && !errors.Is(err, database.ErrUnauthenticated) && !errors.Is(err, ErrScope) {
+		// Database errors are not cached
+		return "", err
+	}
+
+	r.mu.Lock()
+	if len(r.entries) >= maxEntries {
+		r.prune(now)
+	}
+	r.entries[key] = entry{accountID, err, now}
+	r.mu.Unlock()
+	return accountID, err
+}
+
+// Drops stale entries, or every entry if none are stale
+func (r *Resolver) prune(now time.Time) {
+	for key, e := range r.entries {
+		if now.Sub(e.fetched) >= cacheDuration {
+			delete(r.entries, key)
+		}
+	}
+	if len(r.entries) >= maxEntries {
+		r.entries = make(map[string]entry)
+	}
+}
@@ -0,0 +1,135 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+// Database stub holding a single credential's account and scope
+type stubDB struct {
+	apiKey  string
+	scope   string
+	err     error
+	queries *int
+}
+
+func (s stubDB) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+func (s stubDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s stubDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	*s.queries++
+	return stubRow(s)
+}
+
+type stubRow stubDB
+
+func (r stubRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	// API keys are read with their account ID alone
+	if len(dest) == 1 {
+		*dest[0].(*string) = r.apiKey
+		return nil
+	}
+	*dest[0].(*int64) = 1
+	*dest[1].(*string) = r.apiKey
+	*dest[2].(*string) = r.scope
+	now := time.Now()
+	*dest[3].(**time.Time) = &now
+	return nil
+}
+
+const apiKey = "0b3c1c0e-8c1d-4f3a-9e2b-7d6c5b4a3f21"
+
+func TestResolve(t *testing.T) {
+	expecteds := []struct {
+		scope  string
+		apiKey string
+		err    error
+	}{
+		{database.ScopeIngest, apiKey, nil},
+		{database.ScopeAdmin, apiKey, nil},
+		{database.ScopeRead, "", ErrScope},
+		{database.ScopeDashboard, "", ErrScope},
+	}
+	for _, expected := range expecteds {
+		queries := 0
+		resolver := NewResolver(stubDB{apiKey: apiKey, scope: expected.scope, queries: &queries})
+		got, err := resolver.Resolve(context.Background(), database.TokenPrefix+"token")
+		if got != expected.apiKey || !errors.Is(err, expected.err) {
+			t.Errorf("%s: got %q %v, expected %q %v", expected.scope, got, err, expected.apiKey, expected.err)
+		}
+	}
+}
+
+func TestResolveAPIKey(t *testing.T) {
+	queries := 0
+	resolver := NewResolver(stubDB{apiKey: apiKey, queries: &queries})
+	got, err := resolver.Resolve(context.Background(), "f1e2d3c4-8c1d-4f3a-9e2b-7d6c5b4a3f21")
+	if got != apiKey || err != nil {
+		t.Errorf("got %q %v, expected %q", got, err, apiKey)
+	}
+}
+
+func TestResolveCached(t *testing.T) {
+	queries := 0
+	resolver := NewResolver(stubDB{apiKey: apiKey, scope: database.ScopeIngest, queries: &queries})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	resolver.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.Resolve(context.Background(), database.TokenPrefix+"token"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("got %d queries, expected 1", queries)
+	}
+
+	now = now.Add(cacheDuration)
+	if _, err := resolver.Resolve(context.Background(), database.TokenPrefix+"token"); err != nil {
+		t.Fatal(err)
+	}
+	if queries != 2 {
+		t.Errorf("got %d queries after cache duration, expected 2", queries)
+	}
+}
+
+func TestResolveUnknown(t *testing.T) {
+	queries := 0
+	resolver := NewResolver(stubDB{err: pgx.ErrNoRows, queries: &queries})
+	for i := 0; i < 2; i++ {
+		_, err := resolver.Resolve(context.Background(), database.TokenPrefix+"unknown")
+		if !errors.Is(err, database.ErrUnauthenticated) {
+			t.Errorf("got %v, expected %v", err, database.ErrUnauthenticated)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("got %d queries, expected unknown token to be cached", queries)
+	}
+}
+
+func TestResolveDatabaseError(t *testing.T) {
+	queries := 0
+	resolver := NewResolver(stubDB{err: errors.New("connection refused"), queries: &queries})
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.Resolve(context.Background(), database.TokenPrefix+"token"); err == nil {
+			t.Error("got nil error, expected database error")
+		}
+	}
+	if queries != 2 {
+		t.Errorf("got %d queries, expected database errors not to be cached", queries)
+	}
+}
@@ -0,0 +1,91 @@
+package dedupe
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Window records the batch IDs accepted for each API key over a recent
+// period, so retried batches can be recognised without a database lookup.
+type Window struct {
+	mu         sync.Mutex
+	duration   time.Duration
+	maxEntries int
+	entries    map[key]*list.Element
+	order      *list.List // Oldest entry at the front
+	now        func() time.Time
+}
+
+type key struct {
+	apiKey  string
+	batchID string
+}
+
+type entry struct {
+	key  key
+	seen time.Time
+}
+
+// NewWindow creates a window remembering batch IDs for the given duration,
+// forgetting the oldest early if more than maxEntries are held.
+func NewWindow(duration time.Duration, maxEntries int) *Window {
+	return &Window{
+		duration:   duration,
+		maxEntries: maxEntries,
+		entries:    make(map[key]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// Seen reports whether the batch ID was recorded for the API key within the
+// window.
+func (w *Window) Seen(apiKey string, batchID string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.prune()
+	_, ok := w.entries[key{apiKey, batchID}]
+	return ok
+}
+
+// Add records the batch ID for the API key.
+func (w *Window) Add(apiKey string, batchID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	k := key{apiKey, batchID}
+	if element, ok := w.entries[k]; ok {
+		w.order.Remove(element)
+	}
+	w.entries[k] = w.order.PushBack(&entry{k, w.now()})
+
+	w.prune()
+	for w.order.Len() > w.maxEntries {
+		w.remove(w.order.Front())
+	}
+}
+
+// Len returns the number of batch IDs held.
+func (w *Window) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.order.Len()
+}
+
+// Removes entries older than the window from the front of the list
+func (w *Window) prune() {
+	cutoff := w.now().Add(-w.duration)
+	for element := w.order.Front(); element != nil; element = w.order.Front() {
+		if element.Value.(*entry).seen.After(cutoff) {
+			return
+		}
+		w.remove(element)
+	}
+}
+
+func (w *Window) remove(element *list.Element) {
+	w.order.Remove(element)
+	delete(w.entries, element.Value.(*entry).key)
+}
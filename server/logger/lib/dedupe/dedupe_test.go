@@ -0,0 +1,47 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(time.Minute, 100)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	if w.Seen("key", "batch") {
+		t.Error("expected unseen batch")
+	}
+	w.Add("key", "batch")
+	if !w.Seen("key", "batch") {
+		t.Error("expected seen batch")
+	}
+	// Batch IDs are only duplicates for the same API key
+	if w.Seen("other", "batch") {
+		t.Error("expected unseen batch for other key")
+	}
+
+	now = now.Add(time.Minute)
+	if w.Seen("key", "batch") {
+		t.Error("expected batch to expire")
+	}
+	if w.Len() != 0 {
+		t.Errorf("got %d entries, expected 0", w.Len())
+	}
+}
+
+func TestWindowMaxEntries(t *testing.T) {
+	w := NewWindow(time.Hour, 2)
+
+	w.Add("key", "1")
+	w.Add("key", "2")
+	w.Add("key", "3")
+
+	if w.Seen("key", "1") {
+		t.Error("expected oldest batch to be forgotten")
+	}
+	if !w.Seen("key", "2") || !w.Seen("key", "3") {
+		t.Error("expected newest batches to be kept")
+	}
+}
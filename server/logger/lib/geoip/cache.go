@@ -0,0 +1,78 @@
+package geoip
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Fixed-capacity least recently used cache of IP address lookups
+type cache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // Most recently used at the front
+}
+
+type entry struct {
+	ipAddress string
+	location  Location
+}
+
+func newCache(capacity int) *cache {
+	return &cache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *cache) get(ipAddress string) (Location, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[ipAddress]
+	if !ok {
+		return Location{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*entry).location, true
+}
+
+func (c *cache) add(ipAddress string, location Location) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[ipAddress]; ok {
+		element.Value.(*entry).location = location
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[ipAddress] = c.order.PushFront(&entry{ipAddress, location})
+
+	// Evict least recently used entry once over capacity
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).ipAddress)
+	}
+}
+
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element, c.capacity)
+	c.order.Init()
+}
+
+func (c *cache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,54 @@
+package geoip
+
+import (
+	"testing"
+)
+
+func TestCacheEviction(t *testing.T) {
+	c := newCache(2)
+
+	c.add("1.1.1.1", Location{Country: "AU"})
+	c.add("8.8.8.8", Location{Country: "US"})
+
+	// Access oldest entry so the next insert evicts the other
+	if _, ok := c.get("1.1.1.1"); !ok {
+		t.Error("expected 1.1.1.1 to be cached")
+	}
+
+	c.add("9.9.9.9", Location{Country: "CH"})
+
+	if _, ok := c.get("8.8.8.8"); ok {
+		t.Error("expected 8.8.8.8 to be evicted")
+	}
+	if location, ok := c.get("1.1.1.1"); !ok || location.Country != "AU" {
+		t.Errorf("got %v, expected AU", location)
+	}
+	if location, ok := c.get("9.9.9.9"); !ok || location.Country != "CH" {
+		t.Errorf("got %v, expected CH", location)
+	}
+	if c.len() != 2 {
+		t.Errorf("got %d entries, expected 2", c.len())
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	c := newCache(10)
+	c.add("1.1.1.1", Location{Country: "AU"})
+	c.purge()
+
+	if _, ok := c.get("1.1.1.1"); ok {
+		t.Error("expected cache to be empty after purge")
+	}
+}
+
+func TestLookupWithoutDatabases(t *testing.T) {
+	locator := NewLocatorWithPaths("missing-country.mmdb", "missing-city.mmdb", "missing-asn.mmdb", 10)
+	defer locator.Close()
+
+	if location := locator.Lookup("1.1.1.1"); location != (Location{}) {
+		t.Errorf("got %v, expected empty location", location)
+	}
+	if location := locator.Lookup("not an ip"); location != (Location{}) {
+		t.Errorf("got %v, expected empty location", location)
+	}
+}
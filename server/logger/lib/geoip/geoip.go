@@ -0,0 +1,199 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Location information inferred from a client IP address
+type Location struct {
+	Country      string // ISO country code
+	Region       string
+	City         string
+	ASN          uint
+	Organisation string
+}
+
+// Optional MaxMind databases, looked up relative to the working directory.
+// Only the country database is required to infer a location, the city and
+// ASN databases enrich it further when present.
+const (
+	CountryDatabase = "GeoLite2-Country.mmdb"
+	CityDatabase    = "GeoLite2-City.mmdb"
+	ASNDatabase     = "GeoLite2-ASN.mmdb"
+)
+
+const defaultCacheSize int = 50_000
+
+type database struct {
+	path    string
+	reader  *geoip2.Reader
+	modTime time.Time
+}
+
+// Locator holds the GeoIP database readers open for the lifetime of the
+// service and caches recent lookups in memory.
+type Locator struct {
+	mu      sync.RWMutex
+	country database
+	city    database
+	asn     database
+	cache   *cache
+	done    chan struct{}
+}
+
+func NewLocator() *Locator {
+	return NewLocatorWithPaths(CountryDatabase, CityDatabase, ASNDatabase, defaultCacheSize)
+}
+
+func NewLocatorWithPaths(countryPath string, cityPath string, asnPath string, cacheSize int) *Locator {
+	l := &Locator{
+		country: database{path: countryPath},
+		city:    database{path: cityPath},
+		asn:     database{path: asnPath},
+		cache:   newCache(cacheSize),
+		done:    make(chan struct{}),
+	}
+	l.Reload()
+	return l
+}
+
+// Reload reopens any database file that has changed on disk since it was last
+// opened. Returns true if any database was reloaded.
+func (l *Locator) Reload() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reloaded := false
+	for _, db := range []*database{&l.country, &l.city, &l.asn} {
+		if reloadDatabase(db) {
+			reloaded = true
+		}
+	}
+
+	// Previous lookups may be stale. Purged under the lock so a lookup from
+	// a previous reader can't be cached afterwards.
+	if reloaded {
+		l.cache.purge()
+	}
+	return reloaded
+}
+
+func reloadDatabase(db *database) bool {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		// Database removed or never provided
+		if db.reader != nil {
+			db.reader.Close()
+			db.reader = nil
+			db.modTime = time.Time{}
+			return true
+		}
+		return false
+	}
+
+	if db.reader != nil && info.ModTime().Equal(db.modTime) {
+		return false
+	}
+
+	reader, err := geoip2.Open(db.path)
+	if err != nil {
+		// Keep the previous reader if the new file is incomplete or corrupt
+		return false
+	}
+
+	if db.reader != nil {
+		db.reader.Close()
+	}
+	db.reader = reader
+	db.modTime = info.ModTime()
+	return true
+}
+
+// Watch checks the database files for changes at the given interval until
+// Close is called.
+func (l *Locator) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.Reload()
+			case <-l.done:
+				return
+			}
+		}
+	}()
+}
+
+func (l *Locator) Close() {
+	close(l.done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, db := range []*database{&l.country, &l.city, &l.asn} {
+		if db.reader != nil {
+			db.reader.Close()
+			db.reader = nil
+		}
+	}
+}
+
+// Lookup returns the location information available for an IP address.
+// Fields are left blank where the address or the database is unavailable.
+func (l *Locator) Lookup(ipAddress string) Location {
+	if ipAddress == "" {
+		return Location{}
+	}
+
+	// Held until the location is cached, so it is cached with the readers it
+	// was looked up in
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if location, ok := l.cache.get(ipAddress); ok {
+		return location
+	}
+
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return Location{}
+	}
+
+	location := l.lookup(ip)
+	l.cache.add(ipAddress, location)
+	return location
+}
+
+func (l *Locator) lookup(ip net.IP) Location {
+	var location Location
+	if l.city.reader != nil {
+		if record, err := l.city.reader.City(ip); err == nil {
+			location.Country = record.Country.IsoCode
+			location.City = record.City.Names["en"]
+			if len(record.Subdivisions) > 0 {
+				location.Region = record.Subdivisions[0].Names["en"]
+			}
+		}
+	}
+
+	if location.Country == "" && l.country.reader != nil {
+		if record, err := l.country.reader.Country(ip); err == nil {
+			location.Country = record.Country.IsoCode
+		}
+	}
+
+	if l.asn.reader != nil {
+		if record, err := l.asn.reader.ASN(ip); err == nil {
+			location.ASN = record.AutonomousSystemNumber
+			location.Organisation = record.AutonomousSystemOrganization
+		}
+	}
+
+	return location
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "logger"
+
+// Reasons logged requests are rejected, used as the reason label
+const (
+	ReasonInvalid   = "invalid"
+	ReasonBot       = "bot"
+	ReasonQuota     = "quota"
+	ReasonDuplicate = "duplicate"
+	ReasonQueueFull = "queue_full"
+	ReasonError     = "error"
+)
+
+var (
+	// Payloads received by source, either middleware or otlp
+	Payloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payloads_total",
+		Help:      "Payloads of logged requests received.",
+	}, []string{"source"})
+
+	RowsAccepted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_accepted_total",
+		Help:      "Logged requests accepted to be stored.",
+	})
+
+	RowsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_rejected_total",
+		Help:      "Logged requests rejected before being stored, by reason.",
+	}, []string{"reason"})
+
+	RateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Payloads rejected by the per-key rate limit.",
+	})
+
+	// Database errors by operation, such as store or quota
+	DBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Failed database operations.",
+	}, []string{"operation"})
+
+	InsertDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "insert_duration_seconds",
+		Help:      "Time taken to store a batch of logged requests in one transaction.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Logged requests stored per transaction.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 9),
+	})
+
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Middleware records the latency of each request against its route.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Route templates keep the number of label values bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HandlerDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// RegisterQueue exposes the length and capacity of the ingestion queue.
+func RegisterQueue(length func() int, capacity int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_length",
+		Help:      "Payloads waiting in the ingestion queue.",
+	}, func() float64 {
+		return float64(length())
+	})
+	promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_capacity",
+		Help:      "Maximum payloads held by the ingestion queue.",
+	}).Set(float64(capacity))
+}
+
+// Serve serves the registered metrics at /metrics on their own port, kept
+// apart from the service's public routes.
+func Serve(port int) error {
+	app := gin.New()
+	app.GET("/metrics", Handler())
+	return app.Run(fmt.Sprintf(":%d", port))
+}
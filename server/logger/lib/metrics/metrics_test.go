@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.Use(Middleware())
+	app.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	app.GET("/metrics", Handler())
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are labelled by route rather than path
+	if n := testutil.CollectAndCount(HandlerDuration); n != 2 {
+		t.Errorf("got %d series, expected 2", n)
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, expected := range []string{
+		`logger_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`logger_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in metrics", expected)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Span kinds
+const (
+	SpanKindUnspecified = 0
+	SpanKindInternal    = 1
+	SpanKindServer      = 2
+	SpanKindClient      = 3
+	SpanKindProducer    = 4
+	SpanKindConsumer    = 5
+)
+
+// Span holds the fields of an OTLP span needed to record a logged request
+type Span struct {
+	TraceID    string // Hex encoded
+	SpanID     string // Hex encoded
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any // Span attributes merged over resource attributes
+}
+
+// String returns the first of the attributes set to a non-empty string.
+func (s Span) String(keys ...string) string {
+	for _, key := range keys {
+		if value, ok := s.Attributes[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Int returns the first of the attributes set to an integer, or to a string
+// holding one.
+func (s Span) Int(keys ...string) (int64, bool) {
+	for _, key := range keys {
+		switch value := s.Attributes[key].(type) {
+		case int64:
+			return value, true
+		case float64:
+			return int64(value), true
+		case string:
+			if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Decode parses an ExportTraceServiceRequest body in either of the OTLP/HTTP
+// encodings, protobuf or JSON.
+func Decode(body []byte, contentType string) ([]Span, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedContentType
+	}
+	switch mediaType {
+	case "application/x-protobuf":
+		return DecodeProtobuf(body)
+	case "application/json":
+		return DecodeJSON(body)
+	default:
+		return nil, ErrUnsupportedContentType
+	}
+}
+
+// Field numbers from opentelemetry/proto/trace/v1/trace.proto and
+// opentelemetry/proto/common/v1/common.proto
+const (
+	requestResourceSpans = 1
+
+	resourceSpansResource   = 1
+	resourceSpansScopeSpans = 2
+
+	resourceAttributes = 1
+
+	scopeSpansSpans = 2
+
+	spanTraceID    = 1
+	spanSpanID     = 2
+	spanName       = 5
+	spanKind       = 6
+	spanStartTime  = 7
+	spanEndTime    = 8
+	spanAttributes = 9
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+	anyValueBool   = 2
+	anyValueInt    = 3
+	anyValueDouble = 4
+)
+
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	scalar uint64
+}
+
+// Calls fn with each field of a protobuf message, skipping unknown types
+func parseFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.scalar, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.scalar, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.scalar = uint64(v)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeProtobuf parses a protobuf encoded ExportTraceServiceRequest.
+func DecodeProtobuf(data []byte) ([]Span, error) {
+	spans := make([]Span, 0)
+	err := parseFields(data, func(f field) error {
+		if f.num != requestResourceSpans || f.typ != protowire.BytesType {
+			return nil
+		}
+		resourceSpans, err := decodeResourceSpans(f.bytes)
+		spans = append(spans, resourceSpans...)
+		return err
+	})
+	return spans, err
+}
+
+func decodeResourceSpans(data []byte) ([]Span, error) {
+	resource := make(map[string]any)
+	spans := make([]Span, 0)
+	err := parseFields(data, func(f field) error {
+		if f.typ != protowire.BytesType {
+			return nil
+		}
+		switch f.num {
+		case resourceSpansResource:
+			return parseFields(f.bytes, func(f field) error {
+				if f.num == resourceAttributes && f.typ == protowire.BytesType {
+					return decodeKeyValue(f.bytes, resource)
+				}
+				return nil
+			})
+		case resourceSpansScopeSpans:
+			return parseFields(f.bytes, func(f field) error {
+				if f.num != scopeSpansSpans || f.typ != protowire.BytesType {
+					return nil
+				}
+				span, err := decodeSpan(f.bytes)
+				spans = append(spans, span)
+				return err
+			})
+		}
+		return nil
+	})
+
+	// The resource may follow its spans in the encoding
+	for _, span := range spans {
+		mergeAttributes(span.Attributes, resource)
+	}
+	return spans, err
+}
+
+func decodeSpan(data []byte) (Span, error) {
+	span := Span{Attributes: make(map[string]any)}
+	err := parseFields(data, func(f field) error {
+		switch f.num {
+		case spanTraceID:
+			span.TraceID = hex.EncodeToString(f.bytes)
+		case spanSpanID:
+			span.SpanID = hex.EncodeToString(f.bytes)
+		case spanName:
+			span.Name = string(f.bytes)
+		case spanKind:
+			span.Kind = int(f.scalar)
+		case spanStartTime:
+			span.Start = unixNano(f.scalar)
+		case spanEndTime:
+			span.End = unixNano(f.scalar)
+		case spanAttributes:
+			if f.typ == protowire.BytesType {
+				return decodeKeyValue(f.bytes, span.Attributes)
+			}
+		}
+		return nil
+	})
+	return span, err
+}
+
+func decodeKeyValue(data []byte, attributes map[string]any) error {
+	var key string
+	var value any
+	err := parseFields(data, func(f field) error {
+		switch f.num {
+		case keyValueKey:
+			key = string(f.bytes)
+		case keyValueValue:
+			return parseFields(f.bytes, func(f field) error {
+				switch f.num {
+				case anyValueString:
+					value = string(f.bytes)
+				case anyValueBool:
+					value = f.scalar != 0
+				case anyValueInt:
+					value = int64(f.scalar)
+				case anyValueDouble:
+					value = math.Float64frombits(f.scalar)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if key != "" && value != nil {
+		attributes[key] = value
+	}
+	return err
+}
+
+// JSON encoding of the request, with 64-bit integers allowed as either
+// numbers or strings
+type jsonRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID           string         `json:"traceId"`
+				SpanID            string         `json:"spanId"`
+				Name              string         `json:"name"`
+				Kind              jsonSpanKind   `json:"kind"`
+				StartTimeUnixNano json.Number    `json:"startTimeUnixNano"`
+				EndTimeUnixNano   json.Number    `json:"endTimeUnixNano"`
+				Attributes        []jsonKeyValue `json:"attributes"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type jsonKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string      `json:"stringValue"`
+		BoolValue   *bool        `json:"boolValue"`
+		IntValue    *json.Number `json:"intValue"`
+		DoubleValue *float64     `json:"doubleValue"`
+	} `json:"value"`
+}
+
+// Span kind given as either its number or its enum name
+type jsonSpanKind int
+
+var spanKindNames = map[string]jsonSpanKind{
+	"SPAN_KIND_UNSPECIFIED": SpanKindUnspecified,
+	"SPAN_KIND_INTERNAL":    SpanKindInternal,
+	"SPAN_KIND_SERVER":      SpanKindServer,
+	"SPAN_KIND_CLIENT":      SpanKindClient,
+	"SPAN_KIND_PRODUCER":    SpanKindProducer,
+	"SPAN_KIND_CONSUMER":    SpanKindConsumer,
+}
+
+func (k *jsonSpanKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		kind, ok := spanKindNames[name]
+		if !ok {
+			return fmt.Errorf("unknown span kind %s", name)
+		}
+		*k = kind
+		return nil
+	}
+	var kind int
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return err
+	}
+	*k = jsonSpanKind(kind)
+	return nil
+}
+
+// DecodeJSON parses a JSON encoded ExportTraceServiceRequest.
+func DecodeJSON(data []byte) ([]Span, error) {
+	var request jsonRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, err
+	}
+
+	spans := make([]Span, 0)
+	for _, resourceSpans := range request.ResourceSpans {
+		resource := jsonAttributes(resourceSpans.Resource.Attributes)
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, s := range scopeSpans.Spans {
+				span := Span{
+					TraceID:    strings.ToLower(s.TraceID),
+					SpanID:     strings.ToLower(s.SpanID),
+					Name:       s.Name,
+					Kind:       int(s.Kind),
+					Attributes: jsonAttributes(s.Attributes),
+				}
+				if start, err := strconv.ParseUint(s.StartTimeUnixNano.String(), 10, 64); err == nil {
+					span.Start = unixNano(start)
+				}
+				if end, err := strconv.ParseUint(s.EndTimeUnixNano.String(), 10, 64); err == nil {
+					span.End = unixNano(end)
+				}
+				mergeAttributes(span.Attributes, resource)
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans, nil
+}
+
+func jsonAttributes(keyValues []jsonKeyValue) map[string]any {
+	attributes := make(map[string]any, len(keyValues))
+	for _, kv := range keyValues {
+		switch {
+		case kv.Value.StringValue != nil:
+			attributes[kv.Key] = *kv.Value.StringValue
+		case kv.Value.BoolValue != nil:
+			attributes[kv.Key] = *kv.Value.BoolValue
+		case kv.Value.IntValue != nil:
+			if i, err := kv.Value.IntValue.Int64(); err == nil {
+				attributes[kv.Key] = i
+			}
+		case kv.Value.DoubleValue != nil:
+			attributes[kv.Key] = *kv.Value.DoubleValue
+		}
+	}
+	return attributes
+}
+
+// Adds resource attributes not overridden by the span
+func mergeAttributes(attributes map[string]any, resource map[string]any) {
+	for key, value := range resource {
+		if _, ok := attributes[key]; !ok {
+			attributes[key] = value
+		}
+	}
+}
+
+func unixNano(nanoseconds uint64) time.Time {
+	if nanoseconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(nanoseconds)).UTC()
+}
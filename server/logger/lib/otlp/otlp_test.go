@@ -0,0 +1,158 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func keyValue(key string, value []byte) []byte {
+	kv := appendString(nil, keyValueKey, key)
+	return appendMessage(kv, keyValueValue, value)
+}
+
+func stringValue(value string) []byte {
+	return appendString(nil, anyValueString, value)
+}
+
+func intValue(value int64) []byte {
+	b := protowire.AppendTag(nil, anyValueInt, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(value))
+}
+
+func doubleValue(value float64) []byte {
+	b := protowire.AppendTag(nil, anyValueDouble, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(value))
+}
+
+const (
+	testTraceID = "5b8efff798038103d269b633813fc60c"
+	testSpanID  = "eee19b7ec3c1b174"
+)
+
+var (
+	testStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	testEnd   = testStart.Add(25 * time.Millisecond)
+)
+
+func checkSpan(t *testing.T, span Span) {
+	t.Helper()
+	if span.TraceID != testTraceID || span.SpanID != testSpanID {
+		t.Errorf("got trace %q span %q", span.TraceID, span.SpanID)
+	}
+	if span.Name != "GET /users/{id}" || span.Kind != SpanKindServer {
+		t.Errorf("got %q kind %d", span.Name, span.Kind)
+	}
+	if !span.Start.Equal(testStart) || !span.End.Equal(testEnd) {
+		t.Errorf("got %v to %v", span.Start, span.End)
+	}
+	if route := span.String("http.route"); route != "/users/{id}" {
+		t.Errorf("got route %q", route)
+	}
+	if status, ok := span.Int("http.response.status_code"); !ok || status != 200 {
+		t.Errorf("got status %d", status)
+	}
+	if ratio, _ := span.Attributes["sample.ratio"].(float64); ratio != 0.5 {
+		t.Errorf("got ratio %v", ratio)
+	}
+	// Span attributes take priority over resource attributes
+	if host := span.String("server.address"); host != "api.example.com" {
+		t.Errorf("got host %q", host)
+	}
+	if service := span.String("service.name"); service != "users" {
+		t.Errorf("got service %q", service)
+	}
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	traceID, _ := hex.DecodeString(testTraceID)
+	spanID, _ := hex.DecodeString(testSpanID)
+
+	var span []byte
+	span = appendMessage(span, spanTraceID, traceID)
+	span = appendMessage(span, spanSpanID, spanID)
+	span = appendString(span, spanName, "GET /users/{id}")
+	span = protowire.AppendTag(span, spanKind, protowire.VarintType)
+	span = protowire.AppendVarint(span, SpanKindServer)
+	span = protowire.AppendTag(span, spanStartTime, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, uint64(testStart.UnixNano()))
+	span = protowire.AppendTag(span, spanEndTime, protowire.Fixed64Type)
+	span = protowire.AppendFixed64(span, uint64(testEnd.UnixNano()))
+	span = appendMessage(span, spanAttributes, keyValue("http.route", stringValue("/users/{id}")))
+	span = appendMessage(span, spanAttributes, keyValue("http.response.status_code", intValue(200)))
+	span = appendMessage(span, spanAttributes, keyValue("sample.ratio", doubleValue(0.5)))
+	span = appendMessage(span, spanAttributes, keyValue("server.address", stringValue("api.example.com")))
+
+	scopeSpans := appendMessage(nil, scopeSpansSpans, span)
+	resource := appendMessage(nil, resourceAttributes, keyValue("service.name", stringValue("users")))
+	resource = appendMessage(resource, resourceAttributes, keyValue("server.address", stringValue("internal")))
+
+	// Resource encoded after its spans
+	resourceSpans := appendMessage(nil, resourceSpansScopeSpans, scopeSpans)
+	resourceSpans = appendMessage(resourceSpans, resourceSpansResource, resource)
+	request := appendMessage(nil, requestResourceSpans, resourceSpans)
+
+	spans, err := Decode(request, "application/x-protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, expected 1", len(spans))
+	}
+	checkSpan(t, spans[0])
+
+	if _, err := DecodeProtobuf(request[:len(request)-3]); err == nil {
+		t.Error("expected truncated message error")
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	request := `{"resourceSpans": [{
+		"resource": {"attributes": [
+			{"key": "service.name", "value": {"stringValue": "users"}},
+			{"key": "server.address", "value": {"stringValue": "internal"}}
+		]},
+		"scopeSpans": [{"scope": {"name": "otelhttp"}, "spans": [{
+			"traceId": "5B8EFFF798038103D269B633813FC60C",
+			"spanId": "eee19b7ec3c1b174",
+			"name": "GET /users/{id}",
+			"kind": "SPAN_KIND_SERVER",
+			"startTimeUnixNano": "1704110400000000000",
+			"endTimeUnixNano": 1704110400025000000,
+			"attributes": [
+				{"key": "http.route", "value": {"stringValue": "/users/{id}"}},
+				{"key": "http.response.status_code", "value": {"intValue": "200"}},
+				{"key": "sample.ratio", "value": {"doubleValue": 0.5}},
+				{"key": "server.address", "value": {"stringValue": "api.example.com"}}
+			]
+		}]}]
+	}]}`
+
+	spans, err := Decode([]byte(request), "application/json; charset=utf-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, expected 1", len(spans))
+	}
+	checkSpan(t, spans[0])
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	if _, err := Decode([]byte("{}"), "text/plain"); err != ErrUnsupportedContentType {
+		t.Errorf("got %v, expected unsupported content type", err)
+	}
+}
@@ -0,0 +1,292 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrFull = errors.New("queue is full")
+var ErrClosed = errors.New("queue is closed")
+
+type Options[T any] struct {
+	Capacity      int              // Maximum number of items waiting to be processed
+	Workers       int              // Number of items processed concurrently
+	MaxBatchSize  int              // Maximum combined size of items coalesced into one call to Process
+	FlushInterval time.Duration    // Maximum time a worker waits to fill a batch
+	WALPath       string           // Optional file to persist queued items until processed
+	Size          func(item T) int // Size of an item counted towards MaxBatchSize, defaults to 1
+	Process       func(items []T) error
+	OnError       func(items []T, err error) // Called with items dropped after failing to process
+	Retryable     func(err error) bool       // Reports whether a failure may pass, keeping items to be retried. Defaults to never
+	RetryDelay    time.Duration              // Delay before the first retry, doubled after each failure
+}
+
+// Longest delay between retries of items failing to process
+const maxRetryDelay = time.Minute
+
+// Queue is a bounded in-memory queue drained by a pool of workers, which
+// coalesce queued items into larger batches.
+type Queue[T any] struct {
+	options Options[T]
+	items   chan entry[T]
+	done    chan struct{} // Closed when the queue is closed, ending retries
+	wal     *wal
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+type entry[T any] struct {
+	id   uint64 // Write-ahead file record ID, zero when not persisted
+	item T
+}
+
+func New[T any](options Options[T]) (*Queue[T], error) {
+	if options.Capacity <= 0 {
+		options.Capacity = 1
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = 1
+	}
+	if options.Size == nil {
+		options.Size = func(T) int { return 1 }
+	}
+	if options.Retryable == nil {
+		options.Retryable = func(error) bool { return false }
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = time.Second
+	}
+
+	q := &Queue[T]{
+		options: options,
+		items:   make(chan entry[T], options.Capacity),
+		done:    make(chan struct{}),
+	}
+
+	if options.WALPath != "" {
+		w, records, err := openWAL(options.WALPath)
+		if err != nil {
+			return nil, err
+		}
+		q.wal = w
+
+		// Restore items left unprocessed when the service last stopped,
+		// growing the queue if needed so none are lost
+		if len(records) > options.Capacity {
+			q.items = make(chan entry[T], len(records)+options.Capacity)
+		}
+		for _, record := range records {
+			var item T
+			if err := json.Unmarshal(record.Item, &item); err != nil {
+				w.ack([]uint64{record.ID})
+				continue
+			}
+			q.items <- entry[T]{id: record.ID, item: item}
+		}
+	}
+
+	for i := 0; i < options.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return q, nil
+}
+
+// Enqueue adds an item without blocking, returning ErrFull if the queue is at
+// capacity.
+func (q *Queue[T]) Enqueue(item T) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if len(q.items) >= cap(q.items) {
+		return ErrFull
+	}
+
+	e := entry[T]{item: item}
+	if q.wal != nil {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		e.id, err = q.wal.append(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case q.items <- e:
+		return nil
+	default:
+		if q.wal != nil {
+			q.wal.ack([]uint64{e.id})
+		}
+		return ErrFull
+	}
+}
+
+// Len returns the number of items waiting to be processed.
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+func (q *Queue[T]) Cap() int {
+	return cap(q.items)
+}
+
+// Close stops accepting new items and waits for queued items to be processed.
+// Items still failing with a retryable error are tried once more, then left in
+// the write-ahead file to be retried when the queue is next opened.
+func (q *Queue[T]) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.items)
+	close(q.done)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+	if q.wal != nil {
+		return q.wal.close()
+	}
+	return nil
+}
+
+func (q *Queue[T]) work() {
+	defer q.wg.Done()
+
+	for {
+		first, ok := <-q.items
+		if !ok {
+			return
+		}
+		batch := q.fill([]entry[T]{first})
+		q.process(batch)
+	}
+}
+
+// Coalesces further queued items into the batch until it reaches the maximum
+// size or nothing more arrives within the flush interval
+func (q *Queue[T]) fill(batch []entry[T]) []entry[T] {
+	size := q.options.Size(batch[0].item)
+	if size >= q.options.MaxBatchSize {
+		return batch
+	}
+
+	timer := time.NewTimer(q.options.FlushInterval)
+	defer timer.Stop()
+
+	for size < q.options.MaxBatchSize {
+		select {
+		case e, ok := <-q.items:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, e)
+			size += q.options.Size(e.item)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// Processes a batch, retrying items failing with a retryable error until they
+// succeed or the queue is closed
+func (q *Queue[T]) process(batch []entry[T]) {
+	failed, err := q.attempt(batch)
+	delay := q.options.RetryDelay
+	for len(failed) > 0 {
+		select {
+		case <-time.After(delay):
+		case <-q.done:
+			// Kept in the write-ahead file if there is one, otherwise lost
+			if q.wal == nil && q.options.OnError != nil {
+				q.options.OnError(values(failed), err)
+			}
+			return
+		}
+		failed, err = q.attempt(failed)
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// Processes a batch once, returning the items that failed with a retryable
+// error. Items processed, or failing with an error retrying cannot fix, are
+// acknowledged.
+func (q *Queue[T]) attempt(batch []entry[T]) ([]entry[T], error) {
+	err := q.options.Process(values(batch))
+	if err == nil {
+		q.ack(batch)
+		return nil, nil
+	}
+	if q.options.Retryable(err) {
+		return batch, err
+	}
+	if len(batch) == 1 {
+		q.drop(batch, err)
+		return nil, nil
+	}
+
+	// Avoid one invalid item failing the whole coalesced batch
+	var failed, finished []entry[T]
+	var failedErr error
+	for _, e := range batch {
+		err := q.options.Process([]T{e.item})
+		if err != nil && q.options.Retryable(err) {
+			failed = append(failed, e)
+			failedErr = err
+			continue
+		}
+		if err != nil && q.options.OnError != nil {
+			q.options.OnError([]T{e.item}, err)
+		}
+		finished = append(finished, e)
+	}
+	q.ack(finished)
+	return failed, failedErr
+}
+
+func (q *Queue[T]) drop(batch []entry[T], err error) {
+	if q.options.OnError != nil {
+		q.options.OnError(values(batch), err)
+	}
+	q.ack(batch)
+}
+
+// Removes processed items from the write-ahead file
+func (q *Queue[T]) ack(batch []entry[T]) {
+	if q.wal == nil {
+		return
+	}
+	ids := make([]uint64, 0, len(batch))
+	for _, e := range batch {
+		if e.id != 0 {
+			ids = append(ids, e.id)
+		}
+	}
+	if err := q.wal.ack(ids); err != nil && q.options.OnError != nil {
+		q.options.OnError(nil, err)
+	}
+}
+
+func values[T any](batch []entry[T]) []T {
+	items := make([]T, len(batch))
+	for i, e := range batch {
+		items[i] = e.item
+	}
+	return items
+}
@@ -0,0 +1,323 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+	var mu sync.Mutex
+	batches := make([][]int, 0)
+	release := make(chan struct{})
+
+	q, err := New(Options[int]{
+		Capacity:      10,
+		Workers:       1,
+		MaxBatchSize:  100,
+		FlushInterval: 50 * time.Millisecond,
+		Process: func(items []int) error {
+			<-release
+			mu.Lock()
+			batches = append(batches, items)
+			mu.Unlock()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	q.Close()
+
+	if len(batches) != 1 || len(batches[0]) != 5 {
+		t.Errorf("got batches %v, expected a single batch of 5", batches)
+	}
+}
+
+func TestFull(t *testing.T) {
+	block := make(chan struct{})
+	q, err := New(Options[int]{
+		Capacity:      2,
+		Workers:       1,
+		MaxBatchSize:  1,
+		FlushInterval: time.Millisecond,
+		Process: func(items []int) error {
+			<-block
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First item is taken by the blocked worker, next two fill the queue
+	q.Enqueue(0)
+	time.Sleep(10 * time.Millisecond)
+	q.Enqueue(1)
+	q.Enqueue(2)
+	if err := q.Enqueue(3); !errors.Is(err, ErrFull) {
+		t.Errorf("got %v, expected %v", err, ErrFull)
+	}
+
+	close(block)
+	q.Close()
+	if err := q.Enqueue(4); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, expected %v", err, ErrClosed)
+	}
+}
+
+func TestFailedItemsRetriedIndividually(t *testing.T) {
+	var mu sync.Mutex
+	stored := make([]int, 0)
+	dropped := make([]int, 0)
+	release := make(chan struct{})
+
+	q, err := New(Options[int]{
+		Capacity:      10,
+		Workers:       1,
+		MaxBatchSize:  10,
+		FlushInterval: 50 * time.Millisecond,
+		Process: func(items []int) error {
+			<-release
+			for _, item := range items {
+				if item < 0 {
+					return errors.New("invalid item")
+				}
+			}
+			mu.Lock()
+			stored = append(stored, items...)
+			mu.Unlock()
+			return nil
+		},
+		OnError: func(items []int, err error) {
+			dropped = append(dropped, items...)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(1)
+	q.Enqueue(-1)
+	q.Enqueue(2)
+	close(release)
+	q.Close()
+
+	if len(stored) != 2 || len(dropped) != 1 || dropped[0] != -1 {
+		t.Errorf("got stored %v and dropped %v, expected [1 2] and [-1]", stored, dropped)
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	block := make(chan struct{})
+
+	q, err := New(Options[string]{
+		Capacity:      10,
+		Workers:       1,
+		MaxBatchSize:  1,
+		FlushInterval: time.Millisecond,
+		WALPath:       path,
+		Process: func(items []string) error {
+			<-block
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("a")
+	q.Enqueue("b")
+
+	// Simulate a crash by abandoning the queue without processing
+	q.wal.close()
+
+	var mu sync.Mutex
+	replayed := make([]string, 0)
+	q2, err := New(Options[string]{
+		Capacity:      10,
+		Workers:       1,
+		MaxBatchSize:  10,
+		FlushInterval: time.Millisecond,
+		WALPath:       path,
+		Process: func(items []string) error {
+			mu.Lock()
+			replayed = append(replayed, items...)
+			mu.Unlock()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q2.Close()
+	close(block)
+
+	if len(replayed) != 2 {
+		t.Errorf("got %v, expected [a b] to be replayed", replayed)
+	}
+
+	// All items acknowledged, nothing left to replay
+	w, records, err := openWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	if len(records) != 0 {
+		t.Errorf("got %d records, expected 0", len(records))
+	}
+}
+
+var errUnavailable = errors.New("database unavailable")
+
+func TestRetryableFailuresRetried(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	stored := make([]int, 0)
+	dropped := make([]int, 0)
+
+	q, err := New(Options[int]{
+		Capacity:      10,
+		Workers:       1,
+		MaxBatchSize:  10,
+		FlushInterval: time.Millisecond,
+		RetryDelay:    time.Millisecond,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errUnavailable)
+		},
+		Process: func(items []int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts++
+			if attempts < 3 {
+				return errUnavailable
+			}
+			stored = append(stored, items...)
+			return nil
+		},
+		OnError: func(items []int, err error) {
+			dropped = append(dropped, items...)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Enqueue(1)
+	time.Sleep(50 * time.Millisecond)
+	q.Close()
+
+	if len(stored) != 1 || len(dropped) != 0 {
+		t.Errorf("got stored %v and dropped %v after %d attempts, expected [1] and []", stored, dropped, attempts)
+	}
+}
+
+func TestRetryableFailuresKeptInWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	dropped := make([]string, 0)
+
+	q, err := New(Options[string]{
+		Capacity:      10,
+		Workers:       1,
+		MaxBatchSize:  10,
+		FlushInterval: time.Millisecond,
+		WALPath:       path,
+		RetryDelay:    time.Hour,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errUnavailable)
+		},
+		Process: func(items []string) error {
+			for _, item := range items {
+				if item == "invalid" {
+					return errors.New("invalid item")
+				}
+			}
+			return errUnavailable
+		},
+		OnError: func(items []string, err error) {
+			dropped = append(dropped, items...)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Enqueue("a")
+	q.Enqueue("invalid")
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+
+	if len(dropped) != 1 || dropped[0] != "invalid" {
+		t.Errorf("got dropped %v, expected [invalid]", dropped)
+	}
+
+	// Only the item that failed to store for a retryable reason is kept
+	w, records, err := openWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	if len(records) != 1 || string(records[0].Item) != `"a"` {
+		t.Errorf("got %d records, expected only a to be kept", len(records))
+	}
+}
+
+func TestWALCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.wal")
+	w, _, err := openWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]uint64, 0)
+	for i := 0; i < 100; i++ {
+		id, err := w.append(json.RawMessage(fmt.Sprintf(`"%d"`, i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := w.ack(ids[:50]); err != nil {
+		t.Fatal(err)
+	}
+	// Small files are left for acknowledgements to accumulate
+	before := w.size
+	if err := w.ack(ids[50:51]); err != nil {
+		t.Fatal(err)
+	}
+	if w.size <= before {
+		t.Errorf("got %d byte file, expected the acknowledgement to be appended", w.size)
+	}
+
+	if err := w.compact(); err != nil {
+		t.Fatal(err)
+	}
+	if w.size >= before {
+		t.Errorf("got %d byte file, expected compaction to remove acknowledged items", w.size)
+	}
+	w.close()
+
+	w, records, err := openWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	if len(records) != 49 {
+		t.Fatalf("got %d records, expected 49", len(records))
+	}
+	for i, record := range records {
+		if record.ID != ids[51+i] {
+			t.Fatalf("got record %d at %d, expected items replayed in the order written", record.ID, i)
+		}
+	}
+}
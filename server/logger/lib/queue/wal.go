@@ -0,0 +1,197 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Write-ahead file is compacted once it is at least this size and over half
+// of it is no longer needed, so compaction takes time in proportion to the
+// items acknowledged since the last
+const minCompactSize int64 = 1 << 20
+
+// Append-only file of queued items, with acknowledgement records written once
+// an item has been processed. Unacknowledged items are replayed on startup.
+type wal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	live    int64 // Size of the unacknowledged items
+	nextID  uint64
+	pending map[uint64]json.RawMessage
+}
+
+type walRecord struct {
+	ID   uint64          `json:"id"`
+	Ack  bool            `json:"ack,omitempty"`
+	Item json.RawMessage `json:"item,omitempty"`
+}
+
+// Opens the write-ahead file at path, returning any unacknowledged items in
+// the order they were written
+func openWAL(path string) (*wal, []walRecord, error) {
+	w := &wal{
+		path:    path,
+		nextID:  1,
+		pending: make(map[uint64]json.RawMessage),
+	}
+
+	order, err := w.replay()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records := make([]walRecord, 0, len(order))
+	for _, id := range order {
+		if item, ok := w.pending[id]; ok {
+			records = append(records, walRecord{ID: id, Item: item})
+		}
+	}
+
+	// Start from a file holding only the outstanding items
+	if err := w.compact(); err != nil {
+		return nil, nil, err
+	}
+	return w, records, nil
+}
+
+func (w *wal) replay() ([]uint64, error) {
+	file, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	order := make([]uint64, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var record walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Partially written final line from a crash
+			continue
+		}
+		if record.ID >= w.nextID {
+			w.nextID = record.ID + 1
+		}
+		if record.Ack {
+			w.live -= int64(len(w.pending[record.ID]))
+			delete(w.pending, record.ID)
+		} else {
+			w.pending[record.ID] = record.Item
+			w.live += int64(len(record.Item))
+			order = append(order, record.ID)
+		}
+	}
+	return order, scanner.Err()
+}
+
+func (w *wal) append(item json.RawMessage) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	if err := w.write(walRecord{ID: id, Item: item}); err != nil {
+		return 0, err
+	}
+	if err := w.file.Sync(); err != nil {
+		return 0, err
+	}
+	w.nextID++
+	w.pending[id] = item
+	w.live += int64(len(item))
+	return id, nil
+}
+
+func (w *wal) ack(ids []uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, id := range ids {
+		if err := w.write(walRecord{ID: id, Ack: true}); err != nil {
+			return err
+		}
+		w.live -= int64(len(w.pending[id]))
+		delete(w.pending, id)
+	}
+
+	if w.size >= minCompactSize && w.size > 2*w.live {
+		return w.compact()
+	}
+	return nil
+}
+
+func (w *wal) write(record walRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// Rewrites the file with only the unacknowledged items, in the order they were
+// written
+func (w *wal) compact() error {
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint64, 0, len(w.pending))
+	for id := range w.pending {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var size int64
+	for _, id := range ids {
+		line, err := json.Marshal(walRecord{ID: id, Item: w.pending[id]})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		n, err := tmp.Write(append(line, '\n'))
+		size += int64(n)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file = tmp
+	w.size = size
+	return nil
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
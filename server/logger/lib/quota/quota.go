@@ -0,0 +1,167 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+// How long an account's quota is used before being read from the database
+// again
+const cacheDuration = time.Minute
+
+// Accounts tracked before idle accounts are dropped early
+const maxAccounts = 100_000
+
+// How often accounts idle since an earlier day are dropped
+const pruneInterval = time.Hour
+
+// Limiter tracks the number of logged requests accepted for each API key
+// against the per-minute and per-day limits of its quota.
+type Limiter struct {
+	db           database.Querier
+	defaultQuota database.Quota
+	mu           sync.Mutex
+	accounts     map[string]*account
+	pruned       time.Time
+	now          func() time.Time
+}
+
+type account struct {
+	quota      database.Quota
+	fetched    time.Time
+	minute     time.Time // Start of the current minute window
+	minuteRows int
+	day        time.Time // Start of the current UTC day
+	dayRows    int
+}
+
+// NewLimiter creates a limiter reading quotas from the database, falling back
+// to defaultQuota if they cannot be read.
+func NewLimiter(db database.Querier, defaultQuota database.Quota) *Limiter {
+	return &Limiter{
+		db:           db,
+		defaultQuota: defaultQuota,
+		accounts:     make(map[string]*account),
+		now:          time.Now,
+	}
+}
+
+// Quota returns the API key's quota, read from the database at most once per
+// cache duration. A returned error means the quota could not be read and the
+// default was used.
+func (l *Limiter) Quota(ctx context.Context, apiKey string) (database.Quota, error) {
+	a, err := l.account(ctx, apiKey, l.now())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return a.quota, err
+}
+
+func (l *Limiter) account(ctx context.Context, apiKey string, now time.Time) (*account, error) {
+	l.mu.Lock()
+	a, ok := l.accounts[apiKey]
+	stale := !ok || now.Sub(a.fetched) >= cacheDuration
+	l.mu.Unlock()
+
+	if !stale {
+		return a, nil
+	}
+
+	// Read outside the lock to avoid blocking other accounts
+	quota, err := database.GetQuota(ctx, l.db, apiKey)
+	if err != nil {
+		quota = l.defaultQuota
+	}
+
+	l.mu.Lock()
+	if len(l.accounts) >= maxAccounts || now.Sub(l.pruned) >= pruneInterval {
+		l.prune(now)
+	}
+	a, ok = l.accounts[apiKey]
+	if !ok {
+		a = &account{}
+		l.accounts[apiKey] = a
+	}
+	a.quota = quota
+	a.fetched = now
+	l.mu.Unlock()
+
+	return a, err
+}
+
+// Allow records up to rows logged requests against the API key's quota and
+// returns how many can be accepted. A returned error means the quota could
+// not be read and the default was used.
+func (l *Limiter) Allow(ctx context.Context, apiKey string, rows int) (int, error) {
+	now := l.now()
+	a, err := l.account(ctx, apiKey, now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	minute := now.Truncate(time.Minute)
+	if !a.minute.Equal(minute) {
+		a.minute = minute
+		a.minuteRows = 0
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !a.day.Equal(day) {
+		a.day = day
+		a.dayRows = 0
+	}
+
+	allowed := min(rows, a.quota.RowsPerMinute-a.minuteRows, a.quota.RowsPerDay-a.dayRows)
+	if allowed < 0 {
+		allowed = 0
+	}
+	a.minuteRows += allowed
+	a.dayRows += allowed
+
+	return allowed, err
+}
+
+// Refund returns rows accepted by Allow that were then not stored to the API
+// key's quota. Rows are only returned to windows that are still current.
+func (l *Limiter) Refund(apiKey string, rows int) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.accounts[apiKey]
+	if !ok {
+		return
+	}
+	if a.minute.Equal(now.Truncate(time.Minute)) {
+		a.minuteRows = max(a.minuteRows-rows, 0)
+	}
+	if a.day.Equal(now.UTC().Truncate(24 * time.Hour)) {
+		a.dayRows = max(a.dayRows-rows, 0)
+	}
+}
+
+// Drops accounts idle since an earlier day, whose counts no longer apply. If
+// too many accounts remain, those idle for a cache duration are dropped too,
+// losing their daily counts, then every account if none are idle.
+func (l *Limiter) prune(now time.Time) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	for apiKey, a := range l.accounts {
+		if now.Sub(a.fetched) >= cacheDuration && a.day.Before(today) {
+			delete(l.accounts, apiKey)
+		}
+	}
+	if len(l.accounts) >= maxAccounts {
+		for apiKey, a := range l.accounts {
+			if now.Sub(a.fetched) >= cacheDuration {
+				delete(l.accounts, apiKey)
+			}
+		}
+	}
+	if len(l.accounts) >= maxAccounts {
+		l.accounts = make(map[string]*account)
+	}
+	l.pruned = now
+}
@@ -0,0 +1,158 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+// Database stub returning a fixed quota for every API key
+type stubDB struct {
+	quota database.Quota
+	err   error
+}
+
+func (s stubDB) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, s.err
+}
+
+func (s stubDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, s.err
+}
+
+func (s stubDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return stubRow(s)
+}
+
+type stubRow stubDB
+
+func (r stubRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*string) = r.quota.Plan
+	*dest[1].(*int) = r.quota.RowsPerMinute
+	*dest[2].(*int) = r.quota.RowsPerDay
+	*dest[3].(*int) = r.quota.RetentionRows
+	*dest[4].(*int) = r.quota.MonitorCount
+	*dest[5].(*bool) = r.quota.DropBots
+	return nil
+}
+
+func TestAllow(t *testing.T) {
+	quota := database.Quota{Plan: "test", RowsPerMinute: 100, RowsPerDay: 250}
+	limiter := NewLimiter(stubDB{quota: quota}, database.DefaultQuota)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	expecteds := []struct {
+		rows    int
+		advance time.Duration
+		allowed int
+	}{
+		{60, 0, 60},
+		{60, 0, 40}, // Minute limit reached
+		{10, 0, 0},
+		{100, time.Minute, 100},
+		{100, time.Minute, 50}, // Day limit reached
+		{100, time.Minute, 0},
+		{100, 12 * time.Hour, 100}, // Next day
+	}
+
+	for i, expected := range expecteds {
+		now = now.Add(expected.advance)
+		allowed, err := limiter.Allow(context.Background(), "key", expected.rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != expected.allowed {
+			t.Errorf("%d: got %d, expected %d", i, allowed, expected.allowed)
+		}
+	}
+}
+
+func TestAllowDefaultQuota(t *testing.T) {
+	limiter := NewLimiter(stubDB{err: errors.New("connection refused")}, database.Quota{RowsPerMinute: 10, RowsPerDay: 10})
+
+	allowed, err := limiter.Allow(context.Background(), "key", 20)
+	if err == nil {
+		t.Error("expected quota read error")
+	}
+	if allowed != 10 {
+		t.Errorf("got %d, expected 10", allowed)
+	}
+}
+
+func TestQuotaCached(t *testing.T) {
+	db := &stubDB{quota: database.Quota{Plan: "test", DropBots: true}}
+	limiter := NewLimiter(db, database.DefaultQuota)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	quota, err := limiter.Quota(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !quota.DropBots {
+		t.Error("expected drop bots")
+	}
+
+	// Changes are picked up once the cached quota expires
+	db.quota.DropBots = false
+	if quota, _ := limiter.Quota(context.Background(), "key"); !quota.DropBots {
+		t.Error("expected cached quota")
+	}
+	now = now.Add(cacheDuration)
+	if quota, _ := limiter.Quota(context.Background(), "key"); quota.DropBots {
+		t.Error("expected refreshed quota")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	limiter := NewLimiter(stubDB{quota: database.Quota{RowsPerMinute: 100, RowsPerDay: 100}}, database.DefaultQuota)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow(context.Background(), "idle", 10)
+	limiter.Allow(context.Background(), "active", 10)
+
+	// Idle accounts are kept while their daily counts apply
+	now = now.Add(pruneInterval)
+	limiter.Allow(context.Background(), "active", 10)
+	if _, ok := limiter.accounts["idle"]; !ok {
+		t.Error("expected idle account to be kept within the day")
+	}
+
+	now = now.Add(12 * time.Hour)
+	limiter.Allow(context.Background(), "active", 10)
+	if _, ok := limiter.accounts["idle"]; ok {
+		t.Error("expected idle account to be dropped the next day")
+	}
+	if _, ok := limiter.accounts["active"]; !ok {
+		t.Error("expected active account to be kept")
+	}
+}
+
+func TestRefund(t *testing.T) {
+	limiter := NewLimiter(stubDB{quota: database.Quota{RowsPerMinute: 100, RowsPerDay: 150}}, database.DefaultQuota)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow(context.Background(), "key", 100)
+	limiter.Refund("key", 100)
+	if allowed, _ := limiter.Allow(context.Background(), "key", 100); allowed != 100 {
+		t.Errorf("got %d allowed, expected refunded rows to be accepted again", allowed)
+	}
+
+	// Rows reserved in an earlier minute are only returned to the day
+	now = now.Add(time.Minute)
+	limiter.Refund("key", 100)
+	if allowed, _ := limiter.Allow(context.Background(), "key", 150); allowed != 100 {
+		t.Errorf("got %d allowed, expected the minute limit of 100", allowed)
+	}
+}
@@ -0,0 +1,119 @@
+package useragent
+
+import (
+	"context"
+
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+// Identifies the bot a user agent belongs to, if any
+type BotMatcher func(userAgent string) (string, bool)
+
+// IDs returns the user_agents table IDs of the given user agents. Each user
+// agent is parsed once, when first stored, or if it was stored before user
+// agents were parsed.
+func IDs(ctx context.Context, db database.Querier, userAgents map[string]struct{}, matchBot BotMatcher) (map[string]int, error) {
+	ids, unparsed, err := getIDs(ctx, db, userAgents)
+	if err != nil || len(unparsed) == 0 {
+		return ids, err
+	}
+
+	if err := store(ctx, db, unparsed, matchBot); err != nil {
+		return ids, err
+	}
+	newIDs, _, err := getIDs(ctx, db, unparsed)
+	if err != nil {
+		return ids, err
+	}
+	for userAgent, id := range newIDs {
+		ids[userAgent] = id
+	}
+	return ids, nil
+}
+
+// Parses and stores user agents, updating any already stored
+func store(ctx context.Context, db database.Querier, userAgents map[string]struct{}, matchBot BotMatcher) error {
+	// One array per column keeps the statement within the bind parameter limit
+	var raw, browsers, browserVersions, oses, osVersions, deviceTypes, botNames []string
+	var bots []bool
+	for userAgent := range userAgents {
+		parsed := Parse(userAgent)
+		if name, ok := matchBot(userAgent); ok {
+			parsed.SetBot(name)
+		}
+		raw = append(raw, userAgent)
+		browsers = append(browsers, parsed.Browser)
+		browserVersions = append(browserVersions, parsed.BrowserVersion)
+		oses = append(oses, parsed.OS)
+		osVersions = append(osVersions, parsed.OSVersion)
+		deviceTypes = append(deviceTypes, parsed.DeviceType)
+		bots = append(bots, parsed.IsBot)
+		botNames = append(botNames, parsed.BotName)
+	}
+
+	// Ordered to take row locks in the same order as concurrent workers, and
+	// only updating user agents stored before they were parsed
+	query := `INSERT INTO user_agents (user_agent, browser, browser_version, os, os_version, device_type, is_bot, bot_name, parsed)
+		SELECT u, NULLIF(b, ''), NULLIF(bv, ''), NULLIF(o, ''), NULLIF(ov, ''), NULLIF(d, ''), bot, NULLIF(bn, ''), true
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::boolean[], $8::text[]) AS t(u, b, bv, o, ov, d, bot, bn)
+		ORDER BY u
+		ON CONFLICT (user_agent) DO UPDATE SET
+		browser = EXCLUDED.browser,
+		browser_version = EXCLUDED.browser_version,
+		os = EXCLUDED.os,
+		os_version = EXCLUDED.os_version,
+		device_type = EXCLUDED.device_type,
+		is_bot = EXCLUDED.is_bot,
+		bot_name = EXCLUDED.bot_name,
+		parsed = true
+		WHERE NOT user_agents.parsed;`
+
+	_, err := db.Exec(ctx, query, raw, browsers, browserVersions, oses, osVersions, deviceTypes, bots, botNames)
+	return err
+}
+
+// Returns the IDs of stored user agents, and those that are missing or have
+// not been parsed yet
+func getIDs(ctx context.Context, db database.Querier, userAgents map[string]struct{}) (map[string]int, map[string]struct{}, error) {
+	ids := make(map[string]int)
+	unparsed := make(map[string]struct{})
+	if len(userAgents) == 0 {
+		return ids, unparsed, nil
+	}
+
+	arguments := make([]string, 0, len(userAgents))
+	for userAgent := range userAgents {
+		arguments = append(arguments, userAgent)
+	}
+
+	query := "SELECT user_agent, id, parsed FROM user_agents WHERE user_agent = ANY($1);"
+	rows, err := db.Query(ctx, query, arguments)
+	if err != nil {
+		return ids, unparsed, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userAgent string
+		var id int
+		var parsed bool
+		err := rows.Scan(&userAgent, &id, &parsed)
+		if err != nil {
+			return ids, unparsed, err
+		}
+		ids[userAgent] = id
+		if !parsed {
+			unparsed[userAgent] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ids, unparsed, err
+	}
+
+	for userAgent := range userAgents {
+		if _, ok := ids[userAgent]; !ok {
+			unparsed[userAgent] = struct{}{}
+		}
+	}
+	return ids, unparsed, nil
+}
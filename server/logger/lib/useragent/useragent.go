@@ -0,0 +1,195 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Structured fields parsed from a raw user agent string
+type UserAgent struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"`
+	OS             string `json:"os"`
+	OSVersion      string `json:"os_version"`
+	DeviceType     string `json:"device_type"`
+	IsBot          bool   `json:"is_bot"`
+	BotName        string `json:"bot_name"`
+}
+
+// Device types
+const (
+	Desktop = "desktop"
+	Mobile  = "mobile"
+	Tablet  = "tablet"
+	TV      = "tv"
+	Bot     = "bot"
+	Other   = "other"
+)
+
+// Lengths of the columns parsed fields are stored in, which longer values
+// are truncated to
+const (
+	maxNameLength    = 64
+	maxVersionLength = 32
+)
+
+// A named pattern, with an optional first capture group holding the version
+type candidate struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+func newCandidate(name string, pattern string) candidate {
+	return candidate{name, regexp.MustCompile(pattern)}
+}
+
+// Ordered most specific first, as many browsers also claim to be Chrome,
+// Safari or Mozilla
+var browserCandidates = []candidate{
+	newCandidate("Edge", `(?:Edg|Edge|EdgA|EdgiOS)/([\d.]+)`),
+	newCandidate("Opera", `(?:OPR|Opera)/([\d.]+)`),
+	newCandidate("Samsung Internet", `SamsungBrowser/([\d.]+)`),
+	newCandidate("Yandex Browser", `YaBrowser/([\d.]+)`),
+	newCandidate("UC Browser", `UCBrowser/([\d.]+)`),
+	newCandidate("Vivaldi", `Vivaldi/([\d.]+)`),
+	newCandidate("Seamonkey", `Seamonkey/([\d.]+)`),
+	newCandidate("Firefox", `(?:Firefox|FxiOS)/([\d.]+)`),
+	newCandidate("Chromium", `Chromium/([\d.]+)`),
+	newCandidate("Chrome", `(?:CriOS|Chrome)/([\d.]+)`),
+	newCandidate("Safari", `Version/([\d.]+).*Safari/`),
+	newCandidate("Internet Explorer", `(?:MSIE |Trident/.*rv:)([\d.]+)`),
+	newCandidate("Curl", `curl/([\d.]+)`),
+	newCandidate("Wget", `Wget/([\d.]+)`),
+	newCandidate("Postman", `PostmanRuntime/([\d.]+)`),
+	newCandidate("Insomnia", `insomnia/([\d.]+)`),
+	newCandidate("Python requests", `python-requests/([\d.]+)`),
+	newCandidate("HTTPX", `python-httpx/([\d.]+)`),
+	newCandidate("aiohttp", `aiohttp/([\d.]+)`),
+	newCandidate("Python urllib", `Python-urllib/([\d.]+)`),
+	newCandidate("Nodejs fetch", `node-fetch/?([\d.]*)`),
+	newCandidate("axios", `axios/([\d.]+)`),
+	newCandidate("undici", `undici/?([\d.]*)`),
+	newCandidate("Go http", `[Gg]o-http-client/([\d.]+)`),
+	newCandidate("OkHttp", `(?i)okhttp/([\d.]+)`),
+	newCandidate("Java", `Java/([\d._]+)`),
+	newCandidate("Dart", `Dart/([\d.]+)`),
+	newCandidate("Guzzle", `GuzzleHttp/([\d.]+)`),
+	newCandidate("Ruby", `Ruby/?([\d.]*)`),
+}
+
+var osCandidates = []candidate{
+	newCandidate("Windows Phone", `Windows Phone(?: OS)? ([\d.]+)`),
+	newCandidate("Windows", `Windows NT ([\d.]+)`),
+	newCandidate("Windows", `Windows`),
+	newCandidate("iOS", `(?:iPhone|CPU) OS ([\d_]+)`),
+	newCandidate("iOS", `iPhone|iPad|iPod`),
+	newCandidate("Mac OS X", `Mac OS X ([\d_.]+)`),
+	newCandidate("Mac OS X", `Macintosh`),
+	newCandidate("Android", `Android ([\d.]+)`),
+	newCandidate("Android", `Android`),
+	newCandidate("Chrome OS", `CrOS`),
+	newCandidate("Tizen", `Tizen/?([\d.]*)`),
+	newCandidate("FreeBSD", `FreeBSD`),
+	newCandidate("OpenBSD", `OpenBSD`),
+	newCandidate("SunOS", `SunOS`),
+	newCandidate("Linux", `Linux|X11`),
+}
+
+// Marketing names for Windows NT kernel versions
+var windowsVersions = map[string]string{
+	"5.0":  "2000",
+	"5.1":  "XP",
+	"5.2":  "Server 2003",
+	"6.0":  "Vista",
+	"6.1":  "7",
+	"6.2":  "8",
+	"6.3":  "8.1",
+	"10.0": "10/11",
+}
+
+var (
+	tvRegex     = regexp.MustCompile(`(?i)SmartTV|SMART-TV|GoogleTV|AppleTV|HbbTV|BRAVIA|Web0S|NetCast|Roku|CrKey`)
+	tabletRegex = regexp.MustCompile(`iPad|Tablet|Kindle|Silk/|PlayBook|Nexus (?:7|9|10)`)
+	mobileRegex = regexp.MustCompile(`Mobi|iPhone|iPod|Windows Phone|BlackBerry|Opera Mini`)
+	androidRe   = regexp.MustCompile(`Android`)
+	desktopRe   = regexp.MustCompile(`Windows NT|Macintosh|X11|CrOS`)
+)
+
+func match(candidates []candidate, userAgent string) (string, string) {
+	for _, c := range candidates {
+		matches := c.regex.FindStringSubmatch(userAgent)
+		if matches == nil {
+			continue
+		}
+		var version string
+		if len(matches) > 1 {
+			version = matches[1]
+		}
+		return c.name, version
+	}
+	return "", ""
+}
+
+func deviceType(userAgent string) string {
+	switch {
+	case tvRegex.MatchString(userAgent):
+		return TV
+	case tabletRegex.MatchString(userAgent):
+		return Tablet
+	case mobileRegex.MatchString(userAgent):
+		return Mobile
+	case androidRe.MatchString(userAgent):
+		// Android devices without "Mobile" are tablets
+		return Tablet
+	case desktopRe.MatchString(userAgent):
+		return Desktop
+	default:
+		return Other
+	}
+}
+
+// Parse extracts the browser, operating system and device type from a user
+// agent. Fields are left blank where not recognised. Bots are identified
+// separately against the bot signature list.
+func Parse(userAgent string) UserAgent {
+	var ua UserAgent
+	if userAgent == "" {
+		return ua
+	}
+
+	ua.Browser, ua.BrowserVersion = match(browserCandidates, userAgent)
+
+	ua.OS, ua.OSVersion = match(osCandidates, userAgent)
+	switch ua.OS {
+	case "Windows":
+		if name, ok := windowsVersions[ua.OSVersion]; ok {
+			ua.OSVersion = name
+		}
+	case "iOS", "Mac OS X":
+		ua.OSVersion = strings.ReplaceAll(ua.OSVersion, "_", ".")
+	}
+
+	ua.DeviceType = deviceType(userAgent)
+
+	ua.Browser = truncate(ua.Browser, maxNameLength)
+	ua.BrowserVersion = truncate(ua.BrowserVersion, maxVersionLength)
+	ua.OS = truncate(ua.OS, maxNameLength)
+	ua.OSVersion = truncate(ua.OSVersion, maxVersionLength)
+
+	return ua
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
+
+// SetBot marks the user agent as belonging to the named bot.
+func (ua *UserAgent) SetBot(name string) {
+	ua.IsBot = true
+	ua.BotName = truncate(name, maxNameLength)
+	ua.DeviceType = Bot
+}
@@ -0,0 +1,74 @@
+package useragent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	expecteds := []struct {
+		userAgent string
+		expected  UserAgent
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{Browser: "Chrome", BrowserVersion: "120.0.0.0", OS: "Windows", OSVersion: "10/11", DeviceType: Desktop},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			UserAgent{Browser: "Edge", BrowserVersion: "120.0.2210.91", OS: "Windows", OSVersion: "10/11", DeviceType: Desktop},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			UserAgent{Browser: "Safari", BrowserVersion: "17.2", OS: "Mac OS X", OSVersion: "10.15.7", DeviceType: Desktop},
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			UserAgent{Browser: "Firefox", BrowserVersion: "121.0", OS: "Linux", DeviceType: Desktop},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			UserAgent{Browser: "Safari", BrowserVersion: "17.1.2", OS: "iOS", OSVersion: "17.1.2", DeviceType: Mobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			UserAgent{Browser: "Samsung Internet", BrowserVersion: "23.0", OS: "Android", OSVersion: "14", DeviceType: Mobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{Browser: "Chrome", BrowserVersion: "120.0.0.0", OS: "Android", OSVersion: "13", DeviceType: Tablet},
+		},
+		{
+			"curl/8.4.0",
+			UserAgent{Browser: "Curl", BrowserVersion: "8.4.0", DeviceType: Other},
+		},
+		{
+			"python-requests/2.31.0",
+			UserAgent{Browser: "Python requests", BrowserVersion: "2.31.0", DeviceType: Other},
+		},
+		{
+			"",
+			UserAgent{},
+		},
+	}
+
+	for _, expected := range expecteds {
+		ua := Parse(expected.userAgent)
+		if ua != expected.expected {
+			t.Errorf("%q: got %+v, expected %+v", expected.userAgent, ua, expected.expected)
+		}
+	}
+}
+
+func TestParseTruncatesLongFields(t *testing.T) {
+	version := strings.Repeat("1", 40)
+	ua := Parse("Mozilla/5.0 (Windows NT " + version + ") Chrome/" + version)
+	if len(ua.BrowserVersion) != maxVersionLength || len(ua.OSVersion) != maxVersionLength {
+		t.Errorf("got versions %q and %q, expected %d characters", ua.BrowserVersion, ua.OSVersion, maxVersionLength)
+	}
+
+	ua.SetBot(strings.Repeat("é", 100))
+	if n := len([]rune(ua.BotName)); n != maxNameLength {
+		t.Errorf("got bot name of %d characters, expected %d", n, maxNameLength)
+	}
+}
@@ -1,52 +1,177 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/tom-draper/api-analytics/server/config"
 	"github.com/tom-draper/api-analytics/server/database"
-	"github.com/tom-draper/api-analytics/server/logger/lib/log"
+	"github.com/tom-draper/api-analytics/server/logger/lib/bots"
+	"github.com/tom-draper/api-analytics/server/logger/lib/credentials"
+	"github.com/tom-draper/api-analytics/server/logger/lib/dedupe"
+	"github.com/tom-draper/api-analytics/server/logger/lib/geoip"
+	"github.com/tom-draper/api-analytics/server/logger/lib/metrics"
+	"github.com/tom-draper/api-analytics/server/logger/lib/queue"
+	"github.com/tom-draper/api-analytics/server/logger/lib/quota"
 	"github.com/tom-draper/api-analytics/server/logger/lib/ratelimit"
+	"github.com/tom-draper/api-analytics/server/logging"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	"github.com/oschwald/geoip2-golang"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.LogToFile(fmt.Sprintf("Application crashed: %v", err))
+			slog.Error("Application crashed", slog.Any("error", err))
 		}
 	}()
 
-	log.LogToFile("Starting logger...")
+	cfg := defaultConfig()
+	err := config.Load(&cfg, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		os.Exit(2)
+	}
+
+	// JSON logs, rotated once they reach log.max_size megabytes
+	logger, logFile, err := logging.New(cfg.Log)
+	if err != nil {
+		slog.Error("Failed to create logger", logging.Err(err))
+		return
+	}
+	defer logFile.Close()
+	slog.SetDefault(logger)
+
+	slog.Info("Starting logger...")
+
+	database.Configure(cfg.Postgres)
+
+	// Connection pool shared by all handlers
+	pool, err := database.NewPool(context.Background())
+	if err != nil {
+		slog.Error("Failed to create database connection pool", logging.Err(err))
+		return
+	}
+	defer pool.Close()
 
-	err := database.LoadConfig()
+	// Refuse to start against a schema this build was not written for
+	err = database.CheckSchemaVersion(context.Background(), pool)
 	if err != nil {
-		log.LogToFile("Failed to load database configuration: " + err.Error())
+		slog.Error("Database schema check failed", logging.Err(err))
 		return
 	}
 
+	// Requests without a partition for their month are held in the default
+	// partition, so a failure here is not fatal
+	err = maintainPartitions(context.Background(), pool, cfg.Partitions)
+	if err != nil {
+		slog.Error("Failed to maintain partitions", logging.Err(err))
+	}
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+	defer stopMaintenance()
+	go watchPartitions(maintenanceCtx, pool, cfg.Partitions)
+
+	// Dashboard rollups rebuilt from the requests stored each minute
+	go watchRollups(maintenanceCtx, pool)
+
+	// GeoIP databases held open for the lifetime of the service and reloaded
+	// when replaced on disk
+	locator := geoip.NewLocator()
+	locator.Watch(time.Minute)
+	defer locator.Close()
+
+	// Bot signatures reloaded when the signature file is updated on disk
+	detector, err := bots.NewDetector()
+	if err != nil {
+		slog.Error("Failed to load bot signatures", logging.Err(err))
+		return
+	}
+	detector.Watch(time.Minute, func(err error) {
+		slog.Error("Failed to reload bot signatures", logging.Err(err))
+	})
+	defer detector.Close()
+
+	// Accepted batches are stored in the background by a pool of workers
+	ingestQueue, err := newIngestQueue(cfg.Queue, cfg.LiveTail, pool, locator, detector)
+	if err != nil {
+		slog.Error("Failed to create ingestion queue", logging.Err(err))
+		return
+	}
+	metrics.RegisterQueue(ingestQueue.Len, ingestQueue.Cap())
+
 	gin.SetMode(gin.ReleaseMode)
 	app := gin.New()
 
+	app.Use(logging.Middleware(logger))
+	app.Use(metrics.Middleware())
 	app.Use(cors.Default())
 
-	handler := logRequestHandler()
+	// Per-account limits on logged requests, defaulting to max_insert per minute
+	// if quotas cannot be read
+	defaultQuota := database.DefaultQuota
+	defaultQuota.RowsPerMinute = cfg.MaxInsert
+	quotaLimiter := quota.NewLimiter(pool, defaultQuota)
+
+	// Batch IDs recently accepted, so client retries are skipped before
+	// reaching the queue
+	batchIDs := dedupe.NewWindow(cfg.BatchIDWindow, 100_000)
+
+	accountCredentials := credentials.NewResolver(pool)
+
+	handler := logRequestHandler(ingestQueue, quotaLimiter, detector, batchIDs, accountCredentials)
 	app.POST("/api/log-request", handler)
 	app.POST("/api/requests", handler)
-	app.GET("/api/health", checkHealth)
+	app.POST("/v1/traces", otlpTracesHandler(ingestQueue, quotaLimiter, detector, batchIDs, accountCredentials))
+	app.GET("/api/health", checkHealthHandler(pool, ingestQueue))
+
+	// Served on their own port so they are not exposed with the logger
+	if cfg.MetricsPort > 0 {
+		go func() {
+			if err := metrics.Serve(cfg.MetricsPort); err != nil {
+				slog.Error("Failed to serve metrics", logging.Err(err))
+			}
+		}()
+	}
 
-	if err := app.Run(":8000"); err != nil {
-		log.LogToFile(fmt.Sprintf("Failed to run server: %v", err))
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: app,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Failed to run server", logging.Err(err))
+		}
+	}()
+
+	// Finish storing queued requests before exiting
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down logger...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Failed to shut down server", logging.Err(err))
+	}
+	if err := ingestQueue.Close(); err != nil {
+		slog.Error("Failed to close ingestion queue", logging.Err(err))
 	}
 }
 
@@ -60,6 +185,7 @@ type RequestData struct {
 	ResponseTime int16  `json:"response_time"`
 	UserID       string `json:"user_id"`
 	CreatedAt    string `json:"created_at"`
+	RequestID    string `json:"request_id"` // Optional client-generated ID, duplicates are stored once
 }
 
 type Payload struct {
@@ -67,6 +193,7 @@ type Payload struct {
 	Requests     []RequestData `json:"requests"`
 	Framework    string        `json:"framework"`
 	PrivacyLevel PrivacyLevel  `json:"privacy_level"`
+	BatchID      string        `json:"batch_id"` // Optional client-generated ID, retried batches are skipped
 }
 
 type PrivacyLevel int
@@ -77,389 +204,244 @@ const (
 	P3                     // Client IP address never be sent to server, optional custom user ID field is the only user identification
 )
 
-func checkHealth(c *gin.Context) {
-	connection, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("Health check failed: %v", err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "unhealthy",
-			"error":  "Database connection failed",
-		})
-		return
-	}
+func checkHealthHandler(pool *pgxpool.Pool, ingestQueue *queue.Queue[Batch]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := pool.Ping(c.Request.Context())
+		if err != nil {
+			logging.FromContext(c).Error("Health check failed", logging.Err(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status": "unhealthy",
+				"error":  "Database connection failed",
+			})
+			return
+		}
 
-	err = connection.Ping(context.Background())
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("Health check failed: %v", err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "unhealthy",
-			"error":  "Database connection failed",
+		stats := pool.Stat()
+		c.JSON(http.StatusOK, gin.H{
+			"status": "healthy",
+			"pool": gin.H{
+				"max_conns":              stats.MaxConns(),
+				"total_conns":            stats.TotalConns(),
+				"acquired_conns":         stats.AcquiredConns(),
+				"idle_conns":             stats.IdleConns(),
+				"constructing_conns":     stats.ConstructingConns(),
+				"acquire_count":          stats.AcquireCount(),
+				"empty_acquire_count":    stats.EmptyAcquireCount(),
+				"canceled_acquire_count": stats.CanceledAcquireCount(),
+				"acquire_duration_ms":    stats.AcquireDuration().Milliseconds(),
+			},
+			"queue": gin.H{
+				"length":   ingestQueue.Len(),
+				"capacity": ingestQueue.Cap(),
+			},
 		})
-		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
-	})
 }
 
-func getMaxInsert() int {
-	const defaultValue int = 2000
-
-	errMsg := fmt.Sprintf("Failed to load .env file. Using default value MAX_INSERT=%d.", defaultValue)
-
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.LogToFile(errMsg)
-		return defaultValue
-	}
-
-	value := os.Getenv(fmt.Sprintf("MAX_INSERT environment variable is blank. Using default value MAX_INSERT=%d.", defaultValue))
-	if value == "" {
-		log.LogToFile(errMsg)
-		return defaultValue
-	}
-
-	maxInsert, err := strconv.Atoi(value)
-	if err != nil {
-		log.LogToFile(fmt.Sprintf("MAX_INSERT environment variable is not an integer. Using default value MAX_INSERT=%d.", defaultValue))
-		return defaultValue
-	}
-
-	return maxInsert
-}
-
-func getCountryCode(IPAddress string) string {
-	if IPAddress == "" {
-		return ""
-	}
-	db, err := geoip2.Open("GeoLite2-Country.mmdb")
-	if err != nil {
-		return ""
-	}
-	defer db.Close()
-
-	ip := net.ParseIP(IPAddress)
-	if ip == nil {
-		return ""
-	}
-	record, err := db.Country(ip)
-	if err != nil {
-		return ""
-	}
-	location := record.Country.IsoCode
-	return location
-}
-
-func storeNewUserAgents(userAgents map[string]struct{}) error {
-	var query strings.Builder
-	query.WriteString("INSERT INTO user_agents (user_agent) VALUES ")
-	arguments := make([]any, len(userAgents))
-	i := 0
-
-	for userAgent := range userAgents {
-		query.WriteString(fmt.Sprintf("($%d)", i+1))
-		if i < len(userAgents)-1 {
-			query.WriteString(",")
+// Explains why every request in a batch was rejected, naming the most common
+// reason
+func invalidRequestsMessage(rejected map[string]int) string {
+	reason, count := "", 0
+	for r, n := range rejected {
+		if n > count || (n == count && r < reason) {
+			reason, count = r, n
 		}
-		arguments[i] = userAgent
-		i++
-	}
-
-	query.WriteString(" ON CONFLICT (user_agent) DO NOTHING;")
-
-	conn, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(err.Error())
-		return err
 	}
-	_, err = conn.Exec(context.Background(), query.String(), arguments...)
-	conn.Close(context.Background())
-	if err != nil {
-		log.LogToFile(err.Error())
-		return err
+	if reason == "" {
+		return "Invalid request data."
 	}
-
-	return nil
+	return fmt.Sprintf("Invalid request data, %s.", reason)
 }
 
-func getUserAgentIDs(userAgents map[string]struct{}) (map[string]int, error) {
-	if len(userAgents) == 0 {
-		return make(map[string]int), nil
-	}
-
-	var query strings.Builder
-	query.WriteString("SELECT user_agent, id FROM user_agents WHERE user_agent IN (")
-	arguments := make([]any, len(userAgents))
-	i := 0
-
-	for userAgent := range userAgents {
-		query.WriteString(fmt.Sprintf("$%d", i+1))
-		if i < len(userAgents)-1 {
-			query.WriteString(",")
-		}
-		arguments[i] = userAgent
-		i++
-	}
-	query.WriteString(");")
-
-	ids := make(map[string]int)
-	conn, err := database.NewConnection()
-	if err != nil {
-		log.LogToFile(err.Error())
-		return ids, err
-	}
-	rows, err := conn.Query(context.Background(), query.String(), arguments...)
-	conn.Close(context.Background())
-	if err != nil {
-		log.LogToFile(err.Error())
-		return ids, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var userAgent string
-		var id int
-		err := rows.Scan(&userAgent, &id)
-		if err != nil {
-			log.LogToFile(err.Error())
-			continue
-		}
-		ids[userAgent] = id
+// Resolves the API key or token sent with logged requests to the ID of its
+// account, returning the status and message to respond with if it cannot be
+// used to log requests
+func resolveAPIKey(c *gin.Context, resolver *credentials.Resolver, apiKey string) (string, int, string) {
+	resolved, err := resolver.Resolve(c.Request.Context(), apiKey)
+	if errors.Is(err, database.ErrUnauthenticated) {
+		logging.FromContext(c).Warn("Invalid API key", logging.APIKey(apiKey))
+		return "", http.StatusUnauthorized, "Invalid, expired or revoked API key."
+	} else if errors.Is(err, credentials.ErrScope) {
+		logging.FromContext(c).Warn("API token scope denied", logging.APIKey(apiKey))
+		return "", http.StatusForbidden, "API token scope does not allow logging requests."
+	} else if err != nil {
+		logging.FromContext(c).Error("Failed to read API key", logging.APIKey(apiKey), logging.Err(err))
+		metrics.DBErrors.WithLabelValues("credentials").Inc()
+		return "", http.StatusInternalServerError, "Failed to read API key."
 	}
-
-	if err := rows.Err(); err != nil {
-		log.LogToFile(err.Error())
-		return ids, err // Return error if rows iteration failed
-	}
-
-	return ids, nil
+	return resolved, http.StatusOK, ""
 }
 
-func logRequestHandler() gin.HandlerFunc {
+func logRequestHandler(ingestQueue *queue.Queue[Batch], quotaLimiter *quota.Limiter, detector *bots.Detector, batchIDs *dedupe.Window, accountCredentials *credentials.Resolver) gin.HandlerFunc {
 	var rateLimiter = ratelimit.RateLimiter{}
 
-	var maxInsert = getMaxInsert()
-
-	var methodID = map[string]int16{
-		"GET":     0,
-		"POST":    1,
-		"PUT":     2,
-		"PATCH":   3,
-		"DELETE":  4,
-		"OPTIONS": 5,
-		"CONNECT": 6,
-		"HEAD":    7,
-		"TRACE":   8,
-	}
-
-	var frameworkID = map[string]int16{
-		"FastAPI":      0,
-		"Flask":        1,
-		"Gin":          2,
-		"Echo":         3,
-		"Express":      4,
-		"Fastify":      5,
-		"Koa":          6,
-		"Chi":          7,
-		"Fiber":        8,
-		"Actix":        9,
-		"Axum":         10,
-		"Tornado":      11,
-		"Django":       12,
-		"Rails":        13,
-		"Laravel":      14,
-		"Sinatra":      15,
-		"Rocket":       16,
-		"ASP.NET Core": 17,
-	}
-
 	return func(c *gin.Context) {
 		var payload Payload
-		err := c.BindJSON(&payload)
+		// Keeps the body to log if it cannot be parsed
+		err := c.ShouldBindBodyWith(&payload, binding.JSON)
 		if err != nil {
-			msg := fmt.Sprintf("Invalid request data.\n%s\nRequest body: %s", err.Error(), "body")
-			log.LogErrorToFile(c.ClientIP(), "", msg)
+			msg := fmt.Sprintf("Invalid request data.\n%s", err.Error())
+			logging.FromContext(c).Warn("Invalid request data", logging.Err(err), slog.String("body", requestBody(c)))
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": msg})
 			return
 		}
-		
+
 		if payload.APIKey == "" {
 			msg := "API key requied."
-			log.LogErrorToFile(c.ClientIP(), payload.APIKey, msg)
+			logging.FromContext(c).Warn(msg)
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": msg})
 			return
 		}
-		
+		apiKey, status, msg := resolveAPIKey(c, accountCredentials, strings.ReplaceAll(payload.APIKey, "\"", ""))
+		if status != http.StatusOK {
+			c.JSON(status, gin.H{"status": status, "message": msg})
+			return
+		}
+		payload.APIKey = apiKey
+		logging.SetAPIKey(c, payload.APIKey)
+		metrics.Payloads.WithLabelValues("middleware").Inc()
+
 		if rateLimiter.RateLimited(payload.APIKey) {
+			metrics.RateLimited.Inc()
 			msg := "Too many requests."
-			log.LogErrorToFile(c.ClientIP(), payload.APIKey, msg)
+			logging.FromContext(c).Warn(msg)
 			c.JSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests, "message": msg})
 			return
 		}
-		
+
 		if len(payload.Requests) == 0 {
 			msg := "Payload contains no logged requests."
-			log.LogErrorToFile(c.ClientIP(), payload.APIKey, msg)
+			logging.FromContext(c).Warn(msg)
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": msg})
 			return
 		}
 
-		framework, ok := frameworkID[payload.Framework]
+		framework, ok := database.FrameworkID(payload.Framework)
 		if !ok {
 			msg := "Unsupported API framework."
-			log.LogErrorToFile(c.ClientIP(), payload.APIKey, msg)
+			logging.FromContext(c).Warn(msg)
 			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": msg})
 			return
 		}
 
-		payload.APIKey = strings.ReplaceAll(payload.APIKey, "\"", "")
-
-		var query strings.Builder
-		query.WriteString("INSERT INTO requests (api_key, path, hostname, ip_address, status, response_time, method, framework, location, user_id, created_at, user_agent_id) VALUES ")
-		arguments := make([]any, 0)
-		inserted := 0
-		userAgents := make([]string, 0)
-		uniqueUserAgents := map[string]struct{}{}
-		for _, request := range payload.Requests {
-			// Temporary request per minute limit
-			if inserted >= maxInsert {
-				break
-			}
-
-			var location string
-			if payload.PrivacyLevel < P3 {
-				// Location inferred from IP and stored for privacy level P1 and P2
-				location = getCountryCode(request.IPAddress)
-			}
-
-			if payload.PrivacyLevel > P1 {
-				// Client IP address discarded for privacy level P2 and P3
-				request.IPAddress = ""
-			}
-			var ipAddress any
-			if request.IPAddress == "" {
-				ipAddress = nil
-			} else {
-				ipAddress = request.IPAddress
-			}
+		if len(payload.BatchID) > maxIDLength {
+			msg := "Batch ID too long."
+			logging.FromContext(c).Warn(msg)
+			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": msg})
+			return
+		}
 
-			method, ok := methodID[request.Method]
-			if !ok {
-				continue
-			}
+		batch := Batch{
+			APIKey:       payload.APIKey,
+			Framework:    framework,
+			PrivacyLevel: payload.PrivacyLevel,
+			Requests:     payload.Requests,
+			Received:     len(payload.Requests),
+			BatchID:      payload.BatchID,
+		}
 
-			if len(request.UserAgent) > 255 {
-				request.UserAgent = request.UserAgent[:255]
-			}
-			if !database.ValidUserAgent(request.UserAgent) {
-				continue
-			}
+		status, msg = acceptRequests(c, ingestQueue, quotaLimiter, detector, batchIDs, batch)
+		c.JSON(status, gin.H{"status": status, "message": msg})
+	}
+}
 
-			if len(request.UserID) > 255 {
-				request.UserID = request.UserID[:255]
-			}
-			if !database.ValidUserID(request.UserID) {
-				continue
-			}
+// Validates logged requests and queues those within the account's quota to be
+// stored in the background. Returns the response status and message.
+func acceptRequests(c *gin.Context, ingestQueue *queue.Queue[Batch], quotaLimiter *quota.Limiter, detector *bots.Detector, batchIDs *dedupe.Window, batch Batch) (int, string) {
+	// A retried batch was accepted the first time, respond as before without
+	// counting it again
+	if batch.BatchID != "" && batchIDs.Seen(batch.APIKey, batch.BatchID) {
+		logging.FromContext(c).Info("Duplicate batch skipped", slog.String("batch_id", batch.BatchID))
+		metrics.RowsRejected.WithLabelValues(metrics.ReasonDuplicate).Add(float64(len(batch.Requests)))
+		return http.StatusAccepted, "API requests accepted."
+	}
 
-			if len(request.Hostname) > 255 {
-				request.Hostname = request.Hostname[:255]
-			}
-			if !database.ValidHostname(request.Hostname) {
-				continue
-			}
+	requests, rejected := validateRequests(batch.Requests)
+	if len(rejected) > 0 {
+		metrics.RowsRejected.WithLabelValues(metrics.ReasonInvalid).Add(float64(len(batch.Requests) - len(requests)))
+		logging.FromContext(c).Warn("Invalid requests dropped", slog.Any("reasons", rejected))
+	}
 
-			if len(request.Path) > 255 {
-				request.Path = request.Path[:255]
-			}
-			if !database.ValidPath(request.Path) {
-				continue
-			}
+	// If no valid logged requests received
+	if len(requests) == 0 {
+		logging.FromContext(c).Warn("No valid requests received", slog.Int("received", batch.Received))
+		return http.StatusBadRequest, invalidRequestsMessage(rejected)
+	}
 
-			// If not at final row in query, separate with comma
-			if inserted > 0 && inserted < maxInsert && inserted < len(payload.Requests) {
-				query.WriteString(",")
-			}
+	accountQuota, err := quotaLimiter.Quota(c.Request.Context(), batch.APIKey)
+	if err != nil {
+		logging.FromContext(c).Error("Failed to read quota", logging.Err(err))
+		metrics.DBErrors.WithLabelValues("quota").Inc()
+	}
 
-			// Register user agent to be stored in the database
-			if _, ok := uniqueUserAgents[request.UserAgent]; !ok {
-				uniqueUserAgents[request.UserAgent] = struct{}{}
+	// Discard requests from bots before they count towards the quota
+	if accountQuota.DropBots {
+		humans := dropBots(requests, detector)
+		metrics.RowsRejected.WithLabelValues(metrics.ReasonBot).Add(float64(len(requests) - len(humans)))
+		requests = humans
+		if len(requests) == 0 {
+			if batch.BatchID != "" {
+				batchIDs.Add(batch.APIKey, batch.BatchID)
 			}
-			// Temp store for user agents in each row for conversion to user agent IDs
-			userAgents = append(userAgents, request.UserAgent)
-
-			numArgs := len(arguments)
-			query.WriteString(
-				fmt.Sprintf("($%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d)",
-					numArgs+1,
-					numArgs+2,
-					numArgs+3,
-					numArgs+4,
-					numArgs+5,
-					numArgs+6,
-					numArgs+7,
-					numArgs+8,
-					numArgs+9,
-					numArgs+10,
-					numArgs+11,
-					numArgs+12),
-			)
-			arguments = append(
-				arguments,
-				payload.APIKey,
-				request.Path,
-				request.Hostname,
-				ipAddress,
-				request.Status,
-				request.ResponseTime,
-				method,
-				framework,
-				location,
-				request.UserID,
-				request.CreatedAt,
-				0)
-			inserted += 1
+			return http.StatusAccepted, "API requests accepted."
 		}
+	}
 
-		// If no valid logged requests received
-		if inserted == 0 {
-			log.LogToFile("No rows inserted.")
-			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid request data."})
-			return
-		}
+	// Accept as many requests as remain within the account's quota
+	allowed, err := quotaLimiter.Allow(c.Request.Context(), batch.APIKey, len(requests))
+	if err != nil {
+		logging.FromContext(c).Error("Failed to read quota", logging.Err(err))
+		metrics.DBErrors.WithLabelValues("quota").Inc()
+	}
+	metrics.RowsRejected.WithLabelValues(metrics.ReasonQuota).Add(float64(len(requests) - allowed))
+	if allowed == 0 {
+		msg := "Quota exceeded."
+		logging.FromContext(c).Warn(msg)
+		return http.StatusTooManyRequests, msg
+	}
+	batch.Requests = requests[:allowed]
 
-		query.WriteString(";")
+	err = ingestQueue.Enqueue(batch)
+	if err != nil {
+		// Rows that were never queued don't count towards the quota
+		quotaLimiter.Refund(batch.APIKey, allowed)
+	}
+	if err == queue.ErrFull || err == queue.ErrClosed {
+		msg := "Server busy, try again later."
+		logging.FromContext(c).Warn(msg)
+		metrics.RowsRejected.WithLabelValues(metrics.ReasonQueueFull).Add(float64(allowed))
+		c.Header("Retry-After", "5")
+		return http.StatusServiceUnavailable, msg
+	} else if err != nil {
+		logging.FromContext(c).Error("Failed to queue requests", logging.Err(err))
+		metrics.RowsRejected.WithLabelValues(metrics.ReasonError).Add(float64(allowed))
+		return http.StatusInternalServerError, "Failed to queue requests."
+	}
 
-		// Store any new user agents found
-		_ = storeNewUserAgents(uniqueUserAgents)
-		// Get associated user IDs for user agents
-		userAgentIDs, _ := getUserAgentIDs(uniqueUserAgents)
-		// Insert user agent IDs into arguments
-		for i, userAgent := range userAgents {
-			if id, ok := userAgentIDs[userAgent]; ok {
-				arguments[(i*12)+11] = id
-			}
-		}
+	if batch.BatchID != "" {
+		batchIDs.Add(batch.APIKey, batch.BatchID)
+	}
+	metrics.RowsAccepted.Add(float64(allowed))
 
-		// Insert logged requests into database
-		conn, err := database.NewConnection()
-		if err != nil {
-			log.LogToFile(err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": "Database connection failed."})
-			return
-		}
-		_, err = conn.Exec(context.Background(), query.String(), arguments...)
-		if err != nil {
-			log.LogToFile(err.Error())
-			c.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "message": "Invalid data."})
-			return
-		}
+	// Requests are stored in the background
+	return http.StatusAccepted, "API requests accepted."
+}
+
+// Maximum length of a request body written to the logs
+const maxLoggedBody int = 1024
 
-		// Return success response
-		c.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "API requests logged successfully."})
+var apiKeyPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|aat_[A-Za-z0-9_-]+`)
 
-		// Record in log file for debugging
-		log.LogRequestsToFile(payload.APIKey, inserted, len(payload.Requests))
+// Returns the start of the request body read by ShouldBindBodyWith, with any
+// API keys masked
+func requestBody(c *gin.Context) string {
+	body, ok := c.Get(gin.BodyBytesKey)
+	if !ok {
+		return ""
+	}
+	b, _ := body.([]byte)
+	if len(b) > maxLoggedBody {
+		b = b[:maxLoggedBody]
 	}
+	return apiKeyPattern.ReplaceAllStringFunc(string(b), logging.MaskAPIKey)
 }
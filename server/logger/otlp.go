@@ -0,0 +1,212 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logger/lib/bots"
+	"github.com/tom-draper/api-analytics/server/logger/lib/credentials"
+	"github.com/tom-draper/api-analytics/server/logger/lib/dedupe"
+	"github.com/tom-draper/api-analytics/server/logger/lib/metrics"
+	"github.com/tom-draper/api-analytics/server/logger/lib/otlp"
+	"github.com/tom-draper/api-analytics/server/logger/lib/queue"
+	"github.com/tom-draper/api-analytics/server/logger/lib/quota"
+	"github.com/tom-draper/api-analytics/server/logger/lib/ratelimit"
+	"github.com/tom-draper/api-analytics/server/logging"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Maximum size of a decompressed OTLP request body
+const maxTracesBodySize int64 = 16 << 20
+
+// Receives OpenTelemetry HTTP server spans over OTLP/HTTP and stores each as
+// a logged request
+func otlpTracesHandler(ingestQueue *queue.Queue[Batch], quotaLimiter *quota.Limiter, detector *bots.Detector, batchIDs *dedupe.Window, accountCredentials *credentials.Resolver) gin.HandlerFunc {
+	var rateLimiter = ratelimit.RateLimiter{}
+
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-AUTH-TOKEN")
+		if apiKey == "" {
+			msg := "API key requied."
+			logging.FromContext(c).Warn(msg)
+			writeOTLPResponse(c, http.StatusUnauthorized, msg)
+			return
+		}
+		apiKey, status, msg := resolveAPIKey(c, accountCredentials, apiKey)
+		if status != http.StatusOK {
+			writeOTLPResponse(c, status, msg)
+			return
+		}
+		logging.SetAPIKey(c, apiKey)
+		metrics.Payloads.WithLabelValues("otlp").Inc()
+
+		if rateLimiter.RateLimited(apiKey) {
+			metrics.RateLimited.Inc()
+			msg := "Too many requests."
+			logging.FromContext(c).Warn(msg)
+			writeOTLPResponse(c, http.StatusTooManyRequests, msg)
+			return
+		}
+
+		privacyLevel := P1
+		if value := c.GetHeader("X-Privacy-Level"); value != "" {
+			level, err := strconv.Atoi(value)
+			if err != nil || level < int(P1) || level > int(P3) {
+				msg := "Invalid privacy level."
+				logging.FromContext(c).Warn(msg)
+				writeOTLPResponse(c, http.StatusBadRequest, msg)
+				return
+			}
+			privacyLevel = PrivacyLevel(level)
+		}
+
+		body, err := readTracesBody(c)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to read request body - %s", err.Error())
+			logging.FromContext(c).Warn("Failed to read request body", logging.Err(err))
+			writeOTLPResponse(c, http.StatusBadRequest, msg)
+			return
+		}
+
+		spans, err := otlp.Decode(body, c.ContentType())
+		if errors.Is(err, otlp.ErrUnsupportedContentType) {
+			msg := "Unsupported content type."
+			logging.FromContext(c).Warn(msg)
+			writeOTLPResponse(c, http.StatusUnsupportedMediaType, msg)
+			return
+		} else if err != nil {
+			msg := fmt.Sprintf("Invalid trace data - %s", err.Error())
+			logging.FromContext(c).Warn("Invalid trace data", logging.Err(err))
+			writeOTLPResponse(c, http.StatusBadRequest, msg)
+			return
+		}
+
+		requests := spansToRequests(spans)
+		if len(requests) == 0 {
+			// Nothing to store, but the export succeeded
+			writeOTLPResponse(c, http.StatusOK, "")
+			return
+		}
+
+		batch := Batch{
+			APIKey:       apiKey,
+			Framework:    database.FrameworkOpenTelemetry,
+			PrivacyLevel: privacyLevel,
+			Requests:     requests,
+			Received:     len(requests),
+		}
+
+		status, msg = acceptRequests(c, ingestQueue, quotaLimiter, detector, batchIDs, batch)
+		if status == http.StatusAccepted {
+			// OTLP exporters expect 200 on success
+			status = http.StatusOK
+			msg = ""
+		}
+		writeOTLPResponse(c, status, msg)
+	}
+}
+
+func readTracesBody(c *gin.Context) ([]byte, error) {
+	var reader io.Reader = c.Request.Body
+	if c.GetHeader("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxTracesBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxTracesBodySize {
+		return nil, errors.New("request body too large")
+	}
+	return body, nil
+}
+
+// Maps HTTP server spans onto logged requests, ignoring all other spans
+func spansToRequests(spans []otlp.Span) []RequestData {
+	requests := make([]RequestData, 0, len(spans))
+	for _, span := range spans {
+		if span.Kind != otlp.SpanKindServer {
+			continue
+		}
+
+		// Current semantic conventions first, then those they replaced
+		method := span.String("http.request.method", "http.method")
+		if method == "" {
+			continue
+		}
+		status, ok := span.Int("http.response.status_code", "http.status_code")
+		if !ok {
+			continue
+		}
+
+		path := span.String("http.route", "url.path")
+		if path == "" {
+			// Older instrumentation records the path with its query string
+			path, _, _ = strings.Cut(span.String("http.target"), "?")
+		}
+		if path == "" {
+			continue
+		}
+
+		var responseTime int64
+		if !span.Start.IsZero() && span.End.After(span.Start) {
+			responseTime = span.End.Sub(span.Start).Milliseconds()
+		}
+		createdAt := span.Start
+		if createdAt.IsZero() {
+			createdAt = time.Now().UTC()
+		}
+
+		requests = append(requests, RequestData{
+			Path:         path,
+			Hostname:     span.String("server.address", "http.host", "net.host.name"),
+			IPAddress:    span.String("client.address", "http.client_ip", "net.sock.peer.addr"),
+			UserAgent:    span.String("user_agent.original", "http.user_agent"),
+			Method:       strings.ToUpper(method),
+			Status:       int16(min(max(status, 0), math.MaxInt16)),
+			ResponseTime: int16(min(responseTime, math.MaxInt16)),
+			UserID:       span.String("enduser.id"),
+			CreatedAt:    createdAt.Format(time.RFC3339Nano),
+			// Exporters retry whole requests, so spans sent twice are only
+			// stored once
+			RequestID: span.TraceID + span.SpanID,
+		})
+	}
+	return requests
+}
+
+// Writes an empty ExportTraceServiceResponse on success, or a Status message
+// describing the failure, in the encoding of the request
+func writeOTLPResponse(c *gin.Context, status int, msg string) {
+	if c.ContentType() == "application/json" {
+		if status == http.StatusOK {
+			c.JSON(status, gin.H{})
+		} else {
+			c.JSON(status, gin.H{"message": msg})
+		}
+		return
+	}
+
+	var body []byte
+	if status != http.StatusOK {
+		// google.rpc.Status message field
+		body = protowire.AppendTag(body, 2, protowire.BytesType)
+		body = protowire.AppendString(body, msg)
+	}
+	c.Data(status, "application/x-protobuf", body)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tom-draper/api-analytics/server/logger/lib/otlp"
+)
+
+func TestSpansToRequests(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	spans := []otlp.Span{
+		{
+			TraceID: "5b8efff798038103d269b633813fc60c",
+			SpanID:  "eee19b7ec3c1b174",
+			Kind:    otlp.SpanKindServer,
+			Start:   start,
+			End:     start.Add(42 * time.Millisecond),
+			Attributes: map[string]any{
+				"http.route":                "/users/{id}",
+				"http.request.method":       "GET",
+				"http.response.status_code": int64(200),
+				"client.address":            "1.1.1.1",
+				"user_agent.original":       "curl/8.4.0",
+				"server.address":            "api.example.com",
+			},
+		},
+		{
+			// Older semantic conventions
+			Kind:  otlp.SpanKindServer,
+			Start: start,
+			End:   start.Add(time.Minute),
+			Attributes: map[string]any{
+				"http.target":      "/search?q=test",
+				"http.method":      "post",
+				"http.status_code": "201",
+			},
+		},
+		{
+			// Outgoing requests are not logged
+			Kind: otlp.SpanKindClient,
+			Attributes: map[string]any{
+				"http.route":                "/",
+				"http.request.method":       "GET",
+				"http.response.status_code": int64(200),
+			},
+		},
+		{
+			// Not an HTTP span
+			Kind:       otlp.SpanKindServer,
+			Attributes: map[string]any{"rpc.method": "Get"},
+		},
+	}
+
+	requests := spansToRequests(spans)
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, expected 2", len(requests))
+	}
+
+	expected := RequestData{
+		Path:         "/users/{id}",
+		Hostname:     "api.example.com",
+		IPAddress:    "1.1.1.1",
+		UserAgent:    "curl/8.4.0",
+		Method:       "GET",
+		Status:       200,
+		ResponseTime: 42,
+		CreatedAt:    "2024-01-01T12:00:00Z",
+		RequestID:    "5b8efff798038103d269b633813fc60ceee19b7ec3c1b174",
+	}
+	if requests[0] != expected {
+		t.Errorf("got %+v, expected %+v", requests[0], expected)
+	}
+
+	if requests[1].Path != "/search" || requests[1].Method != "POST" || requests[1].Status != 201 {
+		t.Errorf("got %+v", requests[1])
+	}
+	// Response times beyond the column range are capped
+	if requests[1].ResponseTime != 32767 {
+		t.Errorf("got response time %d, expected 32767", requests[1].ResponseTime)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// How often partitions are checked once the logger is running
+const partitionInterval = time.Hour
+
+// Oldest time kept by a retention of whole months before the current month,
+// or zero if all requests are kept
+func retentionCutoff(now time.Time, retentionMonths int) time.Time {
+	if retentionMonths == 0 {
+		return time.Time{}
+	}
+	return database.MonthPartition(now).From.AddDate(0, -retentionMonths, 0)
+}
+
+// Creates upcoming partitions and drops expired ones
+func maintainPartitions(ctx context.Context, pool *pgxpool.Pool, options PartitionConfig) error {
+	now := time.Now()
+	created, err := database.CreatePartitions(ctx, pool, now, options.MonthsAhead)
+	for _, partition := range created {
+		slog.Info("Partition created", slog.String("partition", partition.Name))
+	}
+	if err != nil {
+		return err
+	}
+
+	cutoff := retentionCutoff(now, options.RetentionMonths)
+	if cutoff.IsZero() {
+		return nil
+	}
+	dropped, err := database.DropPartitions(ctx, pool, cutoff)
+	for _, partition := range dropped {
+		slog.Info("Expired partition dropped", slog.String("partition", partition.Name))
+	}
+	return err
+}
+
+// Maintains partitions every partitionInterval until ctx is cancelled
+func watchPartitions(ctx context.Context, pool *pgxpool.Pool, options PartitionConfig) {
+	ticker := time.NewTicker(partitionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := maintainPartitions(ctx, pool, options); err != nil {
+				slog.Error("Failed to maintain partitions", logging.Err(err))
+			}
+		}
+	}
+}
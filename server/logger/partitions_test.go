@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	if cutoff := retentionCutoff(now, 0); !cutoff.IsZero() {
+		t.Errorf("got %s, expected all requests kept", cutoff)
+	}
+
+	expected := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if cutoff := retentionCutoff(now, 2); !cutoff.Equal(expected) {
+		t.Errorf("got %s, expected %s", cutoff, expected)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logging"
+)
+
+// How often rollups of recently stored requests are rebuilt
+const rollupInterval = time.Minute
+
+// Marked hours rebuilt on each run, so a large backlog is worked through
+// gradually
+const rollupLimit = 1000
+
+// Rebuilds rollups every rollupInterval until ctx is cancelled
+func watchRollups(ctx context.Context, pool *pgxpool.Pool) {
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rebuilt, err := database.RefreshRollups(ctx, pool, rollupLimit)
+			if err != nil {
+				slog.Error("Failed to refresh rollups", logging.Err(err))
+			} else if rebuilt > 0 {
+				slog.Debug("Rollups refreshed", slog.Int("hours", rebuilt))
+			}
+		}
+	}
+}
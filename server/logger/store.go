@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Columns inserted for each logged request, in row order
+var insertColumns = []string{
+	"api_key",
+	"path",
+	"hostname",
+	"ip_address",
+	"status",
+	"response_time",
+	"method",
+	"framework",
+	"location",
+	"region",
+	"city",
+	"asn",
+	"organisation",
+	"user_id",
+	"created_at",
+	"user_agent_id",
+	"is_bot",
+	"client_request_id",
+}
+
+// Row indexes of columns that need converting or filling in before insert
+const (
+	ipAddressColumn       int = 3
+	createdAtColumn       int = 14
+	userAgentIDColumn     int = 15
+	clientRequestIDColumn int = 17
+)
+
+// Batches at least this large are written with COPY rather than a multi-row
+// INSERT
+const copyThreshold int = 500
+
+// Postgres limit on bind parameters in a single statement
+const maxParameters int = 65535
+
+func insertRequests(ctx context.Context, tx pgx.Tx, rows [][]any) error {
+	// Rows with a client request ID may already be stored, and only INSERT can
+	// skip the duplicates
+	identified := make([][]any, 0)
+	anonymous := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		if row[clientRequestIDColumn] != nil {
+			identified = append(identified, row)
+		} else {
+			anonymous = append(anonymous, row)
+		}
+	}
+
+	if len(anonymous) >= copyThreshold {
+		// COPY requires typed values, fall back to INSERT and let Postgres
+		// parse any timestamps Go cannot
+		if copyRows, ok := prepareCopyRows(anonymous); ok {
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{"requests"}, insertColumns, pgx.CopyFromRows(copyRows)); err != nil {
+				return err
+			}
+			anonymous = nil
+		}
+	}
+	rows = append(identified, anonymous...)
+
+	// Split into as few statements as the bind parameter limit allows
+	batchSize := maxParameters / len(insertColumns)
+	for start := 0; start < len(rows); start += batchSize {
+		end := min(start+batchSize, len(rows))
+		query, arguments := buildInsertQuery(rows[start:end])
+		if _, err := tx.Exec(ctx, query, arguments...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildInsertQuery(rows [][]any) (string, []any) {
+	var query strings.Builder
+	query.WriteString("INSERT INTO requests (")
+	query.WriteString(strings.Join(insertColumns, ", "))
+	query.WriteString(") VALUES ")
+
+	arguments := make([]any, 0, len(rows)*len(insertColumns))
+	for i, row := range rows {
+		if i > 0 {
+			query.WriteString(",")
+		}
+		query.WriteString("(")
+		for j := range row {
+			if j > 0 {
+				query.WriteString(",")
+			}
+			query.WriteString(fmt.Sprintf("$%d", len(arguments)+j+1))
+		}
+		query.WriteString(")")
+		arguments = append(arguments, row...)
+	}
+	// Only skip requests retried with the same client request ID, so any
+	// other conflict is still reported
+	query.WriteString(" ON CONFLICT (api_key, client_request_id, created_at) WHERE client_request_id IS NOT NULL DO NOTHING;")
+
+	return query.String(), arguments
+}
+
+func prepareCopyRows(rows [][]any) ([][]any, bool) {
+	copyRows := make([][]any, len(rows))
+	for i, row := range rows {
+		copyRow := make([]any, len(row))
+		copy(copyRow, row)
+
+		if ipAddress, ok := row[ipAddressColumn].(string); ok {
+			addr, err := netip.ParseAddr(ipAddress)
+			if err != nil {
+				return nil, false
+			}
+			copyRow[ipAddressColumn] = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		createdAt, ok := parseCreatedAt(row[createdAtColumn].(string))
+		if !ok {
+			return nil, false
+		}
+		copyRow[createdAtColumn] = createdAt
+
+		copyRows[i] = copyRow
+	}
+	return copyRows, true
+}
+
+// Timestamp formats sent by the middleware clients, those without an offset
+// are treated as UTC
+var createdAtLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func parseCreatedAt(createdAt string) (time.Time, bool) {
+	for _, layout := range createdAtLayouts {
+		if t, err := time.Parse(layout, createdAt); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Records the IDs of batches about to be stored, returning the batches not
+// stored before. Batches without an ID are always stored.
+func recordBatches(ctx context.Context, tx pgx.Tx, batches []Batch) ([]Batch, error) {
+	apiKeys := make([]string, 0)
+	batchIDs := make([]string, 0)
+	for _, batch := range batches {
+		if batch.BatchID != "" {
+			apiKeys = append(apiKeys, batch.APIKey)
+			batchIDs = append(batchIDs, batch.BatchID)
+		}
+	}
+	if len(batchIDs) == 0 {
+		return batches, nil
+	}
+
+	query := `INSERT INTO ingested_batches (api_key, batch_id, created_at)
+		SELECT k::uuid, b, NOW() FROM unnest($1::text[], $2::text[]) AS t(k, b)
+		ON CONFLICT (api_key, batch_id) DO NOTHING RETURNING api_key::text, batch_id;`
+	rows, err := tx.Query(ctx, query, apiKeys, batchIDs)
+	if err != nil {
+		return nil, err
+	}
+	inserted := make(map[[2]string]bool)
+	for rows.Next() {
+		var apiKey, batchID string
+		if err := rows.Scan(&apiKey, &batchID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inserted[[2]string{apiKey, batchID}] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	unseen := make([]Batch, 0, len(batches))
+	for _, batch := range batches {
+		if batch.BatchID == "" {
+			unseen = append(unseen, batch)
+			continue
+		}
+		// Postgres returns API keys in canonical form
+		key := [2]string{strings.ToLower(batch.APIKey), batch.BatchID}
+		if inserted[key] {
+			unseen = append(unseen, batch)
+			// The same batch may have been queued twice
+			delete(inserted, key)
+		}
+	}
+	return unseen, nil
+}
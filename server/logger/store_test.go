@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRow(ipAddress any, createdAt string) []any {
+	return []any{"key", "/", "example.com", ipAddress, 200, 10, 0, 0, "GB", nil, nil, nil, nil, "", createdAt, 1, false, nil}
+}
+
+func TestBuildInsertQuery(t *testing.T) {
+	rows := [][]any{testRow(nil, "2024-01-01T00:00:00Z"), testRow("1.1.1.1", "2024-01-01T00:00:01Z")}
+	query, arguments := buildInsertQuery(rows)
+
+	if len(arguments) != 2*len(insertColumns) {
+		t.Errorf("got %d arguments, expected %d", len(arguments), 2*len(insertColumns))
+	}
+	if !strings.HasSuffix(query, ",$35,$36) ON CONFLICT (api_key, client_request_id, created_at) WHERE client_request_id IS NOT NULL DO NOTHING;") {
+		t.Errorf("unexpected query placeholders: %s", query)
+	}
+}
+
+func TestPrepareCopyRows(t *testing.T) {
+	rows := [][]any{testRow("1.1.1.1", "2024-01-01 12:30:00.123456")}
+	copyRows, ok := prepareCopyRows(rows)
+	if !ok {
+		t.Fatal("expected rows to be prepared for copy")
+	}
+
+	if prefix := copyRows[0][ipAddressColumn]; prefix != netip.MustParsePrefix("1.1.1.1/32") {
+		t.Errorf("got %v, expected 1.1.1.1/32", prefix)
+	}
+	expected := time.Date(2024, 1, 1, 12, 30, 0, 123456000, time.UTC)
+	if createdAt := copyRows[0][createdAtColumn].(time.Time); !createdAt.Equal(expected) {
+		t.Errorf("got %v, expected %v", createdAt, expected)
+	}
+	// Original rows left untouched for an INSERT fallback
+	if rows[0][ipAddressColumn] != "1.1.1.1" {
+		t.Error("original row modified")
+	}
+
+	if _, ok := prepareCopyRows([][]any{testRow(nil, "yesterday")}); ok {
+		t.Error("expected unparseable timestamp to fall back to insert")
+	}
+}
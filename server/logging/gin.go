@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carrying the ID of a request, taken from the client or proxy if set
+const RequestIDHeader = "X-Request-ID"
+
+// Gin context keys
+const (
+	loggerKey = "logging.logger"
+	apiKeyKey = "logging.api_key"
+	userIDKey = "logging.user_id"
+)
+
+type contextKey struct{}
+
+// Middleware gives each request an ID, attaches a logger carrying it to the
+// request context, and logs the request once handled.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		setLogger(c, logger.With(slog.String("request_id", requestID)))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		FromContext(c).LogAttrs(c.Request.Context(), level, "Request handled",
+			slog.String("method", c.Request.Method),
+			slog.String("path", MaskTokens(c.Request.URL.Path)),
+			slog.Int("status", status),
+			slog.String("client_ip", c.ClientIP()),
+			Latency(time.Since(start)),
+		)
+	}
+}
+
+// FromContext returns the request's logger, or the default logger outside
+// of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if c, ok := ctx.(*gin.Context); ok {
+		if logger, ok := c.Get(loggerKey); ok {
+			return logger.(*slog.Logger)
+		}
+		if c.Request == nil {
+			return slog.Default()
+		}
+		ctx = c.Request.Context()
+	}
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// SetAPIKey adds the masked API key to every later log of the request.
+func SetAPIKey(c *gin.Context, apiKey string) {
+	if _, ok := c.Get(apiKeyKey); ok || apiKey == "" {
+		return
+	}
+	c.Set(apiKeyKey, apiKey)
+	setLogger(c, FromContext(c).With(APIKey(apiKey)))
+}
+
+// SetUserID adds the user ID to every later log of the request.
+func SetUserID(c *gin.Context, userID string) {
+	if _, ok := c.Get(userIDKey); ok || userID == "" {
+		return
+	}
+	c.Set(userIDKey, userID)
+	setLogger(c, FromContext(c).With(UserID(userID)))
+}
+
+func setLogger(c *gin.Context, logger *slog.Logger) {
+	c.Set(loggerKey, logger)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, logger))
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Accepts IDs from upstream proxies, but nothing that could break a log line
+// or grow without bound
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Options configures where and how much a service logs, tagged to be read
+// by the config package
+type Options struct {
+	Path       string `config:"file" help:"Log file, or stderr if empty"`
+	Level      string `config:"level" help:"Minimum level logged: debug, info, warn or error"`
+	MaxSize    int    `config:"max_size" help:"Megabytes written before the log file is rotated, 0 to never rotate"`
+	MaxBackups int    `config:"max_backups" help:"Rotated log files kept"`
+}
+
+// New creates a logger writing JSON lines to the configured file, returning
+// the file to close on shutdown.
+func New(options Options) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if options.Level != "" {
+		if err := level.UnmarshalText([]byte(options.Level)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var w io.WriteCloser = nopCloser{os.Stderr}
+	if options.Path != "" {
+		file, err := NewRotatingFile(options.Path, int64(options.MaxSize)<<20, options.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = file
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(handler), w, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// MaskAPIKey keeps enough of an API key to tell accounts apart in logs
+// without recording the key itself.
+func MaskAPIKey(apiKey string) string {
+	const visible = 8
+	if len(apiKey) <= visible {
+		return strings.Repeat("*", len(apiKey))
+	}
+	return apiKey[:visible] + strings.Repeat("*", len(apiKey)-visible)
+}
+
+// APIKey returns a masked API key attribute.
+func APIKey(apiKey string) slog.Attr {
+	return slog.String("api_key", MaskAPIKey(apiKey))
+}
+
+// API keys and user IDs, which are UUIDs, and API tokens, with the same
+// prefix as database.TokenPrefix
+var tokenPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|aat_[A-Za-z0-9_-]+`)
+
+// MaskTokens masks any API keys, user IDs or API tokens within s, such as a
+// dashboard link's user ID in a URL path.
+func MaskTokens(s string) string {
+	return tokenPattern.ReplaceAllStringFunc(s, MaskAPIKey)
+}
+
+// UserID returns a masked dashboard user ID or share token attribute.
+func UserID(userID string) slog.Attr {
+	return slog.String("user_id", MaskAPIKey(userID))
+}
+
+// Latency returns a duration attribute in milliseconds.
+func Latency(d time.Duration) slog.Attr {
+	return slog.Float64("latency_ms", float64(d.Microseconds())/1000)
+}
+
+// Err returns an error attribute.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.String("error", "")
+	}
+	return slog.String("error", err.Error())
+}
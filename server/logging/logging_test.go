@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaskAPIKey(t *testing.T) {
+	expecteds := map[string]string{
+		"":                                     "",
+		"abc":                                  "***",
+		"0b3c1c0e-8c1d-4f3a-9e2b-7d6c5b4a3f21": "0b3c1c0e****************************",
+	}
+	for apiKey, expected := range expecteds {
+		if masked := MaskAPIKey(apiKey); masked != expected {
+			t.Errorf("got %q, expected %q", masked, expected)
+		}
+	}
+}
+
+func TestMaskTokens(t *testing.T) {
+	expecteds := map[string]string{
+		"/api/requests/0b3c1c0e-8c1d-4f3a-9e2b-7d6c5b4a3f21": "/api/requests/0b3c1c0e****************************",
+		"/api/requests/demo":               "/api/requests/demo",
+		"/api/requests/aat_AbCd1234EfGh/2": "/api/requests/aat_AbCd********/2",
+		"aat_x-y_z":                        "aat_x-y_*",
+	}
+	for value, expected := range expecteds {
+		if masked := MaskTokens(value); masked != expected {
+			t.Errorf("got %q, expected %q", masked, expected)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The oldest line is dropped beyond the two backups
+	expecteds := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for p, expected := range expecteds {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Errorf("%s: got %q, expected %q", p, content, expected)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected no third backup")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app := gin.New()
+	app.Use(Middleware(logger))
+	app.GET("/", func(c *gin.Context) {
+		SetAPIKey(c, "0b3c1c0e-8c1d-4f3a-9e2b-7d6c5b4a3f21")
+		FromContext(c.Request.Context()).Info("Handled")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Errorf("got request ID %q, expected abc-123", w.Header().Get(RequestIDHeader))
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, expected 2", len(lines))
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["request_id"] != "abc-123" {
+			t.Errorf("got request ID %v in %s", entry["request_id"], line)
+		}
+		if entry["api_key"] != "0b3c1c0e****************************" {
+			t.Errorf("got API key %v in %s", entry["api_key"], line)
+		}
+	}
+
+	// Unsafe request IDs are replaced
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad\nid")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if id := w.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Errorf("got request ID %q, expected generated ID", id)
+	}
+}
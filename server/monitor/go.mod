@@ -1,21 +1,24 @@
 module monitor
 
-go 1.21
+go 1.21.0
 
 toolchain go1.21.4
 
 require (
-	github.com/jackc/pgx v3.6.2+incompatible
 	github.com/jackc/pgx/v5 v5.7.1
+	github.com/tom-draper/api-analytics/server/config v0.0.0
 	github.com/tom-draper/api-analytics/server/database v0.0.0-20241029191841-fbaa9e8c603e
 )
 
 require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
+	github.com/kr/pretty v0.3.1 // indirect
 	golang.org/x/crypto v0.28.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/tom-draper/api-analytics/server/config => ../config
@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/tom-draper/api-analytics/server/config"
 	"github.com/tom-draper/api-analytics/server/database"
 )
 
@@ -85,9 +89,9 @@ func getMonitoredURLs(conn *pgx.Conn) []MonitorRow {
 	return monitors
 }
 
-func deleteExpiredPings(conn *pgx.Conn) error {
-	// Calculate the timestamp 60 days ago
-	expiryTime := time.Now().Add(-60 * 24 * time.Hour).UTC()
+func deleteExpiredPings(conn *pgx.Conn, retentionDays int) error {
+	// Calculate the timestamp retentionDays days ago
+	expiryTime := time.Now().AddDate(0, 0, -retentionDays).UTC()
 
 	// Define the query with a parameter placeholder
 	query := "DELETE FROM pings WHERE created_at < $1;"
@@ -147,8 +151,8 @@ func shuffle(monitored []MonitorRow) {
 	})
 }
 
-func pingMonitored(monitored []MonitorRow) []PingsRow {
-	client := getClient()
+func pingMonitored(monitored []MonitorRow, timeout time.Duration) []PingsRow {
+	client := getClient(timeout)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
@@ -183,8 +187,8 @@ func pingMonitored(monitored []MonitorRow) []PingsRow {
 	return pings
 }
 
-func getClient() http.Client {
-	dialer := net.Dialer{Timeout: 2 * time.Second}
+func getClient(timeout time.Duration) http.Client {
+	dialer := net.Dialer{Timeout: timeout}
 	var client = http.Client{
 		Transport: &http.Transport{
 			Dial: dialer.Dial,
@@ -193,8 +197,34 @@ func getClient() http.Client {
 	return client
 }
 
+type Config struct {
+	PingRetentionDays int           `config:"ping_retention_days" help:"Days pings are kept before being deleted"`
+	Timeout           time.Duration `config:"timeout" help:"Time allowed to connect to a monitored URL"`
+}
+
+func (c *Config) Validate() error {
+	if c.PingRetentionDays <= 0 {
+		return fmt.Errorf("ping_retention_days must be positive, got %d", c.PingRetentionDays)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", c.Timeout)
+	}
+	return nil
+}
+
 func main() {
-	err := database.LoadConfig()
+	cfg := Config{
+		PingRetentionDays: 60,
+		Timeout:           2 * time.Second,
+	}
+	err := config.Load(&cfg, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	err = database.LoadConfig()
 	if err != nil {
 		panic(err)
 	}
@@ -210,12 +240,12 @@ func main() {
 	// due to cold starts or caching
 	shuffle(monitored)
 
-	pings := pingMonitored(monitored)
+	pings := pingMonitored(monitored, cfg.Timeout)
 	err = uploadPings(pings, conn)
 	if err != nil {
 		panic(err)
 	}
-	err = deleteExpiredPings(conn)
+	err = deleteExpiredPings(conn, cfg.PingRetentionDays)
 	if err != nil {
 		panic(err)
 	}
@@ -75,9 +75,9 @@ response=$(curl -skLo - -w "%{http_code}" -X POST  \
 status_code="${response: -3}"
 response_body="${response%???}"
 
-# Check if the status code is 201 (Created)
-display_result "$response_body" "$status_code" 201
-if [ "$status_code" -ne 201 ]; then
+# Check if the status code is 202 (Accepted)
+display_result "$response_body" "$status_code" 202
+if [ "$status_code" -ne 202 ]; then
     exit 2
 fi
 
@@ -9,50 +9,73 @@ import (
 
 	"log"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/tom-draper/api-analytics/server/database"
 	"github.com/tom-draper/api-analytics/server/tools/usage"
 )
 
-const requestsLimit int = 1_500_000
 const userExpiry time.Duration = time.Hour * 24 * 30 * 6
 
-func deleteOldestRequests(apiKey string, count int) error {
-	conn, err := database.NewConnection()
+// How long batch IDs are kept to skip batches retried by clients
+const batchIDExpiry time.Duration = time.Hour * 24
+
+func deleteExpiredRequests(ctx context.Context, db *pgxpool.Pool) {
+	// Only accounts holding more than the lowest retention limit can be over
+	// their own quota
+	minLimit, err := database.MinRetentionRows(ctx, db)
 	if err != nil {
-		return err
+		log.Fatalf("Failed to fetch retention limits: %v", err)
 	}
-	defer conn.Close(context.Background())
-
-	query := "DELETE FROM requests WHERE request_id = any(array(SELECT request_id from requests WHERE api_key = $1 ORDER BY created_at LIMIT $2));"
 
-	_, err = conn.Exec(context.Background(), query, apiKey, count)
-	return err
-}
-
-func deleteExpiredRequests() {
-	users, err := usage.UserRequestsOverLimit(context.Background(), requestsLimit)
+	users, err := usage.UserRequestsOverLimit(ctx, minLimit)
 	if err != nil {
 		log.Fatalf("Failed to fetch users over limit: %v", err) // Use log for error handling
 	}
 
 	log.Printf("%d users found\n", len(users))
 	for _, user := range users {
-		err = deleteOldestRequests(user.APIKey, user.Count-requestsLimit)
+		quota, err := database.GetQuota(ctx, db, user.APIKey)
+		if err != nil {
+			log.Printf("Error fetching quota for user %s: %v", user.APIKey, err)
+			continue
+		}
+		if user.Count <= quota.RetentionRows {
+			continue
+		}
+
+		err = database.DeleteOldestRequests(ctx, db, user.APIKey, user.Count-quota.RetentionRows)
 		if err != nil {
 			log.Printf("Error deleting requests for user %s: %v", user.APIKey, err)
 			continue // Don't panic, just log the error and continue
 		}
-		log.Printf("%s: %d requests deleted\n", user.APIKey, user.Count-requestsLimit)
+		log.Printf("%s: %d requests deleted\n", user.APIKey, user.Count-quota.RetentionRows)
+	}
+}
+
+func deleteExpiredBatchIDs(ctx context.Context, db *pgxpool.Pool) {
+	query := "DELETE FROM ingested_batches WHERE created_at < $1;"
+	result, err := db.Exec(ctx, query, time.Now().Add(-batchIDExpiry))
+	if err != nil {
+		log.Fatalf("Failed to delete expired batch IDs: %v", err)
 	}
+	log.Printf("%d batch IDs deleted\n", result.RowsAffected())
 }
 
-func deleteExpiredUsers() {
-	deleteExpiredUnusedUsers()
-	deleteExpiredRetiredUsers()
+func deleteExpiredAPIKeys(ctx context.Context, db *pgxpool.Pool) {
+	deleted, err := database.DeleteExpiredAPIKeys(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to delete expired API keys: %v", err)
+	}
+	log.Printf("%d rotated API keys deleted\n", deleted)
 }
 
-func deleteExpiredUnusedUsers() {
-	users, err := usage.UnusedUsers(context.Background())
+func deleteExpiredUsers(ctx context.Context, db *pgxpool.Pool) {
+	deleteExpiredUnusedUsers(ctx, db)
+	deleteExpiredRetiredUsers(ctx, db)
+}
+
+func deleteExpiredUnusedUsers(ctx context.Context, db *pgxpool.Pool) {
+	users, err := usage.UnusedUsers(ctx)
 	if err != nil {
 		log.Fatalf("Failed to fetch unused users: %v", err)
 	}
@@ -60,13 +83,13 @@ func deleteExpiredUnusedUsers() {
 	log.Printf("%d unused users found\n", len(users))
 	for _, user := range users {
 		if time.Since(user.CreatedAt) > userExpiry {
-			deleteUser(user.APIKey)
+			deleteUser(ctx, db, user.APIKey)
 		}
 	}
 }
 
-func deleteExpiredRetiredUsers() {
-	users, err := usage.SinceLastRequestUsers(context.Background())
+func deleteExpiredRetiredUsers(ctx context.Context, db *pgxpool.Pool) {
+	users, err := usage.SinceLastRequestUsers(ctx)
 	if err != nil {
 		log.Fatalf("Failed to fetch retired users: %v", err)
 	}
@@ -74,13 +97,13 @@ func deleteExpiredRetiredUsers() {
 	log.Printf("%d retired users found\n", len(users))
 	for _, user := range users {
 		if time.Since(user.CreatedAt) > userExpiry {
-			deleteUser(user.APIKey)
+			deleteUser(ctx, db, user.APIKey)
 		}
 	}
 }
 
-func deleteUser(apiKey string) {
-	fmt.Printf("Delete API key '%s' from the database? (Y/n): ", apiKey)
+func deleteUser(ctx context.Context, db *pgxpool.Pool, apiKey string) {
+	fmt.Printf("Delete account '%s' from the database? (Y/n): ", apiKey)
 	var response string
 	_, err := fmt.Scanln(&response)
 	if err != nil {
@@ -93,34 +116,13 @@ func deleteUser(apiKey string) {
 		return
 	}
 
-	err = database.DeleteUser(apiKey)
+	// Remove the account from every table together so a failure part way
+	// through leaves it intact
+	err = database.DeleteAccount(ctx, db, apiKey)
 	if err != nil {
-		log.Printf("Failed to delete user from 'users' table: %v", err)
+		log.Printf("Failed to delete user: %v", err)
 		return
 	}
-	log.Println("User deleted from table 'users'.")
-
-	err = database.DeleteRequests(apiKey)
-	if err != nil {
-		log.Printf("Failed to delete user from 'requests' table: %v", err)
-		return
-	}
-	log.Println("User deleted from table 'requests'.")
-
-	err = database.DeleteMonitors(apiKey)
-	if err != nil {
-		log.Printf("Failed to delete user from 'monitors' table: %v", err)
-		return
-	}
-	log.Println("User deleted from table 'monitors'.")
-
-	err = database.DeletePings(apiKey)
-	if err != nil {
-		log.Printf("Failed to delete user from 'pings' table: %v", err)
-		return
-	}
-	log.Println("User deleted from table 'pings'.")
-
 	log.Println("User deletion successful.")
 }
 
@@ -148,7 +150,7 @@ func getOptions() Options {
 }
 
 func displayHelp() {
-	fmt.Printf("Cleanup - A command-line tool to delete expired users and requests.\n\nOptions:\n`--users` to delete expired users\n`--target-user` to specify an API key for account deletion\n`--help` to display help\n")
+	fmt.Printf("Cleanup - A command-line tool to delete expired users, requests, batch IDs and rotated API keys.\n\nOptions:\n`--users` to delete expired users\n`--target-user` to specify an API key or account ID for account deletion\n`--help` to display help\n")
 }
 
 func main() {
@@ -157,13 +159,29 @@ func main() {
 		displayHelp()
 		return
 	}
+
+	ctx := context.Background()
+	db, err := database.NewPool(ctx)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
 	if options.targetUser != "" {
-		deleteUser(options.targetUser)
+		// An API key is resolved to its account, and anything else is taken
+		// to be an account ID
+		accountID, err := database.AuthenticateAPIKey(ctx, db, options.targetUser)
+		if err != nil {
+			accountID = options.targetUser
+		}
+		deleteUser(ctx, db, accountID)
 		return
 	}
 
 	if options.users {
-		deleteExpiredUsers()
+		deleteExpiredUsers(ctx, db)
 	}
-	deleteExpiredRequests()
+	deleteExpiredRequests(ctx, db)
+	deleteExpiredBatchIDs(ctx, db)
+	deleteExpiredAPIKeys(ctx, db)
 }
@@ -0,0 +1,5 @@
+module github.com/tom-draper/api-analytics/server/tools/export
+
+go 1.21
+
+toolchain go1.21.4
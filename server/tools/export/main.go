@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultServer = "https://apianalytics-server.com"
+
+type Options struct {
+	apiKey  string
+	server  string
+	format  string
+	gzip    bool
+	output  string
+	filters []string
+	help    bool
+}
+
+func getOptions() Options {
+	options := Options{server: defaultServer, format: "csv", output: "-"}
+	for i, arg := range os.Args {
+		var value string
+		if i+1 < len(os.Args) {
+			value = os.Args[i+1]
+		}
+
+		switch arg {
+		case "--api-key":
+			options.apiKey = value
+		case "--server":
+			options.server = value
+		case "--format":
+			if value != "csv" && value != "ndjson" && value != "parquet" {
+				log.Fatalf("Invalid format: %s", value)
+			}
+			options.format = value
+		case "--gzip":
+			options.gzip = true
+		case "--output":
+			options.output = value
+		case "--filter":
+			options.filters = append(options.filters, value)
+		case "--help":
+			options.help = true
+		}
+	}
+	return options
+}
+
+func displayHelp() {
+	fmt.Printf("Export - A command-line tool to download an account's logged requests.\n\nOptions:\n`--api-key` to specify the account's API key\n`--server` to specify the server URL (default %s)\n`--format` to specify the file format: csv (default), ndjson or parquet\n`--gzip` to gzip a csv or ndjson export, parquet is always compressed\n`--output` to specify the file to write, or `-` (default) for stdout\n`--filter` to filter requests with a data endpoint parameter, such as status=5xx, repeatable\n`--help` to display help\n\nRequests are streamed to the output as they are read, so exports of any size can be downloaded.\n", defaultServer)
+}
+
+// Builds the export URL, passing filters through as query parameters
+func exportURL(options Options) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(options.server, "/") + "/api/export")
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	for _, filter := range options.filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key == "" {
+			return "", fmt.Errorf("invalid filter %q, expected key=value", filter)
+		}
+		query.Add(key, value)
+	}
+	query.Set("format", options.format)
+	if options.gzip {
+		query.Set("gzip", "true")
+	}
+	base.RawQuery = query.Encode()
+	return base.String(), nil
+}
+
+// Streams an export to w, returning the number of requests exported if
+// reported by the server
+func download(client *http.Client, exportURL string, apiKey string, w io.Writer) (string, error) {
+	request, err := http.NewRequest(http.MethodGet, exportURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-AUTH-TOKEN", apiKey)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var body struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body.Message == "" {
+			return "", fmt.Errorf("export failed with status %d", response.StatusCode)
+		}
+		return "", errors.New(body.Message)
+	}
+
+	// The server ends the connection early if the export fails part way
+	if _, err := io.Copy(w, response.Body); err != nil {
+		return "", err
+	}
+	// Trailers are only available once the body has been read, and may be
+	// dropped by proxies
+	return response.Trailer.Get("X-Export-Rows"), nil
+}
+
+func main() {
+	options := getOptions()
+	if options.help || options.apiKey == "" {
+		displayHelp()
+		return
+	}
+
+	target, err := exportURL(options)
+	if err != nil {
+		log.Fatalf("Invalid options: %v", err)
+	}
+
+	var output io.Writer = os.Stdout
+	if options.output != "-" {
+		file, err := os.Create(options.output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", options.output, err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	count, err := download(http.DefaultClient, target, options.apiKey, output)
+	if err != nil {
+		if options.output != "-" {
+			// Remove the incomplete export
+			os.Remove(options.output)
+		}
+		log.Fatalf("Export failed: %v", err)
+	}
+	if count == "" {
+		log.Println("Export complete.")
+	} else {
+		log.Printf("Exported %s requests.", count)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestExportURL(t *testing.T) {
+	options := Options{
+		server:  "https://example.com/",
+		format:  "parquet",
+		filters: []string{"status=5xx", "path=/v1/*", "status=!503"},
+	}
+	got, err := exportURL(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != "/api/export" {
+		t.Errorf("path = %q, want /api/export", parsed.Path)
+	}
+	query := parsed.Query()
+	if query.Get("format") != "parquet" || query.Get("path") != "/v1/*" || len(query["status"]) != 2 {
+		t.Errorf("query = %v", query)
+	}
+
+	options.filters = []string{"status"}
+	if _, err := exportURL(options); err == nil {
+		t.Error("expected error for filter without a value")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-AUTH-TOKEN") != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": 400, "message": "Invalid API key."}`))
+			return
+		}
+		w.Header().Set("Trailer", "X-Export-Rows")
+		w.Write([]byte("path\n/a\n"))
+		if r.URL.Query().Get("fail") == "true" {
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("X-Export-Rows", "1")
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	count, err := download(server.Client(), server.URL, "key", &output)
+	if err != nil || count != "1" || output.String() != "path\n/a\n" {
+		t.Errorf("download = %q, %v, output %q", count, err, output.String())
+	}
+
+	_, err = download(server.Client(), server.URL+"?fail=true", "key", &output)
+	if err == nil {
+		t.Error("expected error for truncated export")
+	}
+
+	_, err = download(server.Client(), server.URL, "wrong", &output)
+	if err == nil || err.Error() != "Invalid API key." {
+		t.Errorf("expected response error, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+module github.com/tom-draper/api-analytics/server/tools/importer
+
+go 1.21.0
+
+toolchain go1.21.4
+
+require (
+	github.com/jackc/pgx/v5 v5.7.1
+	github.com/tom-draper/api-analytics/server/database v0.0.0-20241029191841-fbaa9e8c603e
+	github.com/tom-draper/api-analytics/server/logger v0.0.0-20241029184920-9272b43892b6
+)
+
+require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/oschwald/geoip2-golang v1.11.0 // indirect
+	github.com/oschwald/maxminddb-golang v1.13.1 // indirect
+	github.com/tom-draper/api-analytics/server/config v0.0.0 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/text v0.19.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace (
+	github.com/tom-draper/api-analytics/server/config => ../../config
+	github.com/tom-draper/api-analytics/server/database => ../../database
+	github.com/tom-draper/api-analytics/server/logger => ../../logger
+	github.com/tom-draper/api-analytics/server/logging => ../../logging
+)
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logger/lib/bots"
+	"github.com/tom-draper/api-analytics/server/logger/lib/geoip"
+)
+
+type Options struct {
+	apiKey       string
+	files        []string
+	format       string
+	hostname     string
+	privacyLevel int
+	batchSize    int
+	dryRun       bool
+	help         bool
+}
+
+func getOptions() Options {
+	options := Options{format: FormatAuto, batchSize: 5000}
+	for i, arg := range os.Args {
+		var value string
+		if i+1 < len(os.Args) {
+			value = os.Args[i+1]
+		}
+
+		switch arg {
+		case "--api-key":
+			options.apiKey = value
+		case "--file":
+			options.files = append(options.files, value)
+		case "--format":
+			if value != FormatAuto && value != FormatCLF && value != FormatCaddy {
+				log.Fatalf("Invalid format: %s", value)
+			}
+			options.format = value
+		case "--hostname":
+			options.hostname = value
+		case "--privacy-level":
+			level, err := strconv.Atoi(value)
+			if err != nil || level < 0 || level > 2 {
+				log.Fatalf("Invalid privacy level: %s", value)
+			}
+			options.privacyLevel = level
+		case "--batch-size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				log.Fatalf("Invalid batch size: %s", value)
+			}
+			options.batchSize = size
+		case "--dry-run":
+			options.dryRun = true
+		case "--help":
+			options.help = true
+		}
+	}
+	return options
+}
+
+func displayHelp() {
+	fmt.Printf("Importer - A command-line tool to import requests from web server access logs.\n\nOptions:\n`--api-key` to specify the account's API key\n`--file` to specify an access log to import, repeatable, gzipped logs are supported and `-` reads from stdin\n`--format` to specify the log format: auto (default), clf (common, combined and nginx with $request_time) or caddy (JSON)\n`--hostname` to record against requests in logs without a hostname\n`--privacy-level` 0 (default) stores client IP addresses, 1 stores only the inferred location, 2 stores neither\n`--batch-size` to specify the number of requests inserted per transaction\n`--dry-run` to parse logs and report counts without storing anything\n`--help` to display help\n\nRequests already imported for the account are skipped, so an import can be safely re-run.\n")
+}
+
+// Logged request parsed from an access log line
+type entry struct {
+	request     RequestData
+	fingerprint []byte // Identifies the line across imports
+}
+
+// Counts of lines read during an import
+type summary struct {
+	lines    int
+	skipped  int
+	invalid  int
+	imported int
+	existing int
+	reasons  map[string]int // Counts of invalid lines by reason
+}
+
+func (s *summary) reject(reason string) {
+	s.invalid++
+	if s.reasons == nil {
+		s.reasons = make(map[string]int)
+	}
+	s.reasons[reason]++
+}
+
+func openLog(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, file}, nil
+}
+
+// Fingerprints a line by its content and how many times the same line has
+// been seen before, so identical lines in a log are each imported once
+func fingerprint(apiKey string, line string, seen map[[sha256.Size]byte]int) []byte {
+	lineHash := sha256.Sum256([]byte(line))
+	occurrence := seen[lineHash]
+	seen[lineHash]++
+
+	hash := sha256.New()
+	hash.Write([]byte(apiKey))
+	hash.Write(lineHash[:])
+	hash.Write([]byte(strconv.Itoa(occurrence)))
+	return hash.Sum(nil)
+}
+
+func importLog(ctx context.Context, s *store, reader io.Reader, options Options, total *summary) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	seen := make(map[[sha256.Size]byte]int)
+	batch := make([]entry, 0, options.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if options.dryRun {
+			total.imported += len(batch)
+		} else {
+			imported, err := s.storeBatch(ctx, batch)
+			if err != nil {
+				return err
+			}
+			total.imported += imported
+			total.existing += len(batch) - imported
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		total.lines++
+
+		request, err := parseLine(line, options.format)
+		if errors.Is(err, errSkipped) {
+			total.skipped++
+			continue
+		} else if err != nil {
+			total.reject("unparseable line")
+			continue
+		}
+		if request.Hostname == "" {
+			request.Hostname = options.hostname
+		}
+		request, err = validateRequest(request)
+		if err != nil {
+			total.reject(err.Error())
+			continue
+		}
+
+		batch = append(batch, entry{request, fingerprint(options.apiKey, line, seen)})
+		if len(batch) >= options.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func main() {
+	options := getOptions()
+	if options.help || options.apiKey == "" || len(options.files) == 0 {
+		displayHelp()
+		return
+	}
+
+	ctx := context.Background()
+	var s *store
+	if !options.dryRun {
+		conn, err := database.NewConnection(ctx)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer conn.Close(ctx)
+
+		// Requests are stored against the account the key belongs to, which
+		// stays the same if the key is rotated
+		accountID, scope, err := database.Authenticate(ctx, conn, options.apiKey)
+		if err != nil {
+			log.Fatalf("Invalid API key: %v", err)
+		}
+		if !database.ScopeAllows(scope, database.ScopeIngest) {
+			log.Fatal("Invalid API key: token scope does not allow logging requests")
+		}
+		options.apiKey = accountID
+
+		// Same location and bot enrichment as the logger, using any GeoIP
+		// databases and bot signatures in the working directory
+		locator := geoip.NewLocator()
+		defer locator.Close()
+		detector, err := bots.NewDetector()
+		if err != nil {
+			log.Fatalf("Failed to load bot signatures: %v", err)
+		}
+		defer detector.Close()
+
+		s = &store{
+			conn:         conn,
+			apiKey:       options.apiKey,
+			privacyLevel: options.privacyLevel,
+			locator:      locator,
+			detector:     detector,
+		}
+	}
+
+	var total summary
+	for _, path := range options.files {
+		reader, err := openLog(path)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", path, err)
+		}
+		err = importLog(ctx, s, reader, options, &total)
+		reader.Close()
+		if err != nil {
+			log.Fatalf("Failed to import %s: %v", path, err)
+		}
+		log.Printf("%s imported.", path)
+	}
+
+	fmt.Printf("Lines read: %d\nImported: %d\nAlready imported: %d\nSkipped: %d\nInvalid: %d\n",
+		total.lines, total.imported, total.existing, total.skipped, total.invalid)
+	reasons := make([]string, 0, len(total.reasons))
+	for reason := range total.reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("  %s: %d\n", reason, total.reasons[reason])
+	}
+}
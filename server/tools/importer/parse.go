@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matches the logger's RequestData, the fields the middleware sends for each
+// logged request
+type RequestData struct {
+	Path         string
+	Hostname     string
+	IPAddress    string
+	UserAgent    string
+	Method       string
+	Status       int16
+	ResponseTime int16
+	UserID       string
+	CreatedAt    time.Time
+}
+
+// Supported access log formats
+const (
+	FormatAuto  = "auto"
+	FormatCLF   = "clf"   // Common and combined log formats, with optional nginx $request_time
+	FormatCaddy = "caddy" // Caddy JSON access logs
+)
+
+var errSkipped = errors.New("not a logged request")
+
+// Parses a line in the given format, detecting the format if auto
+func parseLine(line string, format string) (RequestData, error) {
+	switch format {
+	case FormatCLF:
+		return parseCLF(line)
+	case FormatCaddy:
+		return parseCaddy(line)
+	default:
+		if strings.HasPrefix(strings.TrimSpace(line), "{") {
+			return parseCaddy(line)
+		}
+		return parseCLF(line)
+	}
+}
+
+// host ident authuser [time] "request" status bytes, optionally followed by
+// "referer" "user-agent" (combined) and any extra fields
+var clfRegex = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d{3}) \S+(?: "(?:[^"\\]|\\.)*" "((?:[^"\\]|\\.)*)")?(.*)$`)
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+func parseCLF(line string) (RequestData, error) {
+	matches := clfRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return RequestData{}, errors.New("line does not match common or combined log format")
+	}
+
+	createdAt, err := time.Parse(clfTimeLayout, matches[2])
+	if err != nil {
+		return RequestData{}, err
+	}
+
+	// Malformed requests are logged with a request line of "-" or raw bytes
+	request := strings.Fields(matches[3])
+	if len(request) < 2 {
+		return RequestData{}, errSkipped
+	}
+
+	status, err := strconv.Atoi(matches[4])
+	if err != nil {
+		return RequestData{}, err
+	}
+
+	userAgent := strings.ReplaceAll(matches[5], `\"`, `"`)
+	if userAgent == "-" {
+		userAgent = ""
+	}
+
+	return RequestData{
+		Path:         requestPath(request[1]),
+		IPAddress:    matches[1],
+		UserAgent:    userAgent,
+		Method:       request[0],
+		Status:       int16(status),
+		ResponseTime: parseRequestTime(matches[6]),
+		CreatedAt:    createdAt,
+	}, nil
+}
+
+// Finds an nginx $request_time in seconds among the fields after the user
+// agent, either alone or as request_time= or rt=
+func parseRequestTime(fields string) int16 {
+	for _, field := range strings.Fields(fields) {
+		field = strings.Trim(field, `"`)
+		if key, value, ok := strings.Cut(field, "="); ok {
+			if key != "request_time" && key != "rt" {
+				continue
+			}
+			field = value
+		}
+		if seconds, err := strconv.ParseFloat(field, 64); err == nil {
+			return milliseconds(seconds)
+		}
+	}
+	return 0
+}
+
+type caddyLog struct {
+	Logger  string          `json:"logger"`
+	Ts      json.RawMessage `json:"ts"`
+	Request struct {
+		RemoteIP   string              `json:"remote_ip"`
+		RemoteAddr string              `json:"remote_addr"`
+		ClientIP   string              `json:"client_ip"`
+		Method     string              `json:"method"`
+		Host       string              `json:"host"`
+		URI        string              `json:"uri"`
+		Headers    map[string][]string `json:"headers"`
+	} `json:"request"`
+	Duration float64 `json:"duration"` // Seconds
+	Status   int     `json:"status"`
+}
+
+func parseCaddy(line string) (RequestData, error) {
+	var entry caddyLog
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return RequestData{}, err
+	}
+	// Other Caddy logs may be written to the same file
+	if entry.Request.Method == "" || entry.Status == 0 {
+		return RequestData{}, errSkipped
+	}
+
+	createdAt, err := parseCaddyTime(entry.Ts)
+	if err != nil {
+		return RequestData{}, err
+	}
+
+	ipAddress := entry.Request.ClientIP
+	if ipAddress == "" {
+		ipAddress = entry.Request.RemoteIP
+	}
+	if ipAddress == "" {
+		ipAddress, _, _ = net.SplitHostPort(entry.Request.RemoteAddr)
+	}
+
+	var userAgent string
+	for name, values := range entry.Request.Headers {
+		if strings.EqualFold(name, "User-Agent") && len(values) > 0 {
+			userAgent = values[0]
+		}
+	}
+
+	hostname, _, err := net.SplitHostPort(entry.Request.Host)
+	if err != nil {
+		hostname = entry.Request.Host
+	}
+
+	return RequestData{
+		Path:         requestPath(entry.Request.URI),
+		Hostname:     hostname,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Method:       entry.Request.Method,
+		Status:       int16(entry.Status),
+		ResponseTime: milliseconds(entry.Duration),
+		CreatedAt:    createdAt,
+	}, nil
+}
+
+// Caddy writes timestamps as Unix seconds by default, or as a string if a
+// time_format is configured
+func parseCaddyTime(ts json.RawMessage) (time.Time, error) {
+	var seconds float64
+	if err := json.Unmarshal(ts, &seconds); err == nil {
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+
+	var value string
+	if err := json.Unmarshal(ts, &value); err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range []string{time.RFC3339Nano, clfTimeLayout, "2006/01/02 15:04:05.000"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognised timestamp " + value)
+}
+
+// Strips the query string from a request URI
+func requestPath(uri string) string {
+	path, _, _ := strings.Cut(uri, "?")
+	return path
+}
+
+func milliseconds(seconds float64) int16 {
+	ms := math.Round(seconds * 1000)
+	return int16(min(max(ms, 0), math.MaxInt16))
+}
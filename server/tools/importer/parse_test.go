@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	createdAt := time.Date(2024, 1, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+
+	expecteds := []struct {
+		format   string
+		line     string
+		expected RequestData
+	}{
+		{
+			FormatAuto,
+			`127.0.0.1 - frank [10/Jan/2024:13:55:36 -0700] "GET /apache_pb.gif?x=1 HTTP/1.0" 200 2326`,
+			RequestData{Path: "/apache_pb.gif", IPAddress: "127.0.0.1", Method: "GET", Status: 200, CreatedAt: createdAt},
+		},
+		{
+			FormatCLF,
+			`203.0.113.5 - - [10/Jan/2024:13:55:36 -0700] "POST /api/users HTTP/1.1" 201 512 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64) \"quoted\""`,
+			RequestData{Path: "/api/users", IPAddress: "203.0.113.5", UserAgent: `Mozilla/5.0 (X11; Linux x86_64) "quoted"`, Method: "POST", Status: 201, CreatedAt: createdAt},
+		},
+		{
+			// nginx combined with $request_time appended
+			FormatAuto,
+			`203.0.113.5 - - [10/Jan/2024:13:55:36 -0700] "GET /health HTTP/1.1" 200 2 "-" "curl/8.4.0" 0.034`,
+			RequestData{Path: "/health", IPAddress: "203.0.113.5", UserAgent: "curl/8.4.0", Method: "GET", Status: 200, ResponseTime: 34, CreatedAt: createdAt},
+		},
+		{
+			FormatAuto,
+			`203.0.113.5 - - [10/Jan/2024:13:55:36 -0700] "GET /health HTTP/1.1" 200 2 "-" "curl/8.4.0" "-" upstream=10.0.0.2:80 rt=1.5 uct="0.001"`,
+			RequestData{Path: "/health", IPAddress: "203.0.113.5", UserAgent: "curl/8.4.0", Method: "GET", Status: 200, ResponseTime: 1500, CreatedAt: createdAt},
+		},
+		{
+			FormatAuto,
+			`{"level":"info","ts":1704895536.25,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"10.0.0.1","remote_addr":"10.0.0.1:51234","client_ip":"198.51.100.7","proto":"HTTP/2.0","method":"GET","host":"api.example.com:443","uri":"/v1/items?page=2","headers":{"User-Agent":["python-requests/2.31.0"]}},"duration":0.0125,"size":1024,"status":404}`,
+			RequestData{Path: "/v1/items", Hostname: "api.example.com", IPAddress: "198.51.100.7", UserAgent: "python-requests/2.31.0", Method: "GET", Status: 404, ResponseTime: 13, CreatedAt: time.Date(2024, 1, 10, 14, 5, 36, 250000000, time.UTC)},
+		},
+		{
+			FormatCaddy,
+			`{"ts":"2024-01-10T14:05:36Z","request":{"remote_addr":"198.51.100.7:51234","method":"DELETE","host":"api.example.com","uri":"/v1/items/3","headers":{}},"duration":0.5,"status":204}`,
+			RequestData{Path: "/v1/items/3", Hostname: "api.example.com", IPAddress: "198.51.100.7", Method: "DELETE", Status: 204, ResponseTime: 500, CreatedAt: time.Date(2024, 1, 10, 14, 5, 36, 0, time.UTC)},
+		},
+	}
+
+	for _, expected := range expecteds {
+		request, err := parseLine(expected.line, expected.format)
+		if err != nil {
+			t.Errorf("%s: %v", expected.line, err)
+			continue
+		}
+		if !request.CreatedAt.Equal(expected.expected.CreatedAt) {
+			t.Errorf("%s: got created at %v, expected %v", expected.line, request.CreatedAt, expected.expected.CreatedAt)
+		}
+		request.CreatedAt = expected.expected.CreatedAt
+		if request != expected.expected {
+			t.Errorf("%s: got %+v, expected %+v", expected.line, request, expected.expected)
+		}
+	}
+}
+
+func TestParseLineSkipped(t *testing.T) {
+	lines := []string{
+		`203.0.113.5 - - [10/Jan/2024:13:55:36 -0700] "-" 400 0 "-" "-"`,
+		`{"level":"info","ts":1704895536.25,"logger":"tls","msg":"certificate obtained"}`,
+	}
+	for _, line := range lines {
+		if _, err := parseLine(line, FormatAuto); !errors.Is(err, errSkipped) {
+			t.Errorf("%s: got %v, expected skipped", line, err)
+		}
+	}
+
+	if _, err := parseLine("not an access log", FormatAuto); err == nil || errors.Is(err, errSkipped) {
+		t.Errorf("got %v, expected parse error", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	seen := make(map[[sha256.Size]byte]int)
+	line := `127.0.0.1 - - [10/Jan/2024:13:55:36 -0700] "GET / HTTP/1.0" 200 2326`
+
+	first := fingerprint("key", line, seen)
+	second := fingerprint("key", line, seen)
+	if string(first) == string(second) {
+		t.Error("expected repeated lines to have different fingerprints")
+	}
+
+	// Re-reading the same log gives the same fingerprints
+	seen = make(map[[sha256.Size]byte]int)
+	if string(fingerprint("key", line, seen)) != string(first) {
+		t.Error("expected fingerprints to be stable across imports")
+	}
+	if string(fingerprint("key", line, seen)) != string(second) {
+		t.Error("expected fingerprints to be stable across imports")
+	}
+}
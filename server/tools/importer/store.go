@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/tom-draper/api-analytics/server/database"
+	"github.com/tom-draper/api-analytics/server/logger/lib/bots"
+	"github.com/tom-draper/api-analytics/server/logger/lib/geoip"
+	"github.com/tom-draper/api-analytics/server/logger/lib/useragent"
+)
+
+// Columns inserted for each imported request, matching the logger
+var insertColumns = []string{
+	"api_key",
+	"path",
+	"hostname",
+	"ip_address",
+	"status",
+	"response_time",
+	"method",
+	"framework",
+	"location",
+	"region",
+	"city",
+	"asn",
+	"organisation",
+	"user_id",
+	"created_at",
+	"user_agent_id",
+	"is_bot",
+}
+
+// Normalises fields as the logger does, rejecting requests it would not store
+func validateRequest(request RequestData) (RequestData, error) {
+	if _, ok := database.MethodID(request.Method); !ok {
+		return request, &database.ValidationError{Field: "method", Reason: "not supported"}
+	}
+	if request.IPAddress != "" && !database.ValidIPAddress(request.IPAddress) {
+		return request, &database.ValidationError{Field: "ip_address", Reason: "not an IP address"}
+	}
+
+	var err error
+	if request.UserAgent, err = database.NormaliseUserAgent(request.UserAgent); err != nil {
+		return request, err
+	}
+	if request.Hostname, err = database.NormaliseHostname(request.Hostname); err != nil {
+		return request, err
+	}
+	if request.Path, err = database.NormalisePath(request.Path); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+type store struct {
+	conn         *pgx.Conn
+	apiKey       string
+	privacyLevel int
+	locator      *geoip.Locator
+	detector     *bots.Detector
+}
+
+// Stores the requests in a batch not already imported, returning how many
+// were stored
+func (s *store) storeBatch(ctx context.Context, batch []entry) (int, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Record each line's fingerprint, keeping only those not seen before
+	fingerprints := make([][]byte, len(batch))
+	for i, e := range batch {
+		fingerprints[i] = e.fingerprint
+	}
+	query := `INSERT INTO imported_requests (api_key, fingerprint, imported_at)
+		SELECT $1, f, NOW() FROM unnest($2::bytea[]) AS f
+		ON CONFLICT DO NOTHING RETURNING fingerprint;`
+	rows, err := tx.Query(ctx, query, s.apiKey, fingerprints)
+	if err != nil {
+		return 0, err
+	}
+	inserted := make(map[string]struct{})
+	for rows.Next() {
+		var fingerprint []byte
+		if err := rows.Scan(&fingerprint); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		inserted[string(fingerprint)] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	requests := make([]RequestData, 0, len(inserted))
+	uniqueUserAgents := make(map[string]struct{})
+	for _, e := range batch {
+		if _, ok := inserted[string(e.fingerprint)]; ok {
+			requests = append(requests, e.request)
+			uniqueUserAgents[e.request.UserAgent] = struct{}{}
+		}
+	}
+	if len(requests) == 0 {
+		return 0, tx.Commit(ctx)
+	}
+
+	userAgentIDs, err := useragent.IDs(ctx, tx, uniqueUserAgents, s.detector.MatchUserAgent)
+	if err != nil {
+		return 0, err
+	}
+
+	copyRows := make([][]any, len(requests))
+	for i, request := range requests {
+		var location geoip.Location
+		if s.privacyLevel < 2 {
+			location = s.locator.Lookup(request.IPAddress)
+		}
+		_, isBot := s.detector.Match(request.UserAgent, request.IPAddress)
+
+		var ipAddress any
+		if s.privacyLevel == 0 && request.IPAddress != "" {
+			ipAddress = request.IPAddress
+		}
+		var userAgentID any
+		if id, ok := userAgentIDs[request.UserAgent]; ok {
+			userAgentID = id
+		}
+		method, _ := database.MethodID(request.Method)
+
+		copyRows[i] = []any{
+			s.apiKey,
+			request.Path,
+			nullableString(request.Hostname),
+			ipAddress,
+			request.Status,
+			request.ResponseTime,
+			method,
+			database.FrameworkAccessLog,
+			nullableString(location.Country),
+			nullableString(location.Region),
+			nullableString(location.City),
+			nullableASN(location.ASN),
+			nullableString(location.Organisation),
+			nullableString(request.UserID),
+			request.CreatedAt,
+			userAgentID,
+			isBot,
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"requests"}, insertColumns, pgx.CopyFromRows(copyRows)); err != nil {
+		return 0, err
+	}
+
+	// Rollups for the hours imported are rebuilt by the logger
+	apiKeys := make([]string, len(requests))
+	createdAt := make([]string, len(requests))
+	for i, request := range requests {
+		apiKeys[i] = s.apiKey
+		createdAt[i] = request.CreatedAt.Format(time.RFC3339Nano)
+	}
+	if err := database.MarkRollups(ctx, tx, apiKeys, createdAt); err != nil {
+		return 0, err
+	}
+	return len(requests), tx.Commit(ctx)
+}
+
+func nullableString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func nullableASN(asn uint) any {
+	if asn == 0 {
+		return nil
+	}
+	return int64(asn)
+}
@@ -0,0 +1,26 @@
+module github.com/tom-draper/api-analytics/server/tools/migrate
+
+go 1.21.0
+
+toolchain go1.21.4
+
+require github.com/tom-draper/api-analytics/server/database v0.0.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/pgx/v5 v5.7.1 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/tom-draper/api-analytics/server/config v0.0.0 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/text v0.19.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace (
+	github.com/tom-draper/api-analytics/server/config => ../../config
+	github.com/tom-draper/api-analytics/server/database => ../../database
+)
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+type Options struct {
+	command string
+	steps   int
+	help    bool
+}
+
+func getOptions() Options {
+	options := Options{steps: 1}
+	for i, arg := range os.Args[1:] {
+		var value string
+		if i+2 < len(os.Args) {
+			value = os.Args[i+2]
+		}
+
+		switch arg {
+		case "up", "down", "status":
+			if options.command == "" {
+				options.command = arg
+			}
+		case "--steps":
+			steps, err := strconv.Atoi(value)
+			if err != nil || steps <= 0 {
+				log.Fatalf("Invalid number of steps: %s", value)
+			}
+			options.steps = steps
+		case "--help":
+			options.help = true
+		}
+	}
+	return options
+}
+
+func displayHelp() {
+	fmt.Printf("Migrate - A command-line tool to upgrade the database schema.\n\nCommands:\n`up` to apply all pending migrations\n`down` to revert the most recent migration\n`status` to list migrations and whether each is applied\n\nOptions:\n`--steps` to specify the number of migrations reverted by `down` (default 1)\n`--help` to display help\n")
+}
+
+func main() {
+	options := getOptions()
+	if options.help || options.command == "" {
+		displayHelp()
+		return
+	}
+
+	ctx := context.Background()
+	conn, err := database.NewConnection(ctx)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	switch options.command {
+	case "up":
+		applied, err := database.MigrateUp(ctx, conn)
+		for _, m := range applied {
+			log.Printf("Applied %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Schema is up to date.")
+		}
+	case "down":
+		reverted, err := database.MigrateDown(ctx, conn, options.steps)
+		for _, m := range reverted {
+			log.Printf("Reverted %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No migrations to revert.")
+		}
+	case "status":
+		states, err := database.MigrationStatus(ctx, conn)
+		if err != nil {
+			log.Fatalf("Failed to fetch migration status: %v", err)
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-24s %s\n", state.Version, state.Name, applied)
+		}
+	}
+}
@@ -189,7 +189,7 @@ func TryLogRequests(legacy bool) error {
 		return err
 	}
 
-	if response.StatusCode != 201 {
+	if response.StatusCode != 202 {
 		return fmt.Errorf("status code: %d\n%s", response.StatusCode, body)
 	}
 
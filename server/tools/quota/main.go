@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/tom-draper/api-analytics/server/database"
+)
+
+type Options struct {
+	apiKey        string
+	plan          string
+	rowsPerMinute *int
+	rowsPerDay    *int
+	retentionRows *int
+	monitorCount  *int
+	dropBots      *bool
+	setPlan       string
+	plans         bool
+	reset         bool
+	help          bool
+}
+
+func parseLimit(name string, value string) *int {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Fatalf("Invalid value for %s: %s", name, value)
+	}
+	return &limit
+}
+
+func parseBool(name string, value string) *bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %s", name, value)
+	}
+	return &b
+}
+
+func getOptions() Options {
+	options := Options{}
+	for i, arg := range os.Args {
+		var value string
+		if i+1 < len(os.Args) {
+			value = os.Args[i+1]
+		}
+
+		switch arg {
+		case "--api-key":
+			options.apiKey = value
+		case "--plan":
+			options.plan = value
+		case "--rows-per-minute":
+			options.rowsPerMinute = parseLimit(arg, value)
+		case "--rows-per-day":
+			options.rowsPerDay = parseLimit(arg, value)
+		case "--retention-rows":
+			options.retentionRows = parseLimit(arg, value)
+		case "--monitor-count":
+			options.monitorCount = parseLimit(arg, value)
+		case "--drop-bots":
+			options.dropBots = parseBool(arg, value)
+		case "--set-plan":
+			options.setPlan = value
+		case "--plans":
+			options.plans = true
+		case "--reset":
+			options.reset = true
+		case "--help":
+			options.help = true
+		}
+	}
+	return options
+}
+
+func (o Options) limitsProvided() bool {
+	return o.rowsPerMinute != nil || o.rowsPerDay != nil || o.retentionRows != nil || o.monitorCount != nil || o.dropBots != nil
+}
+
+func displayHelp() {
+	fmt.Printf("Quota - A command-line tool to view and adjust account quotas.\n\nOptions:\n`--api-key` to specify the account's API key or ID\n`--plan` to assign the account to a plan\n`--rows-per-minute` to override requests accepted per minute\n`--rows-per-day` to override requests accepted per day\n`--retention-rows` to override requests kept before the oldest are deleted\n`--monitor-count` to override the number of monitors allowed\n`--drop-bots` true or false to discard requests from bots and crawlers rather than store them\n`--reset` to remove the account's overrides and return it to the default plan\n`--plans` to list all plans\n`--set-plan` to create or update a plan with the given limits\n`--help` to display help\n")
+}
+
+func displayQuota(apiKey string, quota database.Quota) {
+	fmt.Printf("%s\nPlan: %s\nRows per minute: %d\nRows per day: %d\nRetention rows: %d\nMonitor count: %d\nDrop bots: %t\n",
+		apiKey, quota.Plan, quota.RowsPerMinute, quota.RowsPerDay, quota.RetentionRows, quota.MonitorCount, quota.DropBots)
+}
+
+func displayPlans(ctx context.Context, db database.Querier) {
+	plans, err := database.GetPlans(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to fetch plans: %v", err)
+	}
+	for _, plan := range plans {
+		fmt.Printf("%s: %d rows/min, %d rows/day, %d retention rows, %d monitors, drop bots %t\n",
+			plan.Plan, plan.RowsPerMinute, plan.RowsPerDay, plan.RetentionRows, plan.MonitorCount, plan.DropBots)
+	}
+}
+
+func setPlan(ctx context.Context, db database.Querier, options Options) {
+	// Start from the plan's current limits so only those provided change
+	plan := database.DefaultQuota
+	plans, err := database.GetPlans(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to fetch plans: %v", err)
+	}
+	for _, p := range plans {
+		if p.Plan == options.setPlan {
+			plan = p
+		}
+	}
+	plan.Plan = options.setPlan
+
+	if options.rowsPerMinute != nil {
+		plan.RowsPerMinute = *options.rowsPerMinute
+	}
+	if options.rowsPerDay != nil {
+		plan.RowsPerDay = *options.rowsPerDay
+	}
+	if options.retentionRows != nil {
+		plan.RetentionRows = *options.retentionRows
+	}
+	if options.monitorCount != nil {
+		plan.MonitorCount = *options.monitorCount
+	}
+	if options.dropBots != nil {
+		plan.DropBots = *options.dropBots
+	}
+
+	if err := database.SetPlan(ctx, db, plan); err != nil {
+		log.Fatalf("Failed to update plan: %v", err)
+	}
+	log.Printf("Plan '%s' updated.", plan.Plan)
+}
+
+func setQuota(ctx context.Context, db database.Querier, options Options) {
+	// Merge with any existing overrides so only those provided change
+	overrides, err := database.GetQuotaOverrides(ctx, db, options.apiKey)
+	if err != nil {
+		log.Fatalf("Failed to fetch quota: %v", err)
+	}
+
+	if options.plan != "" {
+		overrides.Plan = options.plan
+	}
+	if options.rowsPerMinute != nil {
+		overrides.RowsPerMinute = options.rowsPerMinute
+	}
+	if options.rowsPerDay != nil {
+		overrides.RowsPerDay = options.rowsPerDay
+	}
+	if options.retentionRows != nil {
+		overrides.RetentionRows = options.retentionRows
+	}
+	if options.monitorCount != nil {
+		overrides.MonitorCount = options.monitorCount
+	}
+	if options.dropBots != nil {
+		overrides.DropBots = options.dropBots
+	}
+
+	if err := database.SetQuota(ctx, db, options.apiKey, overrides); err != nil {
+		log.Fatalf("Failed to update quota: %v", err)
+	}
+	log.Println("Quota updated.")
+}
+
+func main() {
+	options := getOptions()
+	if options.help {
+		displayHelp()
+		return
+	}
+
+	ctx := context.Background()
+	conn, err := database.NewConnection(ctx)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if options.plans {
+		displayPlans(ctx, conn)
+		return
+	}
+
+	if options.setPlan != "" {
+		setPlan(ctx, conn, options)
+		return
+	}
+
+	if options.apiKey == "" {
+		displayHelp()
+		return
+	}
+
+	// An API key is resolved to its account, and anything else is taken to
+	// be an account ID
+	if accountID, err := database.AuthenticateAPIKey(ctx, conn, options.apiKey); err == nil {
+		options.apiKey = accountID
+	}
+
+	if options.reset {
+		if err := database.DeleteQuota(ctx, conn, options.apiKey); err != nil {
+			log.Fatalf("Failed to reset quota: %v", err)
+		}
+		log.Println("Quota reset to default plan.")
+	} else if options.plan != "" || options.limitsProvided() {
+		setQuota(ctx, conn, options)
+	}
+
+	quota, err := database.GetQuota(ctx, conn, options.apiKey)
+	if err != nil {
+		log.Fatalf("Failed to fetch quota: %v", err)
+	}
+	displayQuota(options.apiKey, quota)
+}
@@ -37,7 +37,7 @@ func TotalMonitorsCount(ctx context.Context) (int, error) {
 }
 
 func MonitorsCount(ctx context.Context, interval string) (int, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -77,7 +77,7 @@ func TotalMonitors(ctx context.Context) ([]MonitorRow, error) {
 }
 
 func Monitors(ctx context.Context, interval string) ([]MonitorRow, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +130,7 @@ func TotalUserMonitors(ctx context.Context) ([]UserCount, error) {
 }
 
 func UserMonitors(ctx context.Context, interval string) ([]UserCount, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
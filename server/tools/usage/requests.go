@@ -45,7 +45,7 @@ func TotalRequestsCount(ctx context.Context) (int, error) {
 }
 
 func RequestsCount(ctx context.Context, interval string) (int, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -86,7 +86,7 @@ func TotalRequests(ctx context.Context) ([]RequestRow, error) {
 }
 
 func Requests(ctx context.Context, interval string) ([]RequestRow, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -140,7 +140,7 @@ func TotalUserRequests(ctx context.Context) ([]UserCount, error) {
 }
 
 func UserRequests(ctx context.Context, interval string) ([]UserCount, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +174,7 @@ func UserRequests(ctx context.Context, interval string) ([]UserCount, error) {
 }
 
 func UserRequestsOverLimit(ctx context.Context, limit int) ([]UserCount, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -223,7 +223,7 @@ func (r RequestsColumnSizes) Display() {
 }
 
 func RequestsColumnSize(ctx context.Context) (RequestsColumnSizes, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return RequestsColumnSizes{}, err
 	}
@@ -260,7 +260,7 @@ type ColumnValueCount[T any] struct {
 }
 
 func ColumnValuesCount[T any](ctx context.Context, column string) ([]ColumnValueCount[T], error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -305,7 +305,7 @@ func TopLocations(ctx context.Context) ([]ColumnValueCount[string], error) {
 }
 
 func AvgResponseTime(ctx context.Context) (float64, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return 0.0, err
 	}
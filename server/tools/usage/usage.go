@@ -34,7 +34,7 @@ func DisplayUserCounts(counts []UserCount) {
 }
 
 func TableSize(ctx context.Context, table string) (string, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -51,7 +51,7 @@ func TableSize(ctx context.Context, table string) (string, error) {
 }
 
 func TableColumnSize(ctx context.Context, table, column string) (string, string, float64, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return "", "", 0, err
 	}
@@ -78,7 +78,7 @@ func TableColumnSize(ctx context.Context, table, column string) (string, string,
 }
 
 func DatabaseConnections(ctx context.Context) (int, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return 0, err
 	}
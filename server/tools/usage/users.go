@@ -10,7 +10,6 @@ import (
 )
 
 type UserRow struct {
-	UserID    string    `json:"user_id"`
 	APIKey    string    `json:"api_key"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -36,7 +35,7 @@ func TotalUsersCount(ctx context.Context) (int, error) {
 }
 
 func UsersCount(ctx context.Context, interval string) (int, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -77,13 +76,13 @@ func TotalUsers(ctx context.Context) ([]UserRow, error) {
 }
 
 func Users(ctx context.Context, interval string) ([]UserRow, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close(ctx)
 
-	query := "SELECT api_key, user_id, created_at FROM users"
+	query := "SELECT api_key, created_at FROM users"
 	if interval != "" {
 		query += fmt.Sprintf(" WHERE created_at >= NOW() - interval '%s'", interval)
 	}
@@ -97,7 +96,7 @@ func Users(ctx context.Context, interval string) ([]UserRow, error) {
 	var users []UserRow
 	for rows.Next() {
 		var user UserRow
-		if err := rows.Scan(&user.APIKey, &user.UserID, &user.CreatedAt); err != nil {
+		if err := rows.Scan(&user.APIKey, &user.CreatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -133,7 +132,7 @@ func DisplayUsers(users []User) {
 }
 
 func TopUsers(ctx context.Context, n int) ([]User, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -219,7 +218,7 @@ func UnusedUsers(ctx context.Context) ([]UserTime, error) {
 }
 
 func UnusedUsersRequests(ctx context.Context) ([]UserTime, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -245,7 +244,7 @@ func UnusedUsersRequests(ctx context.Context) ([]UserTime, error) {
 }
 
 func UnusedUsersMonitors(ctx context.Context) ([]UserTime, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +270,7 @@ func UnusedUsersMonitors(ctx context.Context) ([]UserTime, error) {
 }
 
 func SinceLastRequestUsers(ctx context.Context) ([]UserTime, error) {
-	conn, err := database.NewConnection()
+	conn, err := database.NewConnection(ctx)
 	if err != nil {
 		return nil, err
 	}